@@ -0,0 +1,301 @@
+// pipes calls split methods through a running orchestrator, for debugging.
+//
+//	pipes call [flags] <Service>.<Method> ['{"bookId":"1"}']
+//
+// The request JSON is converted with the descriptors the orchestrator serves for its routes,
+// so any method it exposes can be called without generated code.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"github.com/bsmider/pipes/core/factory/admin"
+	"github.com/bsmider/pipes/core/factory/orchestrator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const usage = `Usage: pipes call [flags] <Service>.<Method> [request JSON]
+
+Calls a unary method through the orchestrator and prints the response, its status and
+the hops of the request. The method may be qualified with its proto package, e.g.
+example.BookService.GetBook. The request JSON defaults to {}, - reads it from stdin.
+
+Flags:
+`
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "call" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("call", flag.ExitOnError)
+	socket := flags.String("socket", factory.DefaultAdminSocket(), "The admin socket of the orchestrator")
+	timeout := flags.Duration("timeout", 10*time.Second, "The deadline of the request")
+	version := flags.String("version", "", "Pin the request to a version of the methods it reaches")
+	priority := flags.String("priority", "", "The priority of the request: high, normal or low")
+	traceID := flags.String("trace-id", "", "The trace id of the request, generated if empty")
+	asJSON := flags.Bool("json", false, "Print a single JSON object instead of text")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[2:])
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	input, err := readInput(flags.Arg(1))
+	if err != nil {
+		fail(err)
+	}
+
+	conn, err := grpc.NewClient("unix://"+*socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fail(err)
+	}
+	defer conn.Close()
+	client := admin.NewAdminClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	// 1. Resolve the method and the types of its messages
+	routes, err := client.ListRoutes(ctx, &admin.ListRoutesRequest{})
+	if err != nil {
+		fail(err)
+	}
+	route, err := findRoute(routes.Routes, flags.Arg(0))
+	if err != nil {
+		fail(err)
+	}
+	types, err := newTypes(routes.Files)
+	if err != nil {
+		fail(err)
+	}
+
+	// 2. Convert the JSON to the request message
+	request, err := types.newMessage(route.RequestType)
+	if err != nil {
+		fail(err)
+	}
+	if err := (protojson.UnmarshalOptions{Resolver: types.types}).Unmarshal(input, request); err != nil {
+		fail(fmt.Errorf("invalid %s: %w", route.RequestType, err))
+	}
+	payload, err := proto.Marshal(request)
+	if err != nil {
+		fail(err)
+	}
+
+	// 3. Send it through the orchestrator, the metadata is read like ingress metadata
+	var md []string
+	if *version != "" {
+		md = append(md, orchestrator.VersionHeader, *version)
+	}
+	if *priority != "" {
+		md = append(md, orchestrator.PriorityHeader, *priority)
+	}
+	if *traceID != "" {
+		md = append(md, orchestrator.TraceIDHeader, *traceID)
+	}
+	if len(md) > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, md...)
+	}
+
+	resp, err := client.Call(ctx, &admin.CallRequest{FullMethod: route.FullMethod, Payload: payload})
+	if err != nil {
+		fail(err)
+	}
+
+	// 4. Print the response
+	response, err := types.newMessage(route.ResponseType)
+	if err != nil {
+		fail(err)
+	}
+	if err := proto.Unmarshal(resp.Payload, response); err != nil {
+		fail(fmt.Errorf("invalid %s in the response: %w", route.ResponseType, err))
+	}
+	if *asJSON {
+		err = printJSON(route, resp, response, types)
+	} else {
+		err = printText(route, resp, response, types)
+	}
+	if err != nil {
+		fail(err)
+	}
+	if codes.Code(resp.Status.GetCode()) != codes.OK {
+		os.Exit(1)
+	}
+}
+
+// readInput returns the request JSON given as argument, read from stdin for "-"
+func readInput(arg string) ([]byte, error) {
+	switch arg {
+	case "":
+		return []byte("{}"), nil
+	case "-":
+		return io.ReadAll(os.Stdin)
+	default:
+		return []byte(arg), nil
+	}
+}
+
+// findRoute returns the route of a unary method named <Service>.<Method>, optionally qualified
+// by its proto package, or by its full gRPC name e.g. /example.BookService/GetBook
+func findRoute(routes []*admin.Route, method string) (*admin.Route, error) {
+	name := dotted(method)
+
+	var matches []*admin.Route
+	for _, route := range routes {
+		full := dotted(route.FullMethod)
+		if full == name || strings.HasSuffix(full, "."+name) {
+			matches = append(matches, route)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return nil, fmt.Errorf("no method %s, the orchestrator serves %s", method, strings.Join(routeNames(routes), ", "))
+	case len(matches) > 1:
+		return nil, fmt.Errorf("%s is ambiguous, it matches %s", method, strings.Join(routeNames(matches), ", "))
+	case matches[0].ClientStreams || matches[0].ServerStreams:
+		return nil, fmt.Errorf("%s is a streaming method, only unary methods can be called", method)
+	}
+	return matches[0], nil
+}
+
+// dotted turns a full gRPC method name into a dotted one, /example.BookService/GetBook becomes example.BookService.GetBook
+func dotted(method string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(method, "/"), "/", ".")
+}
+
+func routeNames(routes []*admin.Route) []string {
+	names := make([]string, len(routes))
+	for i, route := range routes {
+		names[i] = dotted(route.FullMethod)
+	}
+	return names
+}
+
+// types resolves the messages described by the files the orchestrator sent
+type types struct {
+	files *protoregistry.Files
+	types *dynamicpb.Types
+}
+
+func newTypes(files []*descriptorpb.FileDescriptorProto) (*types, error) {
+	registry, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: files})
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptors from the orchestrator: %w", err)
+	}
+	return &types{files: registry, types: dynamicpb.NewTypes(registry)}, nil
+}
+
+// newMessage returns an empty message of the named type
+func (t *types) newMessage(name string) (*dynamicpb.Message, error) {
+	descriptor, err := t.files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("unknown message %s: %w", name, err)
+	}
+	message, ok := descriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", name)
+	}
+	return dynamicpb.NewMessage(message), nil
+}
+
+// printText prints the status, the response and the hop timeline for a person
+func printText(route *admin.Route, resp *admin.CallResponse, response proto.Message, types *types) error {
+	code := codes.Code(resp.Status.GetCode())
+	fmt.Printf("%s  packet %s\n", dotted(route.FullMethod), resp.PacketId)
+	if code == codes.OK {
+		fmt.Printf("status: %s\n\n", code)
+		encoded, err := protojson.MarshalOptions{Multiline: true, Resolver: types.types}.Marshal(response)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+	} else {
+		fmt.Printf("status: %s: %s\n", code, resp.Status.GetMessage())
+	}
+
+	if len(resp.Hops) > 0 {
+		fmt.Printf("\nhops:\n")
+		start := resp.Hops[0].GetTimestamp().AsTime()
+		for _, hop := range resp.Hops {
+			fmt.Printf("  %12v  %s\n", hop.GetTimestamp().AsTime().Sub(start).Round(time.Microsecond), hop.GetBinaryId())
+		}
+	}
+	return nil
+}
+
+// printJSON prints the status, the response and the hops as one JSON object
+func printJSON(route *admin.Route, resp *admin.CallResponse, response proto.Message, types *types) error {
+	type hop struct {
+		BinaryID string    `json:"binary_id"`
+		Time     time.Time `json:"time"`
+		OffsetMs float64   `json:"offset_ms"`
+	}
+	out := struct {
+		Method   string          `json:"method"`
+		MethodID string          `json:"method_id"`
+		PacketID string          `json:"packet_id"`
+		Code     string          `json:"code"`
+		Message  string          `json:"message,omitempty"`
+		Response json.RawMessage `json:"response,omitempty"`
+		Hops     []hop           `json:"hops"`
+	}{
+		Method:   dotted(route.FullMethod),
+		MethodID: resp.MethodId,
+		PacketID: resp.PacketId,
+		Code:     codes.Code(resp.Status.GetCode()).String(),
+		Message:  resp.Status.GetMessage(),
+		Hops:     []hop{},
+	}
+
+	if codes.Code(resp.Status.GetCode()) == codes.OK {
+		encoded, err := protojson.MarshalOptions{Resolver: types.types}.Marshal(response)
+		if err != nil {
+			return err
+		}
+		out.Response = encoded
+	}
+	for _, h := range resp.Hops {
+		at := h.GetTimestamp().AsTime()
+		out.Hops = append(out.Hops, hop{
+			BinaryID: h.GetBinaryId(),
+			Time:     at,
+			OffsetMs: float64(at.Sub(resp.Hops[0].GetTimestamp().AsTime())) / float64(time.Millisecond),
+		})
+	}
+
+	encoded, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(encoded))
+	return nil
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "pipes: %v\n", err)
+	os.Exit(1)
+}
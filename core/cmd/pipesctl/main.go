@@ -0,0 +1,544 @@
+// pipesctl operates a running orchestrator over its admin socket.
+//
+//	pipesctl [--socket path] [--json] <command> [arguments]
+//
+// Methods can be named by their full method ID or any unique suffix of it, e.g. BookService.GetBook.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"github.com/bsmider/pipes/core/factory/admin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// callTimeout bounds every admin call but restart, which waits for the rollout
+const callTimeout = 10 * time.Second
+
+const usage = `Usage: pipesctl [flags] <command> [arguments]
+
+Commands:
+  pools                   list the pools and their settings
+  workers [method]        list the workers, of every pool or of one method
+  scale <method> <N>      start or retire workers until the method has N, the autoscaler leaves it at N
+  scale <method> auto     let the autoscaler size the method again
+  drain <worker-id>       let a worker finish its requests and exit
+  kill <worker-id>        kill a worker at once, it is restarted
+  restart <method>        roll a method out to fresh workers without downtime
+  pending                 list the requests waiting for an answer
+  errors [N]              list the N most recent errors
+  top                     show a live request rate, error rate and latency per method
+
+Flags:
+`
+
+func main() {
+	socket := flag.String("socket", factory.DefaultAdminSocket(), "The admin socket of the orchestrator")
+	asJSON := flag.Bool("json", false, "Print JSON instead of tables")
+	interval := flag.Duration("interval", 2*time.Second, "How often top refreshes")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conn, err := grpc.NewClient("unix://"+*socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fail(err)
+	}
+	defer conn.Close()
+
+	ctl := &ctl{client: admin.NewAdminClient(conn), json: *asJSON}
+	args := flag.Args()[1:]
+
+	switch command := flag.Arg(0); command {
+	case "pools":
+		err = ctl.pools()
+	case "workers":
+		err = ctl.workers(optional(args, 0))
+	case "scale":
+		err = ctl.scale(required(args, 0, "method"), required(args, 1, "worker count or auto"))
+	case "drain":
+		err = ctl.drain(required(args, 0, "worker id"))
+	case "kill":
+		err = ctl.kill(required(args, 0, "worker id"))
+	case "restart":
+		err = ctl.restart(required(args, 0, "method"))
+	case "pending":
+		err = ctl.pending()
+	case "errors":
+		err = ctl.errors(optional(args, 0))
+	case "top":
+		err = ctl.top(*interval)
+	default:
+		fmt.Fprintf(os.Stderr, "pipesctl: unknown command %q\n\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+// ctl runs the commands against the admin service
+type ctl struct {
+	client admin.AdminClient
+	json   bool
+}
+
+func (c *ctl) pools() error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	resp, err := c.client.ListPools(ctx, &admin.ListPoolsRequest{})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(resp)
+	}
+
+	table := newTable("METHOD", "WORKERS", "HEALTHY", "IN FLIGHT", "QUEUED", "BALANCER", "TIMEOUT", "RETRIES", "REPLICAS", "BREAKER", "LATENCY")
+	for _, pool := range resp.Pools {
+		replicas := "fixed"
+		if pool.MaxReplicas > 0 {
+			replicas = fmt.Sprintf("%d-%d", pool.MinReplicas, pool.MaxReplicas)
+		}
+		if pool.Pinned {
+			replicas = "pinned"
+		}
+		table.row(shortMethod(pool.MethodId), pool.Workers, pool.HealthyWorkers, pool.InFlight, pool.Queued,
+			pool.Balancer, pool.Timeout.AsDuration(), pool.Retries, replicas, pool.Breaker, round(pool.Latency.AsDuration()))
+	}
+	return table.flush()
+}
+
+func (c *ctl) workers(method string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	methodID := ""
+	if method != "" {
+		var err error
+		if methodID, err = c.resolve(ctx, method); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.client.ListWorkers(ctx, &admin.ListWorkersRequest{MethodId: methodID})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(resp)
+	}
+
+	table := newTable("ID", "METHOD", "PID", "ORIGIN", "UPTIME", "IN FLIGHT", "HEALTH", "VERSION")
+	for _, worker := range resp.Workers {
+		health := "healthy"
+		switch {
+		case worker.Draining:
+			health = "draining"
+		case !worker.Healthy:
+			health = "unhealthy"
+		}
+		pid := "-"
+		if worker.Pid != 0 {
+			pid = strconv.Itoa(int(worker.Pid))
+		}
+		table.row(shortWorker(worker.Id), shortMethod(worker.MethodId), pid, worker.Origin,
+			worker.Uptime.AsDuration().Round(time.Second), worker.InFlight, health, worker.Version)
+	}
+	return table.flush()
+}
+
+func (c *ctl) scale(method string, count string) error {
+	req := &admin.ScaleRequest{Autoscale: count == "auto"}
+	if !req.Autoscale {
+		replicas, err := strconv.Atoi(count)
+		if err != nil || replicas < 0 {
+			return fmt.Errorf("invalid worker count %q", count)
+		}
+		req.Replicas = int32(replicas)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	methodID, err := c.resolve(ctx, method)
+	if err != nil {
+		return err
+	}
+	req.MethodId = methodID
+	resp, err := c.client.Scale(ctx, req)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(resp)
+	}
+	if req.Autoscale {
+		fmt.Printf("%s is autoscaled again, it has %d workers\n", shortMethod(methodID), resp.Workers)
+		return nil
+	}
+	fmt.Printf("%s now has %d workers\n", shortMethod(methodID), resp.Workers)
+	return nil
+}
+
+func (c *ctl) drain(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	id, err := c.resolveWorker(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := c.client.DrainWorker(ctx, &admin.WorkerRequest{WorkerId: id}); err != nil {
+		return err
+	}
+	return c.done(fmt.Sprintf("draining %s", id))
+}
+
+func (c *ctl) kill(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	id, err := c.resolveWorker(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := c.client.KillWorker(ctx, &admin.WorkerRequest{WorkerId: id}); err != nil {
+		return err
+	}
+	return c.done(fmt.Sprintf("killed %s", id))
+}
+
+func (c *ctl) restart(method string) error {
+	// A rollout waits for the new workers to prove healthy, it is not bounded by callTimeout
+	ctx := context.Background()
+
+	methodID, err := c.resolve(ctx, method)
+	if err != nil {
+		return err
+	}
+	if !c.json {
+		fmt.Printf("restarting %s...\n", shortMethod(methodID))
+	}
+	if _, err := c.client.Restart(ctx, &admin.RestartRequest{MethodId: methodID}); err != nil {
+		return err
+	}
+	return c.done(fmt.Sprintf("restarted %s", shortMethod(methodID)))
+}
+
+func (c *ctl) pending() error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	resp, err := c.client.ListPending(ctx, &admin.ListPendingRequest{})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(resp)
+	}
+
+	table := newTable("PACKET", "METHOD", "TARGET", "AGE")
+	for _, pending := range resp.Pending {
+		table.row(pending.PacketId, shortMethod(pending.MethodId), shortWorker(pending.Target), round(pending.Age.AsDuration()))
+	}
+	return table.flush()
+}
+
+func (c *ctl) errors(count string) error {
+	limit := 20
+	if count != "" {
+		var err error
+		if limit, err = strconv.Atoi(count); err != nil || limit < 0 {
+			return fmt.Errorf("invalid error count %q", count)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	resp, err := c.client.RecentErrors(ctx, &admin.RecentErrorsRequest{Limit: int32(limit)})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(resp)
+	}
+
+	table := newTable("TIME", "METHOD", "WORKER", "PACKET", "CODE", "MESSAGE")
+	for _, e := range resp.Errors {
+		table.row(e.Time.AsTime().Local().Format(time.TimeOnly), shortMethod(e.MethodId), shortWorker(e.WorkerId),
+			orDash(e.PacketId), e.Code, e.Message)
+	}
+	return table.flush()
+}
+
+// topRow is one method in the top view
+type topRow struct {
+	Method    string  `json:"method"`
+	Workers   int32   `json:"workers"`
+	InFlight  int64   `json:"in_flight"`
+	Queued    int32   `json:"queued"`
+	Rate      float64 `json:"requests_per_second"`
+	ErrorRate float64 `json:"error_rate"`
+	Latency   string  `json:"latency"`
+}
+
+// top polls the pools every interval and shows the traffic since the previous poll, until interrupted
+func (c *ctl) top(interval time.Duration) error {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+
+	var previous map[string]*admin.Pool
+	var previousAt time.Time
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		resp, err := c.client.ListPools(ctx, &admin.ListPoolsRequest{})
+		cancel()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+
+		current := make(map[string]*admin.Pool, len(resp.Pools))
+		var rows []topRow
+		for _, pool := range resp.Pools {
+			current[pool.MethodId] = pool
+			row := topRow{
+				Method:   shortMethod(pool.MethodId),
+				Workers:  pool.Workers,
+				InFlight: pool.InFlight,
+				Queued:   pool.Queued,
+				Latency:  round(pool.Latency.AsDuration()).String(),
+			}
+			// Rates need two polls, the first view only shows the load
+			if before, ok := previous[pool.MethodId]; ok {
+				requests := pool.Requests - before.Requests
+				row.Rate = float64(requests) / now.Sub(previousAt).Seconds()
+				if requests > 0 {
+					row.ErrorRate = float64(pool.Failures-before.Failures) / float64(requests)
+				}
+			}
+			rows = append(rows, row)
+		}
+		// Keep the rows in place between refreshes
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Method < rows[j].Method })
+
+		if err := c.showTop(rows, now, previous != nil); err != nil {
+			return err
+		}
+		previous, previousAt = current, now
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// showTop prints one refresh of top, as a JSON line per refresh with --json
+func (c *ctl) showTop(rows []topRow, now time.Time, rates bool) error {
+	if c.json {
+		return printJSONValue(map[string]any{"time": now.Format(time.RFC3339), "methods": rows})
+	}
+
+	// Clear the screen and move to the top left corner
+	fmt.Print("\033[H\033[2J")
+	fmt.Printf("pipesctl top - %s\n\n", now.Format(time.TimeOnly))
+
+	table := newTable("METHOD", "REQ/S", "ERRORS", "LATENCY", "IN FLIGHT", "QUEUED", "WORKERS")
+	for _, row := range rows {
+		rate, errorRate := "-", "-"
+		if rates {
+			rate = fmt.Sprintf("%.1f", row.Rate)
+			errorRate = fmt.Sprintf("%.1f%%", row.ErrorRate*100)
+		}
+		table.row(row.Method, rate, errorRate, row.Latency, row.InFlight, row.Queued, row.Workers)
+	}
+	return table.flush()
+}
+
+// resolve returns the ID of the method named by its full ID or a unique suffix of it
+func (c *ctl) resolve(ctx context.Context, method string) (string, error) {
+	resp, err := c.client.ListPools(ctx, &admin.ListPoolsRequest{})
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, pool := range resp.Pools {
+		if pool.MethodId == method {
+			return method, nil
+		}
+		if suffix(pool.MethodId, method) {
+			matches = append(matches, pool.MethodId)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no method %s", method)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%s matches %d methods: %s", method, len(matches), strings.Join(matches, ", "))
+	}
+}
+
+// resolveWorker returns the ID of the worker named by its full ID or the short ID shown in tables
+func (c *ctl) resolveWorker(ctx context.Context, id string) (string, error) {
+	resp, err := c.client.ListWorkers(ctx, &admin.ListWorkersRequest{})
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, worker := range resp.Workers {
+		if worker.Id == id {
+			return id, nil
+		}
+		if suffix(worker.Id, id) {
+			matches = append(matches, worker.Id)
+		}
+	}
+	if len(matches) != 1 {
+		// Let the orchestrator report the unknown worker
+		return id, nil
+	}
+	return matches[0], nil
+}
+
+// done reports that a command succeeded
+func (c *ctl) done(message string) error {
+	if c.json {
+		return printJSONValue(map[string]string{"result": message})
+	}
+	fmt.Println(message)
+	return nil
+}
+
+// suffix reports whether name ends with short at a boundary of its dotted or slashed path
+func suffix(name string, short string) bool {
+	if !strings.HasSuffix(name, short) || len(short) == len(name) {
+		return false
+	}
+	boundary := name[len(name)-len(short)-1]
+	return boundary == '.' || boundary == '/'
+}
+
+// shortMethod drops the Go package path of a method ID, e.g. example.BookService.GetBook
+func shortMethod(methodID string) string {
+	if i := strings.LastIndex(methodID, "/"); i >= 0 {
+		return methodID[i+1:]
+	}
+	return methodID
+}
+
+// shortWorker drops the Go package path of a worker ID, it starts with the method ID
+func shortWorker(id string) string {
+	return orDash(shortMethod(id))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// round shortens a latency for display
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
+
+// table prints aligned columns
+type table struct {
+	w *tabwriter.Writer
+}
+
+func newTable(headers ...string) *table {
+	t := &table{w: tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)}
+	fmt.Fprintln(t.w, strings.Join(headers, "\t"))
+	return t
+}
+
+func (t *table) row(values ...any) {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = fmt.Sprint(value)
+	}
+	fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+}
+
+func (t *table) flush() error {
+	return t.w.Flush()
+}
+
+func printJSON(message proto.Message) error {
+	encoded, err := protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Marshal(message)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(encoded))
+	return nil
+}
+
+func printJSONValue(value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(encoded))
+	return nil
+}
+
+// required returns the i-th argument or exits with a usage error naming it
+func required(args []string, i int, name string) string {
+	if i >= len(args) {
+		fmt.Fprintf(os.Stderr, "pipesctl: missing %s\n", name)
+		os.Exit(2)
+	}
+	return args[i]
+}
+
+// optional returns the i-th argument, "" if there is none
+func optional(args []string, i int) string {
+	if i >= len(args) {
+		return ""
+	}
+	return args[i]
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "pipesctl: %v\n", err)
+	os.Exit(1)
+}
@@ -309,6 +309,50 @@ func (x *GetBookResponse) GetBook() *Book {
 	return nil
 }
 
+type ListBooksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AuthorId      string                 `protobuf:"bytes,1,opt,name=authorId,proto3" json:"authorId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBooksRequest) Reset() {
+	*x = ListBooksRequest{}
+	mi := &file_core_example_protos_example_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBooksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBooksRequest) ProtoMessage() {}
+
+func (x *ListBooksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_core_example_protos_example_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBooksRequest.ProtoReflect.Descriptor instead.
+func (*ListBooksRequest) Descriptor() ([]byte, []int) {
+	return file_core_example_protos_example_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListBooksRequest) GetAuthorId() string {
+	if x != nil {
+		return x.AuthorId
+	}
+	return ""
+}
+
 type GetAuthorRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	AuthorId      string                 `protobuf:"bytes,1,opt,name=authorId,proto3" json:"authorId,omitempty"`
@@ -318,7 +362,7 @@ type GetAuthorRequest struct {
 
 func (x *GetAuthorRequest) Reset() {
 	*x = GetAuthorRequest{}
-	mi := &file_core_example_protos_example_proto_msgTypes[6]
+	mi := &file_core_example_protos_example_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -330,7 +374,7 @@ func (x *GetAuthorRequest) String() string {
 func (*GetAuthorRequest) ProtoMessage() {}
 
 func (x *GetAuthorRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_core_example_protos_example_proto_msgTypes[6]
+	mi := &file_core_example_protos_example_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -343,7 +387,7 @@ func (x *GetAuthorRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetAuthorRequest.ProtoReflect.Descriptor instead.
 func (*GetAuthorRequest) Descriptor() ([]byte, []int) {
-	return file_core_example_protos_example_proto_rawDescGZIP(), []int{6}
+	return file_core_example_protos_example_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *GetAuthorRequest) GetAuthorId() string {
@@ -362,7 +406,7 @@ type GetAuthorResponse struct {
 
 func (x *GetAuthorResponse) Reset() {
 	*x = GetAuthorResponse{}
-	mi := &file_core_example_protos_example_proto_msgTypes[7]
+	mi := &file_core_example_protos_example_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -374,7 +418,7 @@ func (x *GetAuthorResponse) String() string {
 func (*GetAuthorResponse) ProtoMessage() {}
 
 func (x *GetAuthorResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_core_example_protos_example_proto_msgTypes[7]
+	mi := &file_core_example_protos_example_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -387,7 +431,7 @@ func (x *GetAuthorResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetAuthorResponse.ProtoReflect.Descriptor instead.
 func (*GetAuthorResponse) Descriptor() ([]byte, []int) {
-	return file_core_example_protos_example_proto_rawDescGZIP(), []int{7}
+	return file_core_example_protos_example_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *GetAuthorResponse) GetAuthor() *Author {
@@ -419,14 +463,17 @@ const file_core_example_protos_example_proto_rawDesc = "" +
 	"\x06bookId\x18\x01 \x01(\tR\x06bookId\"4\n" +
 	"\x0fGetBookResponse\x12!\n" +
 	"\x04book\x18\x01 \x01(\v2\r.example.BookR\x04book\".\n" +
+	"\x10ListBooksRequest\x12\x1a\n" +
+	"\bauthorId\x18\x01 \x01(\tR\bauthorId\".\n" +
 	"\x10GetAuthorRequest\x12\x1a\n" +
 	"\bauthorId\x18\x01 \x01(\tR\bauthorId\"<\n" +
 	"\x11GetAuthorResponse\x12'\n" +
-	"\x06author\x18\x01 \x01(\v2\x0f.example.AuthorR\x06author2\xfd\x01\n" +
+	"\x06author\x18\x01 \x01(\v2\x0f.example.AuthorR\x06author2\xb6\x02\n" +
 	"\vBookService\x12<\n" +
 	"\aGetBook\x12\x17.example.GetBookRequest\x1a\x18.example.GetBookResponse\x12l\n" +
 	"\x17GetAuthorNameFromBookId\x12'.example.GetAuthorNameFromBookIdRequest\x1a(.example.GetAuthorNameFromBookIdResponse\x12B\n" +
-	"\tGetAuthor\x12\x19.example.GetAuthorRequest\x1a\x1a.example.GetAuthorResponseB=Z;github.com/bsmider/pipes/core/example/build/example;exampleb\x06proto3"
+	"\tGetAuthor\x12\x19.example.GetAuthorRequest\x1a\x1a.example.GetAuthorResponse\x127\n" +
+	"\tListBooks\x12\x19.example.ListBooksRequest\x1a\r.example.Book0\x01B=Z;github.com/bsmider/pipes/core/example/build/example;exampleb\x06proto3"
 
 var (
 	file_core_example_protos_example_proto_rawDescOnce sync.Once
@@ -440,7 +487,7 @@ func file_core_example_protos_example_proto_rawDescGZIP() []byte {
 	return file_core_example_protos_example_proto_rawDescData
 }
 
-var file_core_example_protos_example_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_core_example_protos_example_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
 var file_core_example_protos_example_proto_goTypes = []any{
 	(*GetAuthorNameFromBookIdRequest)(nil),  // 0: example.GetAuthorNameFromBookIdRequest
 	(*GetAuthorNameFromBookIdResponse)(nil), // 1: example.GetAuthorNameFromBookIdResponse
@@ -448,20 +495,23 @@ var file_core_example_protos_example_proto_goTypes = []any{
 	(*Book)(nil),                            // 3: example.Book
 	(*GetBookRequest)(nil),                  // 4: example.GetBookRequest
 	(*GetBookResponse)(nil),                 // 5: example.GetBookResponse
-	(*GetAuthorRequest)(nil),                // 6: example.GetAuthorRequest
-	(*GetAuthorResponse)(nil),               // 7: example.GetAuthorResponse
+	(*ListBooksRequest)(nil),                // 6: example.ListBooksRequest
+	(*GetAuthorRequest)(nil),                // 7: example.GetAuthorRequest
+	(*GetAuthorResponse)(nil),               // 8: example.GetAuthorResponse
 }
 var file_core_example_protos_example_proto_depIdxs = []int32{
 	3, // 0: example.GetBookResponse.book:type_name -> example.Book
 	2, // 1: example.GetAuthorResponse.author:type_name -> example.Author
 	4, // 2: example.BookService.GetBook:input_type -> example.GetBookRequest
 	0, // 3: example.BookService.GetAuthorNameFromBookId:input_type -> example.GetAuthorNameFromBookIdRequest
-	6, // 4: example.BookService.GetAuthor:input_type -> example.GetAuthorRequest
-	5, // 5: example.BookService.GetBook:output_type -> example.GetBookResponse
-	1, // 6: example.BookService.GetAuthorNameFromBookId:output_type -> example.GetAuthorNameFromBookIdResponse
-	7, // 7: example.BookService.GetAuthor:output_type -> example.GetAuthorResponse
-	5, // [5:8] is the sub-list for method output_type
-	2, // [2:5] is the sub-list for method input_type
+	7, // 4: example.BookService.GetAuthor:input_type -> example.GetAuthorRequest
+	6, // 5: example.BookService.ListBooks:input_type -> example.ListBooksRequest
+	5, // 6: example.BookService.GetBook:output_type -> example.GetBookResponse
+	1, // 7: example.BookService.GetAuthorNameFromBookId:output_type -> example.GetAuthorNameFromBookIdResponse
+	8, // 8: example.BookService.GetAuthor:output_type -> example.GetAuthorResponse
+	3, // 9: example.BookService.ListBooks:output_type -> example.Book
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
 	2, // [2:2] is the sub-list for extension type_name
 	2, // [2:2] is the sub-list for extension extendee
 	0, // [0:2] is the sub-list for field type_name
@@ -478,7 +528,7 @@ func file_core_example_protos_example_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_core_example_protos_example_proto_rawDesc), len(file_core_example_protos_example_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   9,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
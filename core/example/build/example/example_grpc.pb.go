@@ -22,6 +22,7 @@ const (
 	BookService_GetBook_FullMethodName                 = "/example.BookService/GetBook"
 	BookService_GetAuthorNameFromBookId_FullMethodName = "/example.BookService/GetAuthorNameFromBookId"
 	BookService_GetAuthor_FullMethodName               = "/example.BookService/GetAuthor"
+	BookService_ListBooks_FullMethodName               = "/example.BookService/ListBooks"
 )
 
 // BookServiceClient is the client API for BookService service.
@@ -31,6 +32,7 @@ type BookServiceClient interface {
 	GetBook(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (*GetBookResponse, error)
 	GetAuthorNameFromBookId(ctx context.Context, in *GetAuthorNameFromBookIdRequest, opts ...grpc.CallOption) (*GetAuthorNameFromBookIdResponse, error)
 	GetAuthor(ctx context.Context, in *GetAuthorRequest, opts ...grpc.CallOption) (*GetAuthorResponse, error)
+	ListBooks(ctx context.Context, in *ListBooksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Book], error)
 }
 
 type bookServiceClient struct {
@@ -71,6 +73,25 @@ func (c *bookServiceClient) GetAuthor(ctx context.Context, in *GetAuthorRequest,
 	return out, nil
 }
 
+func (c *bookServiceClient) ListBooks(ctx context.Context, in *ListBooksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Book], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BookService_ServiceDesc.Streams[0], BookService_ListBooks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListBooksRequest, Book]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BookService_ListBooksClient = grpc.ServerStreamingClient[Book]
+
 // BookServiceServer is the server API for BookService service.
 // All implementations must embed UnimplementedBookServiceServer
 // for forward compatibility.
@@ -78,6 +99,7 @@ type BookServiceServer interface {
 	GetBook(context.Context, *GetBookRequest) (*GetBookResponse, error)
 	GetAuthorNameFromBookId(context.Context, *GetAuthorNameFromBookIdRequest) (*GetAuthorNameFromBookIdResponse, error)
 	GetAuthor(context.Context, *GetAuthorRequest) (*GetAuthorResponse, error)
+	ListBooks(*ListBooksRequest, grpc.ServerStreamingServer[Book]) error
 	mustEmbedUnimplementedBookServiceServer()
 }
 
@@ -97,6 +119,9 @@ func (UnimplementedBookServiceServer) GetAuthorNameFromBookId(context.Context, *
 func (UnimplementedBookServiceServer) GetAuthor(context.Context, *GetAuthorRequest) (*GetAuthorResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetAuthor not implemented")
 }
+func (UnimplementedBookServiceServer) ListBooks(*ListBooksRequest, grpc.ServerStreamingServer[Book]) error {
+	return status.Error(codes.Unimplemented, "method ListBooks not implemented")
+}
 func (UnimplementedBookServiceServer) mustEmbedUnimplementedBookServiceServer() {}
 func (UnimplementedBookServiceServer) testEmbeddedByValue()                     {}
 
@@ -172,6 +197,17 @@ func _BookService_GetAuthor_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _BookService_ListBooks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListBooksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BookServiceServer).ListBooks(m, &grpc.GenericServerStream[ListBooksRequest, Book]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BookService_ListBooksServer = grpc.ServerStreamingServer[Book]
+
 // BookService_ServiceDesc is the grpc.ServiceDesc for BookService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -192,6 +228,12 @@ var BookService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _BookService_GetAuthor_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListBooks",
+			Handler:       _BookService_ListBooks_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "core/example/protos/example.proto",
 }
@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"flag"
+	"log"
+	"os"
+	"syscall"
 
 	"github.com/bsmider/pipes/core/example/build/example"
 	"github.com/bsmider/pipes/core/factory/processes"
@@ -14,9 +17,29 @@ func GetAuthor(ctx context.Context, req *example.GetAuthorRequest) (*example.Get
 
 func main() {
 	nodeID := flag.String("id", "default-worker", "The unique ID for this worker instance")
+	maxConcurrency := flag.Int("max-concurrency", processes.DefaultMaxConcurrency, "Requests handled at once, further requests are turned away (0 is unlimited)")
+	orchestratorAddr := flag.String("orchestrator", "", "Address of an orchestrator to dial over mutual TLS, instead of being started by it")
+	tlsCert := flag.String("tls-cert", "worker.pem", "The certificate this worker presents to the orchestrator")
+	tlsKey := flag.String("tls-key", "worker-key.pem", "The key of the worker certificate")
+	tlsCA := flag.String("tls-ca", "ca.pem", "The CA that signed the orchestrator certificate")
+	attachSocket := flag.String("attach", "", "Path of the attach socket of a running orchestrator to join, see its -attach-socket flag")
 	flag.Parse()
+	switch {
+	case *orchestratorAddr != "":
+		if err := processes.DialOrchestratorWithCerts(*orchestratorAddr, "github.com/bsmider/pipes/core/example/build/example.BookService.GetAuthor", *tlsCert, *tlsKey, *tlsCA); err != nil {
+			log.Fatalf("[ProcessRunner] %v", err)
+		}
+	case *attachSocket != "":
+		if err := processes.AttachOrchestrator(*attachSocket, "github.com/bsmider/pipes/core/example/build/example.BookService.GetAuthor"); err != nil {
+			log.Fatalf("[ProcessRunner] %v", err)
+		}
+	}
 	node := processes.GetIONode(*nodeID)
+	node.SetMaxConcurrency(*maxConcurrency)
 	node.Listen()
+	node.DrainOnSignal(os.Interrupt, syscall.SIGTERM)
 	processes.Handle(GetAuthor)
-	select {}
+
+	// Exit once the node has drained its in-flight requests
+	<-node.Done()
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"syscall"
+
+	"fmt"
+	"github.com/bsmider/pipes/core/example/build/example"
+	"github.com/bsmider/pipes/core/factory/processes"
+)
+
+func ListBooks(req *example.ListBooksRequest, stream example.BookService_ListBooksServer) error {
+	for i := 1; i <= 3; i++ {
+		bookId := fmt.Sprintf("%s-%d", req.AuthorId, i)
+		authorResponse, err := processes.Call[*example.GetAuthorNameFromBookIdRequest, *example.GetAuthorNameFromBookIdResponse]("github.com/bsmider/pipes/core/example/build/example.BookService.GetAuthorNameFromBookId", stream.Context(), &example.GetAuthorNameFromBookIdRequest{
+	BookId: bookId,
+})
+		if err != nil {
+			return err
+		}
+
+		book := &example.Book{
+			BookId:   bookId,
+			AuthorId: authorResponse.AuthorName,
+			Title:    fmt.Sprintf("book %d", i),
+		}
+		if err := stream.Send(book); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	nodeID := flag.String("id", "default-worker", "The unique ID for this worker instance")
+	maxConcurrency := flag.Int("max-concurrency", processes.DefaultMaxConcurrency, "Requests handled at once, further requests are turned away (0 is unlimited)")
+	orchestratorAddr := flag.String("orchestrator", "", "Address of an orchestrator to dial over mutual TLS, instead of being started by it")
+	tlsCert := flag.String("tls-cert", "worker.pem", "The certificate this worker presents to the orchestrator")
+	tlsKey := flag.String("tls-key", "worker-key.pem", "The key of the worker certificate")
+	tlsCA := flag.String("tls-ca", "ca.pem", "The CA that signed the orchestrator certificate")
+	attachSocket := flag.String("attach", "", "Path of the attach socket of a running orchestrator to join, see its -attach-socket flag")
+	flag.Parse()
+	switch {
+	case *orchestratorAddr != "":
+		if err := processes.DialOrchestratorWithCerts(*orchestratorAddr, "github.com/bsmider/pipes/core/example/build/example.BookService.ListBooks", *tlsCert, *tlsKey, *tlsCA); err != nil {
+			log.Fatalf("[ProcessRunner] %v", err)
+		}
+	case *attachSocket != "":
+		if err := processes.AttachOrchestrator(*attachSocket, "github.com/bsmider/pipes/core/example/build/example.BookService.ListBooks"); err != nil {
+			log.Fatalf("[ProcessRunner] %v", err)
+		}
+	}
+	node := processes.GetIONode(*nodeID)
+	node.SetMaxConcurrency(*maxConcurrency)
+	node.Listen()
+	node.DrainOnSignal(os.Interrupt, syscall.SIGTERM)
+	processes.HandleServerStream(ListBooks)
+
+	// Exit once the node has drained its in-flight requests
+	<-node.Done()
+}
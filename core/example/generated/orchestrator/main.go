@@ -1,23 +1,208 @@
 package main
 
 import (
-	"github.com/bsmider/pipes/core/factory/orchestrator"
+	"context"
+	"crypto/tls"
+	"flag"
 	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"github.com/bsmider/pipes/core/factory/orchestrator"
+	"github.com/bsmider/pipes/core/factory/utils"
+	example "github.com/bsmider/pipes/core/example/build/example"
 )
 
 func main() {
+	grpcAddr := flag.String("grpc-addr", ":50051", "The address the gRPC ingress listens on")
+	httpAddr := flag.String("http-addr", ":8080", "The address the HTTP/JSON ingress listens on")
+	minReplicas := flag.Int("min-replicas", 1, "The minimum number of workers per method, 0 serves the methods with remote workers only")
+	maxReplicas := flag.Int("max-replicas", 4, "The maximum number of workers per method, the pools autoscale in between")
+	balancer := flag.String("balancer", "round-robin", "How workers are picked: round-robin, least-outstanding, power-of-two or weighted")
+	workerAddr := flag.String("worker-addr", "", "The address remote workers dial over mutual TLS, empty only runs local workers")
+	tlsCert := flag.String("tls-cert", "orchestrator.pem", "The certificate presented to remote workers")
+	tlsKey := flag.String("tls-key", "orchestrator-key.pem", "The key of the orchestrator certificate")
+	tlsCA := flag.String("tls-ca", "ca.pem", "The CA remote worker certificates must be signed by")
+	attachSocket := flag.String("attach-socket", factory.DefaultAttachSocket(), "The Unix socket workers launched by hand attach on, empty disables it")
+	adminSocket := flag.String("admin-socket", factory.DefaultAdminSocket(), "The Unix socket the admin service is served on, empty disables it")
+	name := flag.String("name", "", "The name announced to peer orchestrators, defaults to the host name")
+	peerAddr := flag.String("peer-addr", "", "The address peer orchestrators dial over mutual TLS, empty accepts no peers")
+	peers := flag.String("peers", "", "Comma separated addresses of peer orchestrators, requests for methods without local workers are forwarded to them")
+	ingressPriority := flag.String("ingress-priority-limit", "normal", "The highest priority ingress clients may claim with the x-priority header, high lets any client jump the queues")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "How long to wait for in-flight requests on shutdown")
+	flag.Parse()
+
 	orch := orchestrator.NewOrchestrator()
+	if *name != "" {
+		orch.SetName(*name)
+	}
+	priorityLimit, err := factory.ParsePriority(*ingressPriority)
+	if err != nil {
+		log.Fatalf("Invalid -ingress-priority-limit: %v", err)
+	}
+	orch.SetIngressPriorityLimit(priorityLimit)
+
+	// Pool settings shared by every method, "//pipes:pool" directives override them per method
+	defaultOptions := func() orchestrator.PoolOptions {
+		options := orchestrator.DefaultPoolOptions()
+		options.Replicas = *minReplicas
+		options.MaxReplicas = *maxReplicas
+		options.Balancer = newBalancer(*balancer, "-balancer")
+		return options
+	}
+
+	var options orchestrator.PoolOptions
 
-	if err := orch.Spawn("github.com/bsmider/pipes/core/example/build/example.BookService.GetBook", "./f5bcc3da3077_GetBook", 1); err != nil {
+	options = defaultOptions()
+	if err := orch.SpawnWithOptions("github.com/bsmider/pipes/core/example/build/example.BookService.GetBook", "./f5bcc3da3077_GetBook", options); err != nil {
 		log.Fatalf("Failed to spawn worker for %s: %v", "GetBook", err)
 	}
-	if err := orch.Spawn("github.com/bsmider/pipes/core/example/build/example.BookService.GetAuthorNameFromBookId", "./743aee161164_GetAuthorNameFromBookId", 1); err != nil {
+
+	options = defaultOptions()
+	options.Timeout = 500 * time.Millisecond
+	options.Retries = 0
+	options.MaxConcurrency = 16
+	options.Admission.PerCaller.MaxConcurrent = 8
+	if err := orch.SpawnWithOptions("github.com/bsmider/pipes/core/example/build/example.BookService.GetAuthorNameFromBookId", "./743aee161164_GetAuthorNameFromBookId", options); err != nil {
 		log.Fatalf("Failed to spawn worker for %s: %v", "GetAuthorNameFromBookId", err)
 	}
-	if err := orch.Spawn("github.com/bsmider/pipes/core/example/build/example.BookService.GetAuthor", "./3dbb7c569bfe_GetAuthor", 1); err != nil {
+
+	options = defaultOptions()
+	options.Timeout = 20 * time.Second
+	options.Balancer = newBalancer("least-outstanding", "//pipes:pool balancer=least-outstanding directive of ListBooks")
+	if err := orch.SpawnWithOptions("github.com/bsmider/pipes/core/example/build/example.BookService.ListBooks", "./0df8f9749168_ListBooks", options); err != nil {
+		log.Fatalf("Failed to spawn worker for %s: %v", "ListBooks", err)
+	}
+
+	options = defaultOptions()
+	options.Replicas = 2
+	options.Hedging = orchestrator.DefaultHedgingPolicy(2)
+	if err := orch.SpawnWithOptions("github.com/bsmider/pipes/core/example/build/example.BookService.GetAuthor", "./3dbb7c569bfe_GetAuthor", options); err != nil {
 		log.Fatalf("Failed to spawn worker for %s: %v", "GetAuthor", err)
 	}
 
-	// Block forever to keep the orchestrator running
-	select {}
+	orch.AddRoute(orchestrator.NewRoute[*example.GetBookRequest, *example.GetBookResponse]("BookService", "GetBook", "github.com/bsmider/pipes/core/example/build/example.BookService.GetBook"))
+	orch.AddRoute(orchestrator.NewRoute[*example.GetAuthorNameFromBookIdRequest, *example.GetAuthorNameFromBookIdResponse]("BookService", "GetAuthorNameFromBookId", "github.com/bsmider/pipes/core/example/build/example.BookService.GetAuthorNameFromBookId"))
+	orch.AddRoute(orchestrator.NewStreamRoute[*example.ListBooksRequest, *example.Book]("BookService", "ListBooks", "github.com/bsmider/pipes/core/example/build/example.BookService.ListBooks", false, true))
+	orch.AddRoute(orchestrator.NewRoute[*example.GetAuthorRequest, *example.GetAuthorResponse]("BookService", "GetAuthor", "github.com/bsmider/pipes/core/example/build/example.BookService.GetAuthor"))
+
+	lis, err := net.Listen("tcp", *grpcAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", *grpcAddr, err)
+	}
+	go func() {
+		if err := orch.ServeGRPC(lis); err != nil {
+			log.Fatalf("gRPC ingress stopped: %v", err)
+		}
+	}()
+
+	httpLis, err := net.Listen("tcp", *httpAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", *httpAddr, err)
+	}
+	go func() {
+		if err := orch.ServeHTTPIngress(httpLis); err != nil {
+			log.Fatalf("HTTP ingress stopped: %v", err)
+		}
+	}()
+
+	if *workerAddr != "" {
+		tlsConfig, err := utils.ServerTLSConfig(*tlsCert, *tlsKey, *tlsCA)
+		if err != nil {
+			log.Fatalf("Failed to set up TLS for remote workers: %v", err)
+		}
+		workerLis, err := tls.Listen("tcp", *workerAddr, tlsConfig)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", *workerAddr, err)
+		}
+		go func() {
+			if err := orch.ServeRemoteWorkers(workerLis); err != nil {
+				log.Fatalf("Remote worker listener stopped: %v", err)
+			}
+		}()
+	}
+
+	if *peerAddr != "" {
+		tlsConfig, err := utils.ServerTLSConfig(*tlsCert, *tlsKey, *tlsCA)
+		if err != nil {
+			log.Fatalf("Failed to set up TLS for peers: %v", err)
+		}
+		peerLis, err := tls.Listen("tcp", *peerAddr, tlsConfig)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", *peerAddr, err)
+		}
+		go func() {
+			if err := orch.ServePeers(peerLis); err != nil {
+				log.Fatalf("Peer listener stopped: %v", err)
+			}
+		}()
+	}
+	if *peers != "" {
+		tlsConfig, err := utils.ClientTLSConfig(*tlsCert, *tlsKey, *tlsCA)
+		if err != nil {
+			log.Fatalf("Failed to set up TLS for peers: %v", err)
+		}
+		for _, addr := range strings.Split(*peers, ",") {
+			orch.AddPeer(strings.TrimSpace(addr), tlsConfig)
+		}
+	}
+
+	if *attachSocket != "" {
+		if attachLis, err := orchestrator.ListenAttachSocket(*attachSocket); err != nil {
+			log.Printf("Not accepting attached workers: %v", err)
+		} else {
+			go func() {
+				if err := orch.ServeRemoteWorkers(attachLis); err != nil {
+					log.Printf("Attach socket stopped: %v", err)
+				}
+			}()
+		}
+	}
+
+	if *adminSocket != "" {
+		if adminLis, err := orchestrator.ListenAdminSocket(*adminSocket); err != nil {
+			log.Printf("Not serving the admin service: %v", err)
+		} else {
+			go func() {
+				if err := orch.ServeAdmin(adminLis); err != nil {
+					log.Printf("Admin service stopped: %v", err)
+				}
+			}()
+		}
+	}
+
+	// SIGHUP rolls every method out to the binary now on disk, without downtime
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := orch.ReplaceAll(); err != nil {
+				log.Printf("Rollout incomplete: %v", err)
+			}
+		}
+	}()
+
+	// Run until asked to stop, then drain gracefully
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := orch.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown did not complete cleanly: %v", err)
+	}
+}
+
+func newBalancer(name string, source string) orchestrator.Balancer {
+	balancer, err := orchestrator.NewBalancer(name)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", source, err)
+	}
+	return balancer
 }
@@ -2,6 +2,7 @@ package example
 
 import (
 	"context"
+	"fmt"
 	"log"
 
 	"github.com/bsmider/pipes/core/example/build/example"
@@ -35,9 +36,39 @@ func (s *BookService) GetBook(ctx context.Context, req *example.GetBookRequest)
 	return &bookResponse, nil
 }
 
+// GetAuthorNameFromBookId is a cheap lookup, a slow answer is better retried by the caller.
+// Each calling method gets its own share so a busy caller cannot starve the others.
+//
+//pipes:pool timeout=500ms retries=0 max-concurrency=16
+//pipes:pool caller-max-in-flight=8
 func (s *BookService) GetAuthorNameFromBookId(context context.Context, req *example.GetAuthorNameFromBookIdRequest) (*example.GetAuthorNameFromBookIdResponse, error) {
 	log.Printf("GetAuthorNameFromBookId")
 	return &example.GetAuthorNameFromBookIdResponse{
 		AuthorName: "BREVIN SMIDER",
 	}, nil
 }
+
+// ListBooks streams every book of an author
+//
+//pipes:pool timeout=20s balancer=least-outstanding
+func (s *BookService) ListBooks(req *example.ListBooksRequest, stream example.BookService_ListBooksServer) error {
+	for i := 1; i <= 3; i++ {
+		bookId := fmt.Sprintf("%s-%d", req.AuthorId, i)
+		authorResponse, err := s.GetAuthorNameFromBookId(stream.Context(), &example.GetAuthorNameFromBookIdRequest{
+			BookId: bookId,
+		})
+		if err != nil {
+			return err
+		}
+
+		book := &example.Book{
+			BookId:   bookId,
+			AuthorId: authorResponse.AuthorName,
+			Title:    fmt.Sprintf("book %d", i),
+		}
+		if err := stream.Send(book); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/bsmider/pipes/core/example/build/example"
 )
 
+// GetAuthor is read-only, so a slow answer is raced against a second replica
+//
+//pipes:pool replicas=2 hedge-attempts=2
 func (s *BookService) GetAuthor(ctx context.Context, req *example.GetAuthorRequest) (*example.GetAuthorResponse, error) {
 	return nil, nil
 }
@@ -0,0 +1,1576 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: core/factory/protos/admin.proto
+
+package admin
+
+import (
+	factory "github.com/bsmider/pipes/core/factory"
+	status "google.golang.org/genproto/googleapis/rpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Pool struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	MethodId       string                 `protobuf:"bytes,1,opt,name=method_id,json=methodId,proto3" json:"method_id,omitempty"`
+	Balancer       string                 `protobuf:"bytes,2,opt,name=balancer,proto3" json:"balancer,omitempty"`
+	Timeout        *durationpb.Duration   `protobuf:"bytes,3,opt,name=timeout,proto3" json:"timeout,omitempty"`  // per attempt
+	Retries        int32                  `protobuf:"varint,4,opt,name=retries,proto3" json:"retries,omitempty"` // attempts after the first
+	Workers        int32                  `protobuf:"varint,5,opt,name=workers,proto3" json:"workers,omitempty"`
+	HealthyWorkers int32                  `protobuf:"varint,6,opt,name=healthy_workers,json=healthyWorkers,proto3" json:"healthy_workers,omitempty"`
+	InFlight       int64                  `protobuf:"varint,7,opt,name=in_flight,json=inFlight,proto3" json:"in_flight,omitempty"`
+	Queued         int32                  `protobuf:"varint,8,opt,name=queued,proto3" json:"queued,omitempty"` // requests waiting for a free slot
+	Breaker        string                 `protobuf:"bytes,9,opt,name=breaker,proto3" json:"breaker,omitempty"`
+	Binary         string                 `protobuf:"bytes,10,opt,name=binary,proto3" json:"binary,omitempty"`
+	Latency        *durationpb.Duration   `protobuf:"bytes,11,opt,name=latency,proto3" json:"latency,omitempty"`                             // moving average
+	MinReplicas    int32                  `protobuf:"varint,12,opt,name=min_replicas,json=minReplicas,proto3" json:"min_replicas,omitempty"` // 0 when the pool is not autoscaled
+	MaxReplicas    int32                  `protobuf:"varint,13,opt,name=max_replicas,json=maxReplicas,proto3" json:"max_replicas,omitempty"`
+	Requests       uint64                 `protobuf:"varint,14,opt,name=requests,proto3" json:"requests,omitempty"` // routed to the pool since it was created
+	Failures       uint64                 `protobuf:"varint,15,opt,name=failures,proto3" json:"failures,omitempty"` // of those requests, cancelled ones aside
+	Pinned         bool                   `protobuf:"varint,16,opt,name=pinned,proto3" json:"pinned,omitempty"`     // sized by Scale, the autoscaler leaves the pool alone
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Pool) Reset() {
+	*x = Pool{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Pool) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Pool) ProtoMessage() {}
+
+func (x *Pool) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Pool.ProtoReflect.Descriptor instead.
+func (*Pool) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Pool) GetMethodId() string {
+	if x != nil {
+		return x.MethodId
+	}
+	return ""
+}
+
+func (x *Pool) GetBalancer() string {
+	if x != nil {
+		return x.Balancer
+	}
+	return ""
+}
+
+func (x *Pool) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+func (x *Pool) GetRetries() int32 {
+	if x != nil {
+		return x.Retries
+	}
+	return 0
+}
+
+func (x *Pool) GetWorkers() int32 {
+	if x != nil {
+		return x.Workers
+	}
+	return 0
+}
+
+func (x *Pool) GetHealthyWorkers() int32 {
+	if x != nil {
+		return x.HealthyWorkers
+	}
+	return 0
+}
+
+func (x *Pool) GetInFlight() int64 {
+	if x != nil {
+		return x.InFlight
+	}
+	return 0
+}
+
+func (x *Pool) GetQueued() int32 {
+	if x != nil {
+		return x.Queued
+	}
+	return 0
+}
+
+func (x *Pool) GetBreaker() string {
+	if x != nil {
+		return x.Breaker
+	}
+	return ""
+}
+
+func (x *Pool) GetBinary() string {
+	if x != nil {
+		return x.Binary
+	}
+	return ""
+}
+
+func (x *Pool) GetLatency() *durationpb.Duration {
+	if x != nil {
+		return x.Latency
+	}
+	return nil
+}
+
+func (x *Pool) GetMinReplicas() int32 {
+	if x != nil {
+		return x.MinReplicas
+	}
+	return 0
+}
+
+func (x *Pool) GetMaxReplicas() int32 {
+	if x != nil {
+		return x.MaxReplicas
+	}
+	return 0
+}
+
+func (x *Pool) GetRequests() uint64 {
+	if x != nil {
+		return x.Requests
+	}
+	return 0
+}
+
+func (x *Pool) GetFailures() uint64 {
+	if x != nil {
+		return x.Failures
+	}
+	return 0
+}
+
+func (x *Pool) GetPinned() bool {
+	if x != nil {
+		return x.Pinned
+	}
+	return false
+}
+
+type Worker struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	MethodId      string                 `protobuf:"bytes,2,opt,name=method_id,json=methodId,proto3" json:"method_id,omitempty"`
+	Pid           int32                  `protobuf:"varint,3,opt,name=pid,proto3" json:"pid,omitempty"`      // 0 for remote workers
+	Origin        string                 `protobuf:"bytes,4,opt,name=origin,proto3" json:"origin,omitempty"` // "PID n" or where a remote worker connected from
+	Uptime        *durationpb.Duration   `protobuf:"bytes,5,opt,name=uptime,proto3" json:"uptime,omitempty"`
+	InFlight      int64                  `protobuf:"varint,6,opt,name=in_flight,json=inFlight,proto3" json:"in_flight,omitempty"`
+	Healthy       bool                   `protobuf:"varint,7,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Draining      bool                   `protobuf:"varint,8,opt,name=draining,proto3" json:"draining,omitempty"`
+	Version       string                 `protobuf:"bytes,9,opt,name=version,proto3" json:"version,omitempty"`
+	Binary        string                 `protobuf:"bytes,10,opt,name=binary,proto3" json:"binary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Worker) Reset() {
+	*x = Worker{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Worker) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Worker) ProtoMessage() {}
+
+func (x *Worker) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Worker.ProtoReflect.Descriptor instead.
+func (*Worker) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Worker) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Worker) GetMethodId() string {
+	if x != nil {
+		return x.MethodId
+	}
+	return ""
+}
+
+func (x *Worker) GetPid() int32 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *Worker) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *Worker) GetUptime() *durationpb.Duration {
+	if x != nil {
+		return x.Uptime
+	}
+	return nil
+}
+
+func (x *Worker) GetInFlight() int64 {
+	if x != nil {
+		return x.InFlight
+	}
+	return 0
+}
+
+func (x *Worker) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *Worker) GetDraining() bool {
+	if x != nil {
+		return x.Draining
+	}
+	return false
+}
+
+func (x *Worker) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Worker) GetBinary() string {
+	if x != nil {
+		return x.Binary
+	}
+	return ""
+}
+
+type PendingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PacketId      string                 `protobuf:"bytes,1,opt,name=packet_id,json=packetId,proto3" json:"packet_id,omitempty"`
+	MethodId      string                 `protobuf:"bytes,2,opt,name=method_id,json=methodId,proto3" json:"method_id,omitempty"`
+	Target        string                 `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"` // the worker or peer the request was sent to
+	Age           *durationpb.Duration   `protobuf:"bytes,4,opt,name=age,proto3" json:"age,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PendingResponse) Reset() {
+	*x = PendingResponse{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PendingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PendingResponse) ProtoMessage() {}
+
+func (x *PendingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PendingResponse.ProtoReflect.Descriptor instead.
+func (*PendingResponse) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PendingResponse) GetPacketId() string {
+	if x != nil {
+		return x.PacketId
+	}
+	return ""
+}
+
+func (x *PendingResponse) GetMethodId() string {
+	if x != nil {
+		return x.MethodId
+	}
+	return ""
+}
+
+func (x *PendingResponse) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *PendingResponse) GetAge() *durationpb.Duration {
+	if x != nil {
+		return x.Age
+	}
+	return nil
+}
+
+type Error struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	MethodId      string                 `protobuf:"bytes,2,opt,name=method_id,json=methodId,proto3" json:"method_id,omitempty"`
+	PacketId      string                 `protobuf:"bytes,3,opt,name=packet_id,json=packetId,proto3" json:"packet_id,omitempty"` // empty for errors not tied to a request, e.g. a worker exiting
+	WorkerId      string                 `protobuf:"bytes,4,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	Code          string                 `protobuf:"bytes,5,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Error) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Error) GetMethodId() string {
+	if x != nil {
+		return x.MethodId
+	}
+	return ""
+}
+
+func (x *Error) GetPacketId() string {
+	if x != nil {
+		return x.PacketId
+	}
+	return ""
+}
+
+func (x *Error) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+func (x *Error) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type ListPoolsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPoolsRequest) Reset() {
+	*x = ListPoolsRequest{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPoolsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPoolsRequest) ProtoMessage() {}
+
+func (x *ListPoolsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPoolsRequest.ProtoReflect.Descriptor instead.
+func (*ListPoolsRequest) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{4}
+}
+
+type ListPoolsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pools         []*Pool                `protobuf:"bytes,1,rep,name=pools,proto3" json:"pools,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPoolsResponse) Reset() {
+	*x = ListPoolsResponse{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPoolsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPoolsResponse) ProtoMessage() {}
+
+func (x *ListPoolsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPoolsResponse.ProtoReflect.Descriptor instead.
+func (*ListPoolsResponse) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListPoolsResponse) GetPools() []*Pool {
+	if x != nil {
+		return x.Pools
+	}
+	return nil
+}
+
+type ListWorkersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MethodId      string                 `protobuf:"bytes,1,opt,name=method_id,json=methodId,proto3" json:"method_id,omitempty"` // empty lists the workers of every pool
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListWorkersRequest) Reset() {
+	*x = ListWorkersRequest{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListWorkersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListWorkersRequest) ProtoMessage() {}
+
+func (x *ListWorkersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListWorkersRequest.ProtoReflect.Descriptor instead.
+func (*ListWorkersRequest) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListWorkersRequest) GetMethodId() string {
+	if x != nil {
+		return x.MethodId
+	}
+	return ""
+}
+
+type ListWorkersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Workers       []*Worker              `protobuf:"bytes,1,rep,name=workers,proto3" json:"workers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListWorkersResponse) Reset() {
+	*x = ListWorkersResponse{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListWorkersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListWorkersResponse) ProtoMessage() {}
+
+func (x *ListWorkersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListWorkersResponse.ProtoReflect.Descriptor instead.
+func (*ListWorkersResponse) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListWorkersResponse) GetWorkers() []*Worker {
+	if x != nil {
+		return x.Workers
+	}
+	return nil
+}
+
+type ListPendingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPendingRequest) Reset() {
+	*x = ListPendingRequest{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPendingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPendingRequest) ProtoMessage() {}
+
+func (x *ListPendingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPendingRequest.ProtoReflect.Descriptor instead.
+func (*ListPendingRequest) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{8}
+}
+
+type ListPendingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pending       []*PendingResponse     `protobuf:"bytes,1,rep,name=pending,proto3" json:"pending,omitempty"` // oldest first
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPendingResponse) Reset() {
+	*x = ListPendingResponse{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPendingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPendingResponse) ProtoMessage() {}
+
+func (x *ListPendingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPendingResponse.ProtoReflect.Descriptor instead.
+func (*ListPendingResponse) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListPendingResponse) GetPending() []*PendingResponse {
+	if x != nil {
+		return x.Pending
+	}
+	return nil
+}
+
+type RecentErrorsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Limit         int32                  `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"` // 0 returns every error kept
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecentErrorsRequest) Reset() {
+	*x = RecentErrorsRequest{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecentErrorsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecentErrorsRequest) ProtoMessage() {}
+
+func (x *RecentErrorsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecentErrorsRequest.ProtoReflect.Descriptor instead.
+func (*RecentErrorsRequest) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RecentErrorsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type RecentErrorsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Errors        []*Error               `protobuf:"bytes,1,rep,name=errors,proto3" json:"errors,omitempty"` // most recent first
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecentErrorsResponse) Reset() {
+	*x = RecentErrorsResponse{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecentErrorsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecentErrorsResponse) ProtoMessage() {}
+
+func (x *RecentErrorsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecentErrorsResponse.ProtoReflect.Descriptor instead.
+func (*RecentErrorsResponse) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RecentErrorsResponse) GetErrors() []*Error {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+type ScaleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MethodId      string                 `protobuf:"bytes,1,opt,name=method_id,json=methodId,proto3" json:"method_id,omitempty"`
+	Replicas      int32                  `protobuf:"varint,2,opt,name=replicas,proto3" json:"replicas,omitempty"`
+	Autoscale     bool                   `protobuf:"varint,3,opt,name=autoscale,proto3" json:"autoscale,omitempty"` // lets the autoscaler size the pool again instead, replicas is ignored
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScaleRequest) Reset() {
+	*x = ScaleRequest{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScaleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScaleRequest) ProtoMessage() {}
+
+func (x *ScaleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScaleRequest.ProtoReflect.Descriptor instead.
+func (*ScaleRequest) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ScaleRequest) GetMethodId() string {
+	if x != nil {
+		return x.MethodId
+	}
+	return ""
+}
+
+func (x *ScaleRequest) GetReplicas() int32 {
+	if x != nil {
+		return x.Replicas
+	}
+	return 0
+}
+
+func (x *ScaleRequest) GetAutoscale() bool {
+	if x != nil {
+		return x.Autoscale
+	}
+	return false
+}
+
+type ScaleResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Workers       int32                  `protobuf:"varint,1,opt,name=workers,proto3" json:"workers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScaleResponse) Reset() {
+	*x = ScaleResponse{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScaleResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScaleResponse) ProtoMessage() {}
+
+func (x *ScaleResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScaleResponse.ProtoReflect.Descriptor instead.
+func (*ScaleResponse) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ScaleResponse) GetWorkers() int32 {
+	if x != nil {
+		return x.Workers
+	}
+	return 0
+}
+
+type RestartRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MethodId      string                 `protobuf:"bytes,1,opt,name=method_id,json=methodId,proto3" json:"method_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestartRequest) Reset() {
+	*x = RestartRequest{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestartRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestartRequest) ProtoMessage() {}
+
+func (x *RestartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestartRequest.ProtoReflect.Descriptor instead.
+func (*RestartRequest) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RestartRequest) GetMethodId() string {
+	if x != nil {
+		return x.MethodId
+	}
+	return ""
+}
+
+type RestartResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestartResponse) Reset() {
+	*x = RestartResponse{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestartResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestartResponse) ProtoMessage() {}
+
+func (x *RestartResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestartResponse.ProtoReflect.Descriptor instead.
+func (*RestartResponse) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{15}
+}
+
+type WorkerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkerId      string                 `protobuf:"bytes,1,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorkerRequest) Reset() {
+	*x = WorkerRequest{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkerRequest) ProtoMessage() {}
+
+func (x *WorkerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkerRequest.ProtoReflect.Descriptor instead.
+func (*WorkerRequest) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *WorkerRequest) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+type WorkerResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorkerResponse) Reset() {
+	*x = WorkerResponse{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkerResponse) ProtoMessage() {}
+
+func (x *WorkerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkerResponse.ProtoReflect.Descriptor instead.
+func (*WorkerResponse) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{17}
+}
+
+type Route struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FullMethod    string                 `protobuf:"bytes,1,opt,name=full_method,json=fullMethod,proto3" json:"full_method,omitempty"` // e.g. "/example.BookService/GetBook"
+	MethodId      string                 `protobuf:"bytes,2,opt,name=method_id,json=methodId,proto3" json:"method_id,omitempty"`
+	RequestType   string                 `protobuf:"bytes,3,opt,name=request_type,json=requestType,proto3" json:"request_type,omitempty"` // fully qualified message name, described in ListRoutesResponse.files
+	ResponseType  string                 `protobuf:"bytes,4,opt,name=response_type,json=responseType,proto3" json:"response_type,omitempty"`
+	ClientStreams bool                   `protobuf:"varint,5,opt,name=client_streams,json=clientStreams,proto3" json:"client_streams,omitempty"`
+	ServerStreams bool                   `protobuf:"varint,6,opt,name=server_streams,json=serverStreams,proto3" json:"server_streams,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Route) Reset() {
+	*x = Route{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Route) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Route) ProtoMessage() {}
+
+func (x *Route) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Route.ProtoReflect.Descriptor instead.
+func (*Route) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Route) GetFullMethod() string {
+	if x != nil {
+		return x.FullMethod
+	}
+	return ""
+}
+
+func (x *Route) GetMethodId() string {
+	if x != nil {
+		return x.MethodId
+	}
+	return ""
+}
+
+func (x *Route) GetRequestType() string {
+	if x != nil {
+		return x.RequestType
+	}
+	return ""
+}
+
+func (x *Route) GetResponseType() string {
+	if x != nil {
+		return x.ResponseType
+	}
+	return ""
+}
+
+func (x *Route) GetClientStreams() bool {
+	if x != nil {
+		return x.ClientStreams
+	}
+	return false
+}
+
+func (x *Route) GetServerStreams() bool {
+	if x != nil {
+		return x.ServerStreams
+	}
+	return false
+}
+
+type ListRoutesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRoutesRequest) Reset() {
+	*x = ListRoutesRequest{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRoutesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRoutesRequest) ProtoMessage() {}
+
+func (x *ListRoutesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRoutesRequest.ProtoReflect.Descriptor instead.
+func (*ListRoutesRequest) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{19}
+}
+
+type ListRoutesResponse struct {
+	state         protoimpl.MessageState              `protogen:"open.v1"`
+	Routes        []*Route                            `protobuf:"bytes,1,rep,name=routes,proto3" json:"routes,omitempty"`
+	Files         []*descriptorpb.FileDescriptorProto `protobuf:"bytes,2,rep,name=files,proto3" json:"files,omitempty"` // the files declaring the messages of the routes, and their imports
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRoutesResponse) Reset() {
+	*x = ListRoutesResponse{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRoutesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRoutesResponse) ProtoMessage() {}
+
+func (x *ListRoutesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRoutesResponse.ProtoReflect.Descriptor instead.
+func (*ListRoutesResponse) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ListRoutesResponse) GetRoutes() []*Route {
+	if x != nil {
+		return x.Routes
+	}
+	return nil
+}
+
+func (x *ListRoutesResponse) GetFiles() []*descriptorpb.FileDescriptorProto {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type CallRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FullMethod    string                 `protobuf:"bytes,1,opt,name=full_method,json=fullMethod,proto3" json:"full_method,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"` // the encoded request message
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CallRequest) Reset() {
+	*x = CallRequest{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CallRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CallRequest) ProtoMessage() {}
+
+func (x *CallRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CallRequest.ProtoReflect.Descriptor instead.
+func (*CallRequest) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *CallRequest) GetFullMethod() string {
+	if x != nil {
+		return x.FullMethod
+	}
+	return ""
+}
+
+func (x *CallRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type CallResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PacketId      string                 `protobuf:"bytes,1,opt,name=packet_id,json=packetId,proto3" json:"packet_id,omitempty"`
+	MethodId      string                 `protobuf:"bytes,2,opt,name=method_id,json=methodId,proto3" json:"method_id,omitempty"`
+	Status        *status.Status         `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`   // OK, the status the method answered with or why the request got no answer
+	Payload       []byte                 `protobuf:"bytes,4,opt,name=payload,proto3" json:"payload,omitempty"` // the encoded response message, empty unless the status is OK
+	Hops          []*factory.Hop         `protobuf:"bytes,5,rep,name=hops,proto3" json:"hops,omitempty"`       // the hops of the request, and of the response when there is one
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CallResponse) Reset() {
+	*x = CallResponse{}
+	mi := &file_core_factory_protos_admin_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CallResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CallResponse) ProtoMessage() {}
+
+func (x *CallResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_admin_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CallResponse.ProtoReflect.Descriptor instead.
+func (*CallResponse) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_admin_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *CallResponse) GetPacketId() string {
+	if x != nil {
+		return x.PacketId
+	}
+	return ""
+}
+
+func (x *CallResponse) GetMethodId() string {
+	if x != nil {
+		return x.MethodId
+	}
+	return ""
+}
+
+func (x *CallResponse) GetStatus() *status.Status {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *CallResponse) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *CallResponse) GetHops() []*factory.Hop {
+	if x != nil {
+		return x.Hops
+	}
+	return nil
+}
+
+var File_core_factory_protos_admin_proto protoreflect.FileDescriptor
+
+const file_core_factory_protos_admin_proto_rawDesc = "" +
+	"\n" +
+	"\x1fcore/factory/protos/admin.proto\x12\x05admin\x1a core/factory/protos/packet.proto\x1a google/protobuf/descriptor.proto\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x17google/rpc/status.proto\"\x83\x04\n" +
+	"\x04Pool\x12\x1b\n" +
+	"\tmethod_id\x18\x01 \x01(\tR\bmethodId\x12\x1a\n" +
+	"\bbalancer\x18\x02 \x01(\tR\bbalancer\x123\n" +
+	"\atimeout\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\atimeout\x12\x18\n" +
+	"\aretries\x18\x04 \x01(\x05R\aretries\x12\x18\n" +
+	"\aworkers\x18\x05 \x01(\x05R\aworkers\x12'\n" +
+	"\x0fhealthy_workers\x18\x06 \x01(\x05R\x0ehealthyWorkers\x12\x1b\n" +
+	"\tin_flight\x18\a \x01(\x03R\binFlight\x12\x16\n" +
+	"\x06queued\x18\b \x01(\x05R\x06queued\x12\x18\n" +
+	"\abreaker\x18\t \x01(\tR\abreaker\x12\x16\n" +
+	"\x06binary\x18\n" +
+	" \x01(\tR\x06binary\x123\n" +
+	"\alatency\x18\v \x01(\v2\x19.google.protobuf.DurationR\alatency\x12!\n" +
+	"\fmin_replicas\x18\f \x01(\x05R\vminReplicas\x12!\n" +
+	"\fmax_replicas\x18\r \x01(\x05R\vmaxReplicas\x12\x1a\n" +
+	"\brequests\x18\x0e \x01(\x04R\brequests\x12\x1a\n" +
+	"\bfailures\x18\x0f \x01(\x04R\bfailures\x12\x16\n" +
+	"\x06pinned\x18\x10 \x01(\bR\x06pinned\"\x97\x02\n" +
+	"\x06Worker\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\tmethod_id\x18\x02 \x01(\tR\bmethodId\x12\x10\n" +
+	"\x03pid\x18\x03 \x01(\x05R\x03pid\x12\x16\n" +
+	"\x06origin\x18\x04 \x01(\tR\x06origin\x121\n" +
+	"\x06uptime\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x06uptime\x12\x1b\n" +
+	"\tin_flight\x18\x06 \x01(\x03R\binFlight\x12\x18\n" +
+	"\ahealthy\x18\a \x01(\bR\ahealthy\x12\x1a\n" +
+	"\bdraining\x18\b \x01(\bR\bdraining\x12\x18\n" +
+	"\aversion\x18\t \x01(\tR\aversion\x12\x16\n" +
+	"\x06binary\x18\n" +
+	" \x01(\tR\x06binary\"\x90\x01\n" +
+	"\x0fPendingResponse\x12\x1b\n" +
+	"\tpacket_id\x18\x01 \x01(\tR\bpacketId\x12\x1b\n" +
+	"\tmethod_id\x18\x02 \x01(\tR\bmethodId\x12\x16\n" +
+	"\x06target\x18\x03 \x01(\tR\x06target\x12+\n" +
+	"\x03age\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x03age\"\xbc\x01\n" +
+	"\x05Error\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x1b\n" +
+	"\tmethod_id\x18\x02 \x01(\tR\bmethodId\x12\x1b\n" +
+	"\tpacket_id\x18\x03 \x01(\tR\bpacketId\x12\x1b\n" +
+	"\tworker_id\x18\x04 \x01(\tR\bworkerId\x12\x12\n" +
+	"\x04code\x18\x05 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\"\x12\n" +
+	"\x10ListPoolsRequest\"6\n" +
+	"\x11ListPoolsResponse\x12!\n" +
+	"\x05pools\x18\x01 \x03(\v2\v.admin.PoolR\x05pools\"1\n" +
+	"\x12ListWorkersRequest\x12\x1b\n" +
+	"\tmethod_id\x18\x01 \x01(\tR\bmethodId\">\n" +
+	"\x13ListWorkersResponse\x12'\n" +
+	"\aworkers\x18\x01 \x03(\v2\r.admin.WorkerR\aworkers\"\x14\n" +
+	"\x12ListPendingRequest\"G\n" +
+	"\x13ListPendingResponse\x120\n" +
+	"\apending\x18\x01 \x03(\v2\x16.admin.PendingResponseR\apending\"+\n" +
+	"\x13RecentErrorsRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\"<\n" +
+	"\x14RecentErrorsResponse\x12$\n" +
+	"\x06errors\x18\x01 \x03(\v2\f.admin.ErrorR\x06errors\"e\n" +
+	"\fScaleRequest\x12\x1b\n" +
+	"\tmethod_id\x18\x01 \x01(\tR\bmethodId\x12\x1a\n" +
+	"\breplicas\x18\x02 \x01(\x05R\breplicas\x12\x1c\n" +
+	"\tautoscale\x18\x03 \x01(\bR\tautoscale\")\n" +
+	"\rScaleResponse\x12\x18\n" +
+	"\aworkers\x18\x01 \x01(\x05R\aworkers\"-\n" +
+	"\x0eRestartRequest\x12\x1b\n" +
+	"\tmethod_id\x18\x01 \x01(\tR\bmethodId\"\x11\n" +
+	"\x0fRestartResponse\",\n" +
+	"\rWorkerRequest\x12\x1b\n" +
+	"\tworker_id\x18\x01 \x01(\tR\bworkerId\"\x10\n" +
+	"\x0eWorkerResponse\"\xdb\x01\n" +
+	"\x05Route\x12\x1f\n" +
+	"\vfull_method\x18\x01 \x01(\tR\n" +
+	"fullMethod\x12\x1b\n" +
+	"\tmethod_id\x18\x02 \x01(\tR\bmethodId\x12!\n" +
+	"\frequest_type\x18\x03 \x01(\tR\vrequestType\x12#\n" +
+	"\rresponse_type\x18\x04 \x01(\tR\fresponseType\x12%\n" +
+	"\x0eclient_streams\x18\x05 \x01(\bR\rclientStreams\x12%\n" +
+	"\x0eserver_streams\x18\x06 \x01(\bR\rserverStreams\"\x13\n" +
+	"\x11ListRoutesRequest\"v\n" +
+	"\x12ListRoutesResponse\x12$\n" +
+	"\x06routes\x18\x01 \x03(\v2\f.admin.RouteR\x06routes\x12:\n" +
+	"\x05files\x18\x02 \x03(\v2$.google.protobuf.FileDescriptorProtoR\x05files\"H\n" +
+	"\vCallRequest\x12\x1f\n" +
+	"\vfull_method\x18\x01 \x01(\tR\n" +
+	"fullMethod\x12\x18\n" +
+	"\apayload\x18\x02 \x01(\fR\apayload\"\xb0\x01\n" +
+	"\fCallResponse\x12\x1b\n" +
+	"\tpacket_id\x18\x01 \x01(\tR\bpacketId\x12\x1b\n" +
+	"\tmethod_id\x18\x02 \x01(\tR\bmethodId\x12*\n" +
+	"\x06status\x18\x03 \x01(\v2\x12.google.rpc.StatusR\x06status\x12\x18\n" +
+	"\apayload\x18\x04 \x01(\fR\apayload\x12 \n" +
+	"\x04hops\x18\x05 \x03(\v2\f.factory.HopR\x04hops2\xf5\x04\n" +
+	"\x05Admin\x12>\n" +
+	"\tListPools\x12\x17.admin.ListPoolsRequest\x1a\x18.admin.ListPoolsResponse\x12D\n" +
+	"\vListWorkers\x12\x19.admin.ListWorkersRequest\x1a\x1a.admin.ListWorkersResponse\x12D\n" +
+	"\vListPending\x12\x19.admin.ListPendingRequest\x1a\x1a.admin.ListPendingResponse\x12G\n" +
+	"\fRecentErrors\x12\x1a.admin.RecentErrorsRequest\x1a\x1b.admin.RecentErrorsResponse\x122\n" +
+	"\x05Scale\x12\x13.admin.ScaleRequest\x1a\x14.admin.ScaleResponse\x128\n" +
+	"\aRestart\x12\x15.admin.RestartRequest\x1a\x16.admin.RestartResponse\x12:\n" +
+	"\vDrainWorker\x12\x14.admin.WorkerRequest\x1a\x15.admin.WorkerResponse\x129\n" +
+	"\n" +
+	"KillWorker\x12\x14.admin.WorkerRequest\x1a\x15.admin.WorkerResponse\x12A\n" +
+	"\n" +
+	"ListRoutes\x12\x18.admin.ListRoutesRequest\x1a\x19.admin.ListRoutesResponse\x12/\n" +
+	"\x04Call\x12\x12.admin.CallRequest\x1a\x13.admin.CallResponseB3Z1github.com/bsmider/pipes/core/factory/admin;adminb\x06proto3"
+
+var (
+	file_core_factory_protos_admin_proto_rawDescOnce sync.Once
+	file_core_factory_protos_admin_proto_rawDescData []byte
+)
+
+func file_core_factory_protos_admin_proto_rawDescGZIP() []byte {
+	file_core_factory_protos_admin_proto_rawDescOnce.Do(func() {
+		file_core_factory_protos_admin_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_core_factory_protos_admin_proto_rawDesc), len(file_core_factory_protos_admin_proto_rawDesc)))
+	})
+	return file_core_factory_protos_admin_proto_rawDescData
+}
+
+var file_core_factory_protos_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 23)
+var file_core_factory_protos_admin_proto_goTypes = []any{
+	(*Pool)(nil),                             // 0: admin.Pool
+	(*Worker)(nil),                           // 1: admin.Worker
+	(*PendingResponse)(nil),                  // 2: admin.PendingResponse
+	(*Error)(nil),                            // 3: admin.Error
+	(*ListPoolsRequest)(nil),                 // 4: admin.ListPoolsRequest
+	(*ListPoolsResponse)(nil),                // 5: admin.ListPoolsResponse
+	(*ListWorkersRequest)(nil),               // 6: admin.ListWorkersRequest
+	(*ListWorkersResponse)(nil),              // 7: admin.ListWorkersResponse
+	(*ListPendingRequest)(nil),               // 8: admin.ListPendingRequest
+	(*ListPendingResponse)(nil),              // 9: admin.ListPendingResponse
+	(*RecentErrorsRequest)(nil),              // 10: admin.RecentErrorsRequest
+	(*RecentErrorsResponse)(nil),             // 11: admin.RecentErrorsResponse
+	(*ScaleRequest)(nil),                     // 12: admin.ScaleRequest
+	(*ScaleResponse)(nil),                    // 13: admin.ScaleResponse
+	(*RestartRequest)(nil),                   // 14: admin.RestartRequest
+	(*RestartResponse)(nil),                  // 15: admin.RestartResponse
+	(*WorkerRequest)(nil),                    // 16: admin.WorkerRequest
+	(*WorkerResponse)(nil),                   // 17: admin.WorkerResponse
+	(*Route)(nil),                            // 18: admin.Route
+	(*ListRoutesRequest)(nil),                // 19: admin.ListRoutesRequest
+	(*ListRoutesResponse)(nil),               // 20: admin.ListRoutesResponse
+	(*CallRequest)(nil),                      // 21: admin.CallRequest
+	(*CallResponse)(nil),                     // 22: admin.CallResponse
+	(*durationpb.Duration)(nil),              // 23: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil),            // 24: google.protobuf.Timestamp
+	(*descriptorpb.FileDescriptorProto)(nil), // 25: google.protobuf.FileDescriptorProto
+	(*status.Status)(nil),                    // 26: google.rpc.Status
+	(*factory.Hop)(nil),                      // 27: factory.Hop
+}
+var file_core_factory_protos_admin_proto_depIdxs = []int32{
+	23, // 0: admin.Pool.timeout:type_name -> google.protobuf.Duration
+	23, // 1: admin.Pool.latency:type_name -> google.protobuf.Duration
+	23, // 2: admin.Worker.uptime:type_name -> google.protobuf.Duration
+	23, // 3: admin.PendingResponse.age:type_name -> google.protobuf.Duration
+	24, // 4: admin.Error.time:type_name -> google.protobuf.Timestamp
+	0,  // 5: admin.ListPoolsResponse.pools:type_name -> admin.Pool
+	1,  // 6: admin.ListWorkersResponse.workers:type_name -> admin.Worker
+	2,  // 7: admin.ListPendingResponse.pending:type_name -> admin.PendingResponse
+	3,  // 8: admin.RecentErrorsResponse.errors:type_name -> admin.Error
+	18, // 9: admin.ListRoutesResponse.routes:type_name -> admin.Route
+	25, // 10: admin.ListRoutesResponse.files:type_name -> google.protobuf.FileDescriptorProto
+	26, // 11: admin.CallResponse.status:type_name -> google.rpc.Status
+	27, // 12: admin.CallResponse.hops:type_name -> factory.Hop
+	4,  // 13: admin.Admin.ListPools:input_type -> admin.ListPoolsRequest
+	6,  // 14: admin.Admin.ListWorkers:input_type -> admin.ListWorkersRequest
+	8,  // 15: admin.Admin.ListPending:input_type -> admin.ListPendingRequest
+	10, // 16: admin.Admin.RecentErrors:input_type -> admin.RecentErrorsRequest
+	12, // 17: admin.Admin.Scale:input_type -> admin.ScaleRequest
+	14, // 18: admin.Admin.Restart:input_type -> admin.RestartRequest
+	16, // 19: admin.Admin.DrainWorker:input_type -> admin.WorkerRequest
+	16, // 20: admin.Admin.KillWorker:input_type -> admin.WorkerRequest
+	19, // 21: admin.Admin.ListRoutes:input_type -> admin.ListRoutesRequest
+	21, // 22: admin.Admin.Call:input_type -> admin.CallRequest
+	5,  // 23: admin.Admin.ListPools:output_type -> admin.ListPoolsResponse
+	7,  // 24: admin.Admin.ListWorkers:output_type -> admin.ListWorkersResponse
+	9,  // 25: admin.Admin.ListPending:output_type -> admin.ListPendingResponse
+	11, // 26: admin.Admin.RecentErrors:output_type -> admin.RecentErrorsResponse
+	13, // 27: admin.Admin.Scale:output_type -> admin.ScaleResponse
+	15, // 28: admin.Admin.Restart:output_type -> admin.RestartResponse
+	17, // 29: admin.Admin.DrainWorker:output_type -> admin.WorkerResponse
+	17, // 30: admin.Admin.KillWorker:output_type -> admin.WorkerResponse
+	20, // 31: admin.Admin.ListRoutes:output_type -> admin.ListRoutesResponse
+	22, // 32: admin.Admin.Call:output_type -> admin.CallResponse
+	23, // [23:33] is the sub-list for method output_type
+	13, // [13:23] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_core_factory_protos_admin_proto_init() }
+func file_core_factory_protos_admin_proto_init() {
+	if File_core_factory_protos_admin_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_core_factory_protos_admin_proto_rawDesc), len(file_core_factory_protos_admin_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   23,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_core_factory_protos_admin_proto_goTypes,
+		DependencyIndexes: file_core_factory_protos_admin_proto_depIdxs,
+		MessageInfos:      file_core_factory_protos_admin_proto_msgTypes,
+	}.Build()
+	File_core_factory_protos_admin_proto = out.File
+	file_core_factory_protos_admin_proto_goTypes = nil
+	file_core_factory_protos_admin_proto_depIdxs = nil
+}
@@ -77,6 +77,11 @@ func generateMethodFile(servicePath string, method utils.ServiceMethod, parsed *
 	}
 	source := string(sourceBytes)
 
+	options, err := parseMethodOptions(method.Options)
+	if err != nil {
+		return nil, err
+	}
+
 	// Extract and transform the function body
 	transformedBody, err := transformMethodBody(servicePath, source, method, parsed, methodNames, config)
 	if err != nil {
@@ -118,6 +123,15 @@ func generateMethodFile(servicePath string, method utils.ServiceMethod, parsed *
 		ShortID:      shortID,
 		FullDirPath:  outputDir,
 		RelativePath: relPath, // e.g. "example/book_service/get_book/main.go"
+
+		ServiceName:     parsed.ServiceName,
+		ProtoImportPath: parsed.ProtoImportPath,
+		ProtoPackage:    parsed.ProtoPackage,
+		ReqType:         method.ReqType,
+		RespType:        method.RespType,
+		ClientStreams:   method.Kind == utils.MethodKindClientStreaming || method.Kind == utils.MethodKindBidiStreaming,
+		ServerStreams:   method.Kind == utils.MethodKindServerStreaming || method.Kind == utils.MethodKindBidiStreaming,
+		Options:         options,
 	}, nil
 }
 
@@ -166,6 +180,14 @@ func transformMethodBody(servicePath string, source string, method utils.Service
 			continue
 		}
 
+		// Streams can't be rewritten into a single processes.Call
+		if targetMethod.IsStream() {
+			return "", fmt.Errorf("%s calls streaming method %s, open the stream with processes.OpenStream instead", method.Name, call.MethodName)
+		}
+		if call.ReqArg == "" {
+			continue
+		}
+
 		// Build the replacement call
 		replacement := buildProcessesCall(call, targetMethod, parsed, config)
 
@@ -219,17 +241,16 @@ func findRPCCallsInBody(body *ast.BlockStmt, method utils.ServiceMethod, methodN
 			return true
 		}
 
-		// Extract call arguments
-		if len(callExpr.Args) < 2 {
-			return true
-		}
-
 		call := utils.RPCCall{
 			MethodName: methodName,
 			CallStart:  fset.Position(callExpr.Pos()).Offset,
 			CallEnd:    fset.Position(callExpr.End()).Offset,
-			CtxArg:     astExprToString(callExpr.Args[0], fset),
-			ReqArg:     astExprToString(callExpr.Args[1], fset),
+		}
+
+		// Extract call arguments, calls to streaming methods are reported without them
+		if len(callExpr.Args) >= 2 {
+			call.CtxArg = astExprToString(callExpr.Args[0], fset)
+			call.ReqArg = astExprToString(callExpr.Args[1], fset)
 		}
 
 		calls = append(calls, call)
@@ -246,6 +267,26 @@ func astExprToString(expr ast.Expr, fset *token.FileSet) string {
 	return buf.String()
 }
 
+// selectedNames returns the identifiers that selectors in body select from, such as
+// context in context.Background(). Package names and variables are not told apart,
+// but names in strings and comments are not counted. A body that does not parse selects nothing.
+func selectedNames(body string) map[string]bool {
+	names := make(map[string]bool)
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package main\nfunc _() {\n"+body+"\n}\n", 0)
+	if err != nil {
+		return names
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if selector, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok {
+				names[ident.Name] = true
+			}
+		}
+		return true
+	})
+	return names
+}
+
 // buildProcessesCall builds the processes.Call replacement string
 // Uses the unique method ID to ensure correct routing even with same method names across services
 func buildProcessesCall(call utils.RPCCall, targetMethod *utils.ServiceMethod, parsed *utils.ParsedServiceFile, config CodeGenConfig) string {
@@ -273,9 +314,18 @@ func generateFileContent(method utils.ServiceMethod, parsed *utils.ParsedService
 	buf.WriteString("package main\n\n")
 
 	// Imports
+	// Streaming methods have no context parameter and may not use the package
+	selected := selectedNames(transformedBody)
+	usesContext := !method.IsStream() || selected["context"]
+
 	buf.WriteString("import (\n")
-	buf.WriteString("\t\"context\"\n")
+	if usesContext {
+		buf.WriteString("\t\"context\"\n")
+	}
 	buf.WriteString("\t\"flag\"\n")
+	buf.WriteString("\t\"log\"\n")
+	buf.WriteString("\t\"os\"\n")
+	buf.WriteString("\t\"syscall\"\n")
 	buf.WriteString("\n")
 
 	// Track imported packages to avoid duplicates
@@ -283,6 +333,9 @@ func generateFileContent(method utils.ServiceMethod, parsed *utils.ParsedService
 	imported := map[string]bool{
 		"context": true,
 		"flag":    true,
+		"log":     true,
+		"os":      true,
+		"syscall": true,
 		"github.com/bsmider/pipes/core/factory/processes": true,
 	}
 
@@ -294,7 +347,7 @@ func generateFileContent(method utils.ServiceMethod, parsed *utils.ParsedService
 			continue
 		}
 
-		// Heuristic: check if the package name or alias appears in the transformed body
+		// Heuristic: check if the package name or alias is selected from in the transformed body
 		// For the proto import, we always include it because it's used in the signature
 
 		isProto := imp.Path == parsed.ProtoImportPath
@@ -314,7 +367,7 @@ func generateFileContent(method utils.ServiceMethod, parsed *utils.ParsedService
 				buf.WriteString(fmt.Sprintf("\t\"%s\"\n", imp.Path))
 			}
 			imported[imp.Path] = true
-		} else if strings.Contains(transformedBody, searchName+".") {
+		} else if selected[searchName] {
 			// Include only if used
 			if imp.Name != "" {
 				buf.WriteString(fmt.Sprintf("\t%s \"%s\"\n", imp.Name, imp.Path))
@@ -329,31 +382,83 @@ func generateFileContent(method utils.ServiceMethod, parsed *utils.ParsedService
 	buf.WriteString(")\n\n")
 
 	// Function signature (without receiver)
-	buf.WriteString(fmt.Sprintf("func %s(%s context.Context, %s %s) (%s, error) {",
-		method.Name,
-		method.CtxName,
-		method.ReqName,
-		method.ReqType,
-		method.RespType,
-	))
+	switch method.Kind {
+	case utils.MethodKindServerStreaming:
+		buf.WriteString(fmt.Sprintf("func %s(%s %s, %s %s) error {",
+			method.Name,
+			method.ReqName,
+			method.ReqType,
+			method.StreamName,
+			method.StreamType,
+		))
+	case utils.MethodKindClientStreaming, utils.MethodKindBidiStreaming:
+		buf.WriteString(fmt.Sprintf("func %s(%s %s) error {",
+			method.Name,
+			method.StreamName,
+			method.StreamType,
+		))
+	default:
+		buf.WriteString(fmt.Sprintf("func %s(%s context.Context, %s %s) (%s, error) {",
+			method.Name,
+			method.CtxName,
+			method.ReqName,
+			method.ReqType,
+			method.RespType,
+		))
+	}
 
 	// Function body (already transformed)
 	buf.WriteString(transformedBody)
 	buf.WriteString("}\n\n")
 
 	// Main function
+	methodID := utils.GenerateMethodID(parsed.ProtoImportPath, parsed.ServiceName, method.Name)
 	buf.WriteString("func main() {\n")
 	buf.WriteString("\tnodeID := flag.String(\"id\", \"default-worker\", \"The unique ID for this worker instance\")\n")
+	buf.WriteString("\tmaxConcurrency := flag.Int(\"max-concurrency\", processes.DefaultMaxConcurrency, \"Requests handled at once, further requests are turned away (0 is unlimited)\")\n")
+	buf.WriteString("\torchestratorAddr := flag.String(\"orchestrator\", \"\", \"Address of an orchestrator to dial over mutual TLS, instead of being started by it\")\n")
+	buf.WriteString("\ttlsCert := flag.String(\"tls-cert\", \"worker.pem\", \"The certificate this worker presents to the orchestrator\")\n")
+	buf.WriteString("\ttlsKey := flag.String(\"tls-key\", \"worker-key.pem\", \"The key of the worker certificate\")\n")
+	buf.WriteString("\ttlsCA := flag.String(\"tls-ca\", \"ca.pem\", \"The CA that signed the orchestrator certificate\")\n")
+	buf.WriteString("\tattachSocket := flag.String(\"attach\", \"\", \"Path of the attach socket of a running orchestrator to join, see its -attach-socket flag\")\n")
 	buf.WriteString("\tflag.Parse()\n")
+	buf.WriteString("\tswitch {\n")
+	buf.WriteString("\tcase *orchestratorAddr != \"\":\n")
+	buf.WriteString(fmt.Sprintf("\t\tif err := processes.DialOrchestratorWithCerts(*orchestratorAddr, %q, *tlsCert, *tlsKey, *tlsCA); err != nil {\n", methodID))
+	buf.WriteString("\t\t\tlog.Fatalf(\"[ProcessRunner] %v\", err)\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\tcase *attachSocket != \"\":\n")
+	buf.WriteString(fmt.Sprintf("\t\tif err := processes.AttachOrchestrator(*attachSocket, %q); err != nil {\n", methodID))
+	buf.WriteString("\t\t\tlog.Fatalf(\"[ProcessRunner] %v\", err)\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t}\n")
 	buf.WriteString("\tnode := processes.GetIONode(*nodeID)\n")
+	buf.WriteString("\tnode.SetMaxConcurrency(*maxConcurrency)\n")
 	buf.WriteString("\tnode.Listen()\n")
-	buf.WriteString(fmt.Sprintf("\tprocesses.Handle(%s)\n", method.Name))
-	buf.WriteString("\tselect {}\n")
+	buf.WriteString("\tnode.DrainOnSignal(os.Interrupt, syscall.SIGTERM)\n")
+	buf.WriteString(fmt.Sprintf("\tprocesses.%s(%s)\n", handleFunc(method.Kind), method.Name))
+	buf.WriteString("\n")
+	buf.WriteString("\t// Exit once the node has drained its in-flight requests\n")
+	buf.WriteString("\t<-node.Done()\n")
 	buf.WriteString("}\n")
 
 	return buf.String()
 }
 
+// handleFunc returns the processes function that serves methods of the given kind
+func handleFunc(kind utils.MethodKind) string {
+	switch kind {
+	case utils.MethodKindServerStreaming:
+		return "HandleServerStream"
+	case utils.MethodKindClientStreaming:
+		return "HandleClientStream"
+	case utils.MethodKindBidiStreaming:
+		return "HandleBidiStream"
+	default:
+		return "Handle"
+	}
+}
+
 // QuickGenerate is a convenience function that generates code from a service file
 // using sensible defaults and writes to a directory named after the method
 func QuickGenerate(servicePath string, outputBaseDir string) error {
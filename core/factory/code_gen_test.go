@@ -3,13 +3,18 @@ package factory
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory/utils"
+	"google.golang.org/grpc/codes"
 )
 
 func TestGenerateFromServiceFile(t *testing.T) {
 	// Get the path to the example service file
-	servicePath := "../example/book_service.go"
+	servicePath := "../example/src/book_service.go"
 
 	// Create a temporary output directory
 	tempDir, err := os.MkdirTemp("", "codegen_test_*")
@@ -53,7 +58,7 @@ func TestGenerateFromServiceFile(t *testing.T) {
 	}
 
 	// Verify the unique method ID is included in the processes.Call
-	if !strings.Contains(contentStr, "github.com/bsmider/pipes/core/factory/build/example.BookService.") {
+	if !strings.Contains(contentStr, "github.com/bsmider/pipes/core/example/build/example.BookService.") {
 		t.Error("Generated file should use full unique method ID in processes.Call")
 	}
 
@@ -72,6 +77,14 @@ func TestGenerateFromServiceFile(t *testing.T) {
 		t.Error("Generated file should call processes.Handle(GetBook)")
 	}
 
+	// Verify the worker can dial a remote orchestrator for its own method
+	if !strings.Contains(contentStr, `processes.DialOrchestratorWithCerts(*orchestratorAddr, "github.com/bsmider/pipes/core/example/build/example.BookService.GetBook"`) {
+		t.Error("Generated file should register with a remote orchestrator as GetBook")
+	}
+	if !strings.Contains(contentStr, `processes.AttachOrchestrator(*attachSocket, "github.com/bsmider/pipes/core/example/build/example.BookService.GetBook")`) {
+		t.Error("Generated file should attach to a local orchestrator as GetBook")
+	}
+
 	// Verify the other method was also generated
 	getAuthorPath := filepath.Join(tempDir, "example", "book_service", "get_author_name_from_book_id", "main.go")
 	if _, err := os.Stat(getAuthorPath); os.IsNotExist(err) {
@@ -80,7 +93,7 @@ func TestGenerateFromServiceFile(t *testing.T) {
 }
 
 func TestParsesServiceFile(t *testing.T) {
-	servicePath := "../example/book_service.go"
+	servicePath := "../example/src/book_service.go"
 
 	methods, err := GetServiceMethods(servicePath)
 	if err != nil {
@@ -106,10 +119,173 @@ func TestParsesServiceFile(t *testing.T) {
 }
 
 func TestValidateServiceFile(t *testing.T) {
-	servicePath := "../example/book_service.go"
+	servicePath := "../example/src/book_service.go"
 
 	err := ValidateServiceFile(servicePath)
 	if err != nil {
 		t.Errorf("ValidateServiceFile should pass for valid service: %v", err)
 	}
 }
+
+func TestGenerateStreamingMethod(t *testing.T) {
+	servicePath := "../example/src/book_service.go"
+
+	tempDir, err := os.MkdirTemp("", "codegen_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	methods, err := GenerateFromServiceFile(servicePath, CodeGenConfig{OutputDir: tempDir})
+	if err != nil {
+		t.Fatalf("GenerateFromServiceFile failed: %v", err)
+	}
+
+	// The stream alias should resolve to the message types of the stream
+	var listBooks *MethodInfo
+	for i := range methods {
+		if methods[i].MethodName == "ListBooks" {
+			listBooks = &methods[i]
+		}
+	}
+	if listBooks == nil {
+		t.Fatal("Expected ListBooks method to be generated")
+	}
+	if listBooks.ClientStreams || !listBooks.ServerStreams {
+		t.Errorf("Expected ListBooks to be server streaming, got client=%v server=%v", listBooks.ClientStreams, listBooks.ServerStreams)
+	}
+	if listBooks.ReqType != "*example.ListBooksRequest" || listBooks.RespType != "*example.Book" {
+		t.Errorf("Unexpected ListBooks types %s -> %s", listBooks.ReqType, listBooks.RespType)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "example", "book_service", "list_books", "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+	contentStr := string(content)
+
+	if !strings.Contains(contentStr, "func ListBooks(req *example.ListBooksRequest, stream example.BookService_ListBooksServer) error {") {
+		t.Error("Generated file should keep the streaming signature")
+	}
+	if !strings.Contains(contentStr, "processes.HandleServerStream(ListBooks)") {
+		t.Error("Generated file should call processes.HandleServerStream(ListBooks)")
+	}
+}
+
+func TestPoolDirectives(t *testing.T) {
+	servicePath := "../example/src/book_service.go"
+
+	tempDir, err := os.MkdirTemp("", "codegen_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	methods, err := GenerateFromServiceFile(servicePath, CodeGenConfig{OutputDir: tempDir})
+	if err != nil {
+		t.Fatalf("GenerateFromServiceFile failed: %v", err)
+	}
+
+	for _, method := range methods {
+		switch method.MethodName {
+		case "GetAuthorNameFromBookId":
+			options := method.Options
+			if options.Timeout != 500*time.Millisecond || options.Retries == nil || *options.Retries != 0 || options.MaxConcurrency != 16 || options.CallerMaxInFlight != 8 {
+				t.Errorf("Unexpected options for %s: %+v", method.MethodName, options)
+			}
+		case "GetBook":
+			if !reflect.DeepEqual(method.Options, MethodOptions{}) {
+				t.Errorf("Expected default options for GetBook, got %+v", method.Options)
+			}
+		}
+	}
+
+	if _, err := parseMethodOptions(map[string]string{"retry": "1"}); err == nil {
+		t.Error("Expected unknown pool settings to be rejected")
+	}
+
+	options, err := parseMethodOptions(map[string]string{"retry-codes": "unavailable,ABORTED", "idempotent": "false"})
+	if err != nil {
+		t.Fatalf("parseMethodOptions failed: %v", err)
+	}
+	if !reflect.DeepEqual(options.RetryCodes, []codes.Code{codes.Unavailable, codes.Aborted}) || !options.NonIdempotent {
+		t.Errorf("Unexpected retry options: %+v", options)
+	}
+	options, err = parseMethodOptions(map[string]string{"target-latency": "250ms"})
+	if err != nil || options.TargetLatency != 250*time.Millisecond {
+		t.Errorf("Unexpected target latency: %v, %v", options.TargetLatency, err)
+	}
+	options, err = parseMethodOptions(map[string]string{"replicas": "0"})
+	if err != nil || options.Replicas == nil || *options.Replicas != 0 {
+		t.Errorf("Unexpected replicas: %v, %v", options.Replicas, err)
+	}
+	if _, err := parseMethodOptions(map[string]string{"replicas": "0", "max-replicas": "4"}); err == nil {
+		t.Error("Expected max-replicas with replicas=0 to be rejected")
+	}
+	if _, err := parseMethodOptions(map[string]string{"retry-codes": "UNAVAILABLE,NOPE"}); err == nil {
+		t.Error("Expected unknown status codes to be rejected")
+	}
+	if _, err := parseMethodOptions(map[string]string{"hedge-delay": "50ms"}); err == nil {
+		t.Error("Expected hedge-delay without hedge-attempts to be rejected")
+	}
+	if _, err := parseMethodOptions(map[string]string{"breaker": "off", "breaker-open": "10s"}); err == nil {
+		t.Error("Expected breaker settings with breaker=off to be rejected")
+	}
+}
+
+func TestStreamingMethodContextImport(t *testing.T) {
+	method := utils.ServiceMethod{
+		Name:       "ListBooks",
+		Kind:       utils.MethodKindServerStreaming,
+		ReqName:    "req",
+		ReqType:    "*example.ListBooksRequest",
+		StreamName: "stream",
+		StreamType: "example.BookService_ListBooksServer",
+	}
+	parsed := &utils.ParsedServiceFile{
+		Imports: []utils.ImportInfo{
+			{Path: "context"},
+			{Path: "strings"},
+			{Path: "github.com/bsmider/pipes/core/example/build/example"},
+		},
+		ProtoImportPath: "github.com/bsmider/pipes/core/example/build/example",
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantContext bool
+		wantStrings bool
+	}{
+		{
+			name:        "selector on the context package",
+			body:        "ctx, cancel := context.WithCancel(stream.Context())\n\tdefer cancel()\n\t_ = ctx\n\treturn nil",
+			wantContext: true,
+		},
+		{
+			name: "context only in a string and a comment",
+			body: "// no context. here\n\treturn stream.Send(&example.Book{Title: \"context.\"})",
+		},
+		{
+			name: "method named like the package",
+			body: "_ = stream.Context()\n\treturn nil",
+		},
+		{
+			name:        "other package selected from",
+			body:        "return stream.Send(&example.Book{Title: strings.ToUpper(req.AuthorId)})",
+			wantStrings: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := generateFileContent(method, parsed, tt.body, DefaultCodeGenConfig())
+			if got := strings.Contains(content, "\t\"context\"\n"); got != tt.wantContext {
+				t.Errorf("context imported = %v, want %v\n%s", got, tt.wantContext, content)
+			}
+			if got := strings.Contains(content, "\t\"strings\"\n"); got != tt.wantStrings {
+				t.Errorf("strings imported = %v, want %v\n%s", got, tt.wantStrings, content)
+			}
+		})
+	}
+}
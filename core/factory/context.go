@@ -74,24 +74,31 @@ func UpdateContext(ctx context.Context, newContext *Context) error {
 // ToGoContext converts the Proto Context into a Go context.Context.
 // It preserves the deadline, trace ID, and hop history.
 func (ctx *Context) ToGoContext() (context.Context, context.CancelFunc) {
-	// 1. Start with Background
-	goCtx := context.Background()
+	return ctx.ToGoContextFrom(context.Background())
+}
+
+// ToGoContextFrom is ToGoContext derived from parent,
+// so cancelling parent also cancels the returned context.
+func (ctx *Context) ToGoContextFrom(parent context.Context) (context.Context, context.CancelFunc) {
+	// 1. Start with the parent
+	goCtx := parent
 
 	// 2. Handle Deadline
 	// Note: 'fctx' is the pointer to the Context struct
+	var cancel context.CancelFunc
 	if ctx.Deadline != nil {
 		deadline := ctx.Deadline.AsTime()
-		goCtx, _ = context.WithDeadline(goCtx, deadline)
+		goCtx, cancel = context.WithDeadline(goCtx, deadline)
 	} else {
 		// Default fallback if no deadline is provided in the packet
-		goCtx, _ = context.WithTimeout(goCtx, defaultTimeout)
+		goCtx, cancel = context.WithTimeout(goCtx, defaultTimeout)
 	}
 
 	// store the factory context
 	goCtx = context.WithValue(goCtx, protoContexWrappertKey, &ContextWrapper{ctx: ctx})
 
 	// Return the wrapped context and the cancel function
-	return context.WithCancel(goCtx)
+	return goCtx, cancel
 }
 
 // FromGoContext populates the receiver with values extracted from a standard context.
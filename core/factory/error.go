@@ -1,6 +1,7 @@
 package factory
 
 import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	pstatus "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -46,3 +47,29 @@ func (m *Error) FromGoError(err error) *Error {
 	m.Status = status.New(codes.Internal, err.Error()).Proto()
 	return m
 }
+
+// ErrorDomain is the ErrorInfo domain of errors raised by pipes itself
+const ErrorDomain = "pipes"
+
+// ReasonNotProcessed marks a status sent by a worker that turned a request away
+// without running it, e.g. while draining. Such requests are safe to send elsewhere.
+const ReasonNotProcessed = "NOT_PROCESSED"
+
+// MarkNotProcessed attaches the ReasonNotProcessed ErrorInfo to st
+func MarkNotProcessed(st *status.Status) *status.Status {
+	marked, err := st.WithDetails(&errdetails.ErrorInfo{Reason: ReasonNotProcessed, Domain: ErrorDomain})
+	if err != nil {
+		return st
+	}
+	return marked
+}
+
+// NotProcessed reports whether st was marked with MarkNotProcessed
+func NotProcessed(st *status.Status) bool {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == ErrorDomain && info.Reason == ReasonNotProcessed {
+			return true
+		}
+	}
+	return false
+}
@@ -63,6 +63,9 @@ func GenerateDockerfile(methods []MethodInfo, config CodeGenConfig) error {
 	}
 	buf.WriteString("COPY --from=builder /orchestrator .\n\n")
 
+	// gRPC and HTTP/JSON ingress
+	buf.WriteString("EXPOSE 50051 8080\n\n")
+
 	// Run orchestrator
 	buf.WriteString("# Run orchestrator\n")
 	buf.WriteString("CMD [\"./orchestrator\"]\n")
@@ -5,10 +5,86 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
+// writeMethodOptions emits the assignments overriding the default pool options of a method
+func writeMethodOptions(buf *bytes.Buffer, methodName string, options MethodOptions) {
+	if options.Timeout > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Timeout = %s\n", durationLiteral(options.Timeout)))
+	}
+	if options.Retries != nil {
+		buf.WriteString(fmt.Sprintf("\toptions.Retries = %d\n", *options.Retries))
+	}
+	if options.Replicas != nil {
+		buf.WriteString(fmt.Sprintf("\toptions.Replicas = %d\n", *options.Replicas))
+	}
+	if options.MaxReplicas > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.MaxReplicas = %d\n", options.MaxReplicas))
+	}
+	if options.TargetLatency > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.TargetLatency = %s\n", durationLiteral(options.TargetLatency)))
+	}
+	if options.MaxConcurrency > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.MaxConcurrency = %d\n", options.MaxConcurrency))
+	}
+	if options.Balancer != "" {
+		directive := fmt.Sprintf("//pipes:pool balancer=%s directive of %s", options.Balancer, methodName)
+		buf.WriteString(fmt.Sprintf("\toptions.Balancer = newBalancer(%q, %q)\n", options.Balancer, directive))
+	}
+	if options.RetryCodes != nil {
+		buf.WriteString(fmt.Sprintf("\toptions.Retry.RetryableCodes = %s\n", codesLiteral(options.RetryCodes)))
+	}
+	if options.InitialBackoff > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Retry.InitialBackoff = %s\n", durationLiteral(options.InitialBackoff)))
+	}
+	if options.MaxBackoff > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Retry.MaxBackoff = %s\n", durationLiteral(options.MaxBackoff)))
+	}
+	if options.Deadline > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Retry.Deadline = %s\n", durationLiteral(options.Deadline)))
+	}
+	if options.NonIdempotent {
+		buf.WriteString("\toptions.Retry.NonIdempotent = true\n")
+	}
+	if options.HedgeAttempts > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Hedging = orchestrator.DefaultHedgingPolicy(%d)\n", options.HedgeAttempts))
+	}
+	if options.HedgeDelay > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Hedging.Delay = %s\n", durationLiteral(options.HedgeDelay)))
+	}
+	if options.BreakerOff {
+		buf.WriteString("\toptions.Breaker = orchestrator.BreakerPolicy{}\n")
+	}
+	if options.BreakerFailures > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Breaker.ConsecutiveFailures = %d\n", options.BreakerFailures))
+	}
+	if options.BreakerOpen > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Breaker.OpenDuration = %s\n", durationLiteral(options.BreakerOpen)))
+	}
+	if options.Rate > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Admission.Rate = %s\n", strconv.FormatFloat(options.Rate, 'g', -1, 64)))
+	}
+	if options.Burst > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Admission.Burst = %d\n", options.Burst))
+	}
+	if options.MaxInFlight > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Admission.MaxConcurrent = %d\n", options.MaxInFlight))
+	}
+	if options.CallerRate > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Admission.PerCaller.Rate = %s\n", strconv.FormatFloat(options.CallerRate, 'g', -1, 64)))
+	}
+	if options.CallerBurst > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Admission.PerCaller.Burst = %d\n", options.CallerBurst))
+	}
+	if options.CallerMaxInFlight > 0 {
+		buf.WriteString(fmt.Sprintf("\toptions.Admission.PerCaller.MaxConcurrent = %d\n", options.CallerMaxInFlight))
+	}
+}
+
 // GenerateOrchestrator generates the main.go for the orchestrator
-// which spawns all the generated RPC worker processes.
+// which spawns all the generated RPC worker processes and serves
+// the original proto services on the gRPC and HTTP/JSON ingresses.
 func GenerateOrchestrator(methods []MethodInfo, config CodeGenConfig) error {
 	var buf bytes.Buffer
 
@@ -17,28 +93,233 @@ func GenerateOrchestrator(methods []MethodInfo, config CodeGenConfig) error {
 
 	// Imports
 	buf.WriteString("import (\n")
-	buf.WriteString("\t\"github.com/bsmider/pipes/core/factory/orchestrator\"\n")
+	buf.WriteString("\t\"context\"\n")
+	buf.WriteString("\t\"crypto/tls\"\n")
+	buf.WriteString("\t\"flag\"\n")
 	buf.WriteString("\t\"log\"\n")
+	buf.WriteString("\t\"net\"\n")
+	buf.WriteString("\t\"os\"\n")
+	buf.WriteString("\t\"os/signal\"\n")
+	buf.WriteString("\t\"strings\"\n")
+	buf.WriteString("\t\"syscall\"\n")
+	buf.WriteString("\t\"time\"\n")
+	buf.WriteString("\n")
+	buf.WriteString("\t\"github.com/bsmider/pipes/core/factory\"\n")
+	buf.WriteString("\t\"github.com/bsmider/pipes/core/factory/orchestrator\"\n")
+	buf.WriteString("\t\"github.com/bsmider/pipes/core/factory/utils\"\n")
+	for _, method := range methods {
+		if method.Options.RetryCodes != nil {
+			buf.WriteString("\t\"google.golang.org/grpc/codes\"\n")
+			break
+		}
+	}
+
+	// The proto packages are needed for the request/response types of the ingress routes
+	imported := make(map[string]bool)
+	for _, method := range methods {
+		if method.ProtoImportPath == "" || imported[method.ProtoImportPath] {
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("\t%s \"%s\"\n", method.ProtoPackage, method.ProtoImportPath))
+		imported[method.ProtoImportPath] = true
+	}
 	buf.WriteString(")\n\n")
 
 	// Main function
 	buf.WriteString("func main() {\n")
+	buf.WriteString("\tgrpcAddr := flag.String(\"grpc-addr\", \":50051\", \"The address the gRPC ingress listens on\")\n")
+	buf.WriteString("\thttpAddr := flag.String(\"http-addr\", \":8080\", \"The address the HTTP/JSON ingress listens on\")\n")
+	buf.WriteString("\tminReplicas := flag.Int(\"min-replicas\", 1, \"The minimum number of workers per method, 0 serves the methods with remote workers only\")\n")
+	buf.WriteString("\tmaxReplicas := flag.Int(\"max-replicas\", 4, \"The maximum number of workers per method, the pools autoscale in between\")\n")
+	buf.WriteString("\tbalancer := flag.String(\"balancer\", \"round-robin\", \"How workers are picked: round-robin, least-outstanding, power-of-two or weighted\")\n")
+	buf.WriteString("\tworkerAddr := flag.String(\"worker-addr\", \"\", \"The address remote workers dial over mutual TLS, empty only runs local workers\")\n")
+	buf.WriteString("\ttlsCert := flag.String(\"tls-cert\", \"orchestrator.pem\", \"The certificate presented to remote workers\")\n")
+	buf.WriteString("\ttlsKey := flag.String(\"tls-key\", \"orchestrator-key.pem\", \"The key of the orchestrator certificate\")\n")
+	buf.WriteString("\ttlsCA := flag.String(\"tls-ca\", \"ca.pem\", \"The CA remote worker certificates must be signed by\")\n")
+	buf.WriteString("\tattachSocket := flag.String(\"attach-socket\", factory.DefaultAttachSocket(), \"The Unix socket workers launched by hand attach on, empty disables it\")\n")
+	buf.WriteString("\tadminSocket := flag.String(\"admin-socket\", factory.DefaultAdminSocket(), \"The Unix socket the admin service is served on, empty disables it\")\n")
+	buf.WriteString("\tname := flag.String(\"name\", \"\", \"The name announced to peer orchestrators, defaults to the host name\")\n")
+	buf.WriteString("\tpeerAddr := flag.String(\"peer-addr\", \"\", \"The address peer orchestrators dial over mutual TLS, empty accepts no peers\")\n")
+	buf.WriteString("\tpeers := flag.String(\"peers\", \"\", \"Comma separated addresses of peer orchestrators, requests for methods without local workers are forwarded to them\")\n")
+	buf.WriteString("\tingressPriority := flag.String(\"ingress-priority-limit\", \"normal\", \"The highest priority ingress clients may claim with the x-priority header, high lets any client jump the queues\")\n")
+	buf.WriteString("\tshutdownTimeout := flag.Duration(\"shutdown-timeout\", 30*time.Second, \"How long to wait for in-flight requests on shutdown\")\n")
+	buf.WriteString("\tflag.Parse()\n")
+	buf.WriteString("\n")
 	buf.WriteString("\torch := orchestrator.NewOrchestrator()\n")
+	buf.WriteString("\tif *name != \"\" {\n")
+	buf.WriteString("\t\torch.SetName(*name)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tpriorityLimit, err := factory.ParsePriority(*ingressPriority)\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\tlog.Fatalf(\"Invalid -ingress-priority-limit: %v\", err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\torch.SetIngressPriorityLimit(priorityLimit)\n")
+	buf.WriteString("\n")
+	buf.WriteString("\t// Pool settings shared by every method, \"//pipes:pool\" directives override them per method\n")
+	buf.WriteString("\tdefaultOptions := func() orchestrator.PoolOptions {\n")
+	buf.WriteString("\t\toptions := orchestrator.DefaultPoolOptions()\n")
+	buf.WriteString("\t\toptions.Replicas = *minReplicas\n")
+	buf.WriteString("\t\toptions.MaxReplicas = *maxReplicas\n")
+	buf.WriteString("\t\toptions.Balancer = newBalancer(*balancer, \"-balancer\")\n")
+	buf.WriteString("\t\treturn options\n")
+	buf.WriteString("\t}\n")
 	buf.WriteString("\n")
+	buf.WriteString("\tvar options orchestrator.PoolOptions\n")
 
 	for _, method := range methods {
 		// Use the ShortID for the binary name to match what we'll generate in the Dockerfile
 		// Binary path is relative to the working directory in the container (WORKDIR is /app)
 		binaryPath := fmt.Sprintf("./%s", method.ShortID)
 
-		buf.WriteString(fmt.Sprintf("\tif err := orch.Spawn(\"%s\", \"%s\", 1); err != nil {\n", method.MethodID, binaryPath))
+		buf.WriteString("\n")
+		buf.WriteString("\toptions = defaultOptions()\n")
+		writeMethodOptions(&buf, method.MethodName, method.Options)
+		buf.WriteString(fmt.Sprintf("\tif err := orch.SpawnWithOptions(\"%s\", \"%s\", options); err != nil {\n", method.MethodID, binaryPath))
 		buf.WriteString(fmt.Sprintf("\t\tlog.Fatalf(\"Failed to spawn worker for %%s: %%v\", \"%s\", err)\n", method.MethodName))
 		buf.WriteString("\t}\n")
 	}
 
+	// Ingress routes expose each method under its original proto service name
+	buf.WriteString("\n")
+	for _, method := range methods {
+		if method.ReqType == "" || method.RespType == "" {
+			continue
+		}
+		if method.ClientStreams || method.ServerStreams {
+			buf.WriteString(fmt.Sprintf("\torch.AddRoute(orchestrator.NewStreamRoute[%s, %s](\"%s\", \"%s\", \"%s\", %t, %t))\n",
+				method.ReqType, method.RespType, method.ServiceName, method.MethodName, method.MethodID, method.ClientStreams, method.ServerStreams))
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("\torch.AddRoute(orchestrator.NewRoute[%s, %s](\"%s\", \"%s\", \"%s\"))\n",
+			method.ReqType, method.RespType, method.ServiceName, method.MethodName, method.MethodID))
+	}
+
+	buf.WriteString("\n")
+	buf.WriteString("\tlis, err := net.Listen(\"tcp\", *grpcAddr)\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\tlog.Fatalf(\"Failed to listen on %s: %v\", *grpcAddr, err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tgo func() {\n")
+	buf.WriteString("\t\tif err := orch.ServeGRPC(lis); err != nil {\n")
+	buf.WriteString("\t\t\tlog.Fatalf(\"gRPC ingress stopped: %v\", err)\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t}()\n")
+	buf.WriteString("\n")
+	buf.WriteString("\thttpLis, err := net.Listen(\"tcp\", *httpAddr)\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\tlog.Fatalf(\"Failed to listen on %s: %v\", *httpAddr, err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tgo func() {\n")
+	buf.WriteString("\t\tif err := orch.ServeHTTPIngress(httpLis); err != nil {\n")
+	buf.WriteString("\t\t\tlog.Fatalf(\"HTTP ingress stopped: %v\", err)\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t}()\n")
+
+	buf.WriteString("\n")
+	buf.WriteString("\tif *workerAddr != \"\" {\n")
+	buf.WriteString("\t\ttlsConfig, err := utils.ServerTLSConfig(*tlsCert, *tlsKey, *tlsCA)\n")
+	buf.WriteString("\t\tif err != nil {\n")
+	buf.WriteString("\t\t\tlog.Fatalf(\"Failed to set up TLS for remote workers: %v\", err)\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t\tworkerLis, err := tls.Listen(\"tcp\", *workerAddr, tlsConfig)\n")
+	buf.WriteString("\t\tif err != nil {\n")
+	buf.WriteString("\t\t\tlog.Fatalf(\"Failed to listen on %s: %v\", *workerAddr, err)\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t\tgo func() {\n")
+	buf.WriteString("\t\t\tif err := orch.ServeRemoteWorkers(workerLis); err != nil {\n")
+	buf.WriteString("\t\t\t\tlog.Fatalf(\"Remote worker listener stopped: %v\", err)\n")
+	buf.WriteString("\t\t\t}\n")
+	buf.WriteString("\t\t}()\n")
+	buf.WriteString("\t}\n")
+
+	// Peers are set up once the local pools exist, so the first announcement lists them all
+	buf.WriteString("\n")
+	buf.WriteString("\tif *peerAddr != \"\" {\n")
+	buf.WriteString("\t\ttlsConfig, err := utils.ServerTLSConfig(*tlsCert, *tlsKey, *tlsCA)\n")
+	buf.WriteString("\t\tif err != nil {\n")
+	buf.WriteString("\t\t\tlog.Fatalf(\"Failed to set up TLS for peers: %v\", err)\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t\tpeerLis, err := tls.Listen(\"tcp\", *peerAddr, tlsConfig)\n")
+	buf.WriteString("\t\tif err != nil {\n")
+	buf.WriteString("\t\t\tlog.Fatalf(\"Failed to listen on %s: %v\", *peerAddr, err)\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t\tgo func() {\n")
+	buf.WriteString("\t\t\tif err := orch.ServePeers(peerLis); err != nil {\n")
+	buf.WriteString("\t\t\t\tlog.Fatalf(\"Peer listener stopped: %v\", err)\n")
+	buf.WriteString("\t\t\t}\n")
+	buf.WriteString("\t\t}()\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif *peers != \"\" {\n")
+	buf.WriteString("\t\ttlsConfig, err := utils.ClientTLSConfig(*tlsCert, *tlsKey, *tlsCA)\n")
+	buf.WriteString("\t\tif err != nil {\n")
+	buf.WriteString("\t\t\tlog.Fatalf(\"Failed to set up TLS for peers: %v\", err)\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t\tfor _, addr := range strings.Split(*peers, \",\") {\n")
+	buf.WriteString("\t\t\torch.AddPeer(strings.TrimSpace(addr), tlsConfig)\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t}\n")
+
+	// A second orchestrator on the same machine runs without the attach socket rather than failing
+	buf.WriteString("\n")
+	buf.WriteString("\tif *attachSocket != \"\" {\n")
+	buf.WriteString("\t\tif attachLis, err := orchestrator.ListenAttachSocket(*attachSocket); err != nil {\n")
+	buf.WriteString("\t\t\tlog.Printf(\"Not accepting attached workers: %v\", err)\n")
+	buf.WriteString("\t\t} else {\n")
+	buf.WriteString("\t\t\tgo func() {\n")
+	buf.WriteString("\t\t\t\tif err := orch.ServeRemoteWorkers(attachLis); err != nil {\n")
+	buf.WriteString("\t\t\t\t\tlog.Printf(\"Attach socket stopped: %v\", err)\n")
+	buf.WriteString("\t\t\t\t}\n")
+	buf.WriteString("\t\t\t}()\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t}\n")
+
+	// The same goes for the admin socket
+	buf.WriteString("\n")
+	buf.WriteString("\tif *adminSocket != \"\" {\n")
+	buf.WriteString("\t\tif adminLis, err := orchestrator.ListenAdminSocket(*adminSocket); err != nil {\n")
+	buf.WriteString("\t\t\tlog.Printf(\"Not serving the admin service: %v\", err)\n")
+	buf.WriteString("\t\t} else {\n")
+	buf.WriteString("\t\t\tgo func() {\n")
+	buf.WriteString("\t\t\t\tif err := orch.ServeAdmin(adminLis); err != nil {\n")
+	buf.WriteString("\t\t\t\t\tlog.Printf(\"Admin service stopped: %v\", err)\n")
+	buf.WriteString("\t\t\t\t}\n")
+	buf.WriteString("\t\t\t}()\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t}\n")
+
+	buf.WriteString("\n")
+	buf.WriteString("\t// SIGHUP rolls every method out to the binary now on disk, without downtime\n")
+	buf.WriteString("\treload := make(chan os.Signal, 1)\n")
+	buf.WriteString("\tsignal.Notify(reload, syscall.SIGHUP)\n")
+	buf.WriteString("\tgo func() {\n")
+	buf.WriteString("\t\tfor range reload {\n")
+	buf.WriteString("\t\t\tif err := orch.ReplaceAll(); err != nil {\n")
+	buf.WriteString("\t\t\t\tlog.Printf(\"Rollout incomplete: %v\", err)\n")
+	buf.WriteString("\t\t\t}\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t}()\n")
+	buf.WriteString("\n")
+	buf.WriteString("\t// Run until asked to stop, then drain gracefully\n")
+	buf.WriteString("\tstop := make(chan os.Signal, 1)\n")
+	buf.WriteString("\tsignal.Notify(stop, os.Interrupt, syscall.SIGTERM)\n")
+	buf.WriteString("\t<-stop\n")
+	buf.WriteString("\n")
+	buf.WriteString("\tctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)\n")
+	buf.WriteString("\tdefer cancel()\n")
+	buf.WriteString("\tif err := orch.Shutdown(ctx); err != nil {\n")
+	buf.WriteString("\t\tlog.Printf(\"Shutdown did not complete cleanly: %v\", err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("}\n")
+
+	// Every pool gets its own balancer, they keep per-pool state.
+	// source names the flag or directive the balancer was picked with.
 	buf.WriteString("\n")
-	buf.WriteString("\t// Block forever to keep the orchestrator running\n")
-	buf.WriteString("\tselect {}\n")
+	buf.WriteString("func newBalancer(name string, source string) orchestrator.Balancer {\n")
+	buf.WriteString("\tbalancer, err := orchestrator.NewBalancer(name)\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\tlog.Fatalf(\"Invalid %s: %v\", source, err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn balancer\n")
 	buf.WriteString("}\n")
 
 	// Output directory for orchestrator
@@ -8,4 +8,13 @@ type MethodInfo struct {
 	ShortID      string // Short unique ID for binaries e.g. "get_book"
 	FullDirPath  string // Absolute path to the directory containing main.go
 	RelativePath string // Path generated relative to the CodeGenConfig.OutputDir
+
+	ServiceName     string // e.g. "BookService"
+	ProtoImportPath string // e.g. "github.com/bsmider/pipes/core/example/build/example"
+	ProtoPackage    string // Name the proto package is referenced by e.g. "example"
+	ReqType         string // e.g. "*example.GetBookRequest"
+	RespType        string // e.g. "*example.GetBookResponse"
+	ClientStreams   bool   // The request side is a stream
+	ServerStreams   bool   // The response side is a stream
+	Options         MethodOptions
 }
@@ -0,0 +1,231 @@
+package factory
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// MethodOptions are the pool settings of a generated method. They are read from
+// "//pipes:pool" directives in the doc comment of the service method, e.g.
+//
+//	//pipes:pool timeout=20s retries=0 replicas=2 max-replicas=8 max-concurrency=4 balancer=least-outstanding
+//	//pipes:pool target-latency=500ms
+//	//pipes:pool retry-codes=UNAVAILABLE,RESOURCE_EXHAUSTED initial-backoff=100ms max-backoff=2s deadline=30s idempotent=false
+//	//pipes:pool hedge-attempts=2 hedge-delay=50ms
+//	//pipes:pool breaker-failures=10 breaker-open=30s
+//	//pipes:pool rate=200 burst=50 max-in-flight=100 caller-rate=50 caller-max-in-flight=20
+//
+// Settings that are not given keep the generated orchestrator's defaults.
+type MethodOptions struct {
+	Timeout        time.Duration // 0 keeps the default
+	Retries        *int          // nil keeps the default, retries=0 is a valid setting
+	Replicas       *int          // nil keeps the default, replicas=0 serves the method with remote workers only
+	MaxReplicas    int           // 0 keeps the default
+	TargetLatency  time.Duration // 0 keeps the default, half the timeout
+	MaxConcurrency int           // 0 keeps the default
+	Balancer       string        // "" keeps the default
+
+	// Retry policy settings, unset ones keep orchestrator.DefaultRetryPolicy
+	RetryCodes     []codes.Code  // nil keeps the default
+	InitialBackoff time.Duration // 0 keeps the default
+	MaxBackoff     time.Duration // 0 keeps the default
+	Deadline       time.Duration // 0 keeps the default
+	NonIdempotent  bool          // set by idempotent=false
+
+	// Hedging settings, hedging replaces retries for the method
+	HedgeAttempts int           // 0 disables hedging
+	HedgeDelay    time.Duration // 0 hedges after the pool's p95 latency
+
+	// Circuit breaker settings
+	BreakerOff      bool          // set by breaker=off
+	BreakerFailures int           // 0 keeps the default
+	BreakerOpen     time.Duration // 0 keeps the default
+
+	// Admission limits of the method as a whole and of each calling method, 0 is unlimited
+	Rate              float64
+	Burst             int
+	MaxInFlight       int
+	CallerRate        float64
+	CallerBurst       int
+	CallerMaxInFlight int
+}
+
+// parseMethodOptions validates the raw directive settings of a method
+func parseMethodOptions(raw map[string]string) (MethodOptions, error) {
+	var options MethodOptions
+
+	for key, value := range raw {
+		var err error
+		switch key {
+		case "timeout":
+			options.Timeout, err = parsePositiveDuration(value)
+		case "retries":
+			var retries int
+			retries, err = parseCount(value, 0)
+			options.Retries = &retries
+		case "replicas":
+			var replicas int
+			replicas, err = parseCount(value, 0)
+			options.Replicas = &replicas
+		case "max-replicas":
+			options.MaxReplicas, err = parseCount(value, 1)
+		case "target-latency":
+			options.TargetLatency, err = parsePositiveDuration(value)
+		case "max-concurrency":
+			options.MaxConcurrency, err = parseCount(value, 1)
+		case "balancer":
+			switch value {
+			case "round-robin", "least-outstanding", "power-of-two", "weighted":
+				options.Balancer = value
+			default:
+				err = fmt.Errorf("unknown balancer")
+			}
+		case "retry-codes":
+			options.RetryCodes, err = parseCodes(value)
+		case "initial-backoff":
+			options.InitialBackoff, err = parsePositiveDuration(value)
+		case "max-backoff":
+			options.MaxBackoff, err = parsePositiveDuration(value)
+		case "deadline":
+			options.Deadline, err = parsePositiveDuration(value)
+		case "idempotent":
+			var idempotent bool
+			idempotent, err = strconv.ParseBool(value)
+			options.NonIdempotent = !idempotent
+		case "hedge-attempts":
+			options.HedgeAttempts, err = parseCount(value, 2)
+		case "hedge-delay":
+			options.HedgeDelay, err = parsePositiveDuration(value)
+		case "breaker":
+			options.BreakerOff = value == "off"
+			if value != "on" && value != "off" {
+				err = fmt.Errorf("must be on or off")
+			}
+		case "breaker-failures":
+			options.BreakerFailures, err = parseCount(value, 1)
+		case "breaker-open":
+			options.BreakerOpen, err = parsePositiveDuration(value)
+		case "rate":
+			options.Rate, err = parseRate(value)
+		case "burst":
+			options.Burst, err = parseCount(value, 1)
+		case "max-in-flight":
+			options.MaxInFlight, err = parseCount(value, 1)
+		case "caller-rate":
+			options.CallerRate, err = parseRate(value)
+		case "caller-burst":
+			options.CallerBurst, err = parseCount(value, 1)
+		case "caller-max-in-flight":
+			options.CallerMaxInFlight, err = parseCount(value, 1)
+		default:
+			return options, fmt.Errorf("unknown pool setting %q", key)
+		}
+
+		if err != nil {
+			return options, fmt.Errorf("invalid pool setting %s=%s: %w", key, value, err)
+		}
+	}
+
+	if options.Replicas != nil && options.MaxReplicas > 0 {
+		if *options.Replicas == 0 {
+			return options, fmt.Errorf("max-replicas given with replicas=0, pools of remote workers only are not autoscaled")
+		}
+		if options.MaxReplicas < *options.Replicas {
+			return options, fmt.Errorf("max-replicas %d is below replicas %d", options.MaxReplicas, *options.Replicas)
+		}
+	}
+	if options.HedgeDelay > 0 && options.HedgeAttempts == 0 {
+		return options, fmt.Errorf("hedge-delay needs hedge-attempts")
+	}
+	if options.BreakerOff && (options.BreakerFailures > 0 || options.BreakerOpen > 0) {
+		return options, fmt.Errorf("breaker settings given with breaker=off")
+	}
+	if (options.Burst > 0 && options.Rate == 0) || (options.CallerBurst > 0 && options.CallerRate == 0) {
+		return options, fmt.Errorf("burst needs a rate")
+	}
+	return options, nil
+}
+
+// parsePositiveDuration parses a duration greater than zero
+func parsePositiveDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return d, nil
+}
+
+// parseRate parses a positive number of requests per second
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return 0, fmt.Errorf("must be a positive number")
+	}
+	return rate, nil
+}
+
+// parseCodes parses a comma separated list of status code names e.g. "UNAVAILABLE,ABORTED"
+func parseCodes(value string) ([]codes.Code, error) {
+	var parsed []codes.Code
+	for _, name := range strings.Split(value, ",") {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(name)))); err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, code)
+	}
+	return parsed, nil
+}
+
+// parseCount parses a whole number of at least min
+func parseCount(value string, min int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < min {
+		return 0, fmt.Errorf("must be at least %d", min)
+	}
+	return n, nil
+}
+
+// codesLiteral formats codes as a Go slice literal, e.g. "[]codes.Code{codes.Unavailable}"
+func codesLiteral(list []codes.Code) string {
+	names := make([]string, len(list))
+	for i, code := range list {
+		names[i] = "codes." + code.String()
+	}
+	return "[]codes.Code{" + strings.Join(names, ", ") + "}"
+}
+
+// durationLiteral formats d as Go source using the time package's units, e.g. "20 * time.Second"
+func durationLiteral(d time.Duration) string {
+	units := []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	}
+
+	for _, u := range units {
+		if d%u.unit == 0 {
+			return fmt.Sprintf("%d * %s", d/u.unit, u.name)
+		}
+	}
+	return fmt.Sprintf("time.Duration(%d)", int64(d))
+}
@@ -0,0 +1,371 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"github.com/bsmider/pipes/core/factory/admin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ListenAdminSocket listens on a Unix socket at path for the admin service, see ServeAdmin.
+// A socket left behind by a previous orchestrator is replaced. Only the current user may connect.
+func ListenAdminSocket(path string) (net.Listener, error) {
+	return listenLocalSocket(path)
+}
+
+// ServeAdmin serves the admin gRPC service on lis. The service can kill workers, so lis
+// should only be reachable by operators, e.g. a listener from ListenAdminSocket.
+// It keeps serving while the orchestrator shuts down and blocks until the server stops.
+func (o *Orchestrator) ServeAdmin(lis net.Listener) error {
+	server := grpc.NewServer()
+	admin.RegisterAdminServer(server, &adminServer{o: o})
+
+	o.routesMu.Lock()
+	o.adminServer = server
+	o.routesMu.Unlock()
+
+	log.Printf("[Orchestrator] Admin service listening on %s", lis.Addr())
+	return server.Serve(lis)
+}
+
+// stopAdmin stops the admin service, if it is served
+func (o *Orchestrator) stopAdmin() {
+	o.routesMu.RLock()
+	server := o.adminServer
+	o.routesMu.RUnlock()
+
+	if server != nil {
+		server.Stop()
+	}
+}
+
+// DrainWorker retires the worker with id from its pool, it finishes its requests and exits
+// without being restarted
+func (o *Orchestrator) DrainWorker(id string) error {
+	worker, pool, ok := o.findWorker(id)
+	if !ok {
+		return fmt.Errorf("no worker %s", id)
+	}
+	if pool == nil {
+		return fmt.Errorf("worker %s is already draining", id)
+	}
+	o.retireWorker(pool, worker)
+	log.Printf("[Orchestrator] Draining worker %s (%s)", worker.id, worker.origin())
+	return nil
+}
+
+// KillWorker kills the worker with id at once. Requests it was running fail and the
+// worker is restarted like one that crashed, a remote worker is disconnected.
+func (o *Orchestrator) KillWorker(id string) error {
+	worker, _, ok := o.findWorker(id)
+	if !ok {
+		return fmt.Errorf("no worker %s", id)
+	}
+	log.Printf("[Orchestrator] Killing worker %s (%s)", worker.id, worker.origin())
+	worker.kill()
+	return nil
+}
+
+// findWorker looks up a worker by id among the pools and the workers still draining.
+// The pool is nil for a worker that is draining.
+func (o *Orchestrator) findWorker(id string) (*Worker, *WorkerPool, bool) {
+	o.poolsMu.RLock()
+	for _, pool := range o.pools {
+		for _, worker := range pool.snapshot() {
+			if worker.id == id {
+				o.poolsMu.RUnlock()
+				return worker, pool, true
+			}
+		}
+	}
+	o.poolsMu.RUnlock()
+
+	var found *Worker
+	o.retiring.Range(func(key, _ any) bool {
+		if worker := key.(*Worker); worker.id == id {
+			found = worker
+			return false
+		}
+		return true
+	})
+	return found, nil, found != nil
+}
+
+// adminServer implements the admin service on top of the orchestrator
+type adminServer struct {
+	admin.UnimplementedAdminServer
+	o *Orchestrator
+}
+
+func (s *adminServer) ListPools(ctx context.Context, req *admin.ListPoolsRequest) (*admin.ListPoolsResponse, error) {
+	s.o.poolsMu.RLock()
+	pools := make(map[string]*WorkerPool, len(s.o.pools))
+	for processType, pool := range s.o.pools {
+		pools[processType] = pool
+	}
+	s.o.poolsMu.RUnlock()
+
+	resp := &admin.ListPoolsResponse{}
+	for processType, pool := range pools {
+		resp.Pools = append(resp.Pools, describePool(processType, pool))
+	}
+	sort.Slice(resp.Pools, func(i, j int) bool { return resp.Pools[i].MethodId < resp.Pools[j].MethodId })
+	return resp, nil
+}
+
+func (s *adminServer) ListWorkers(ctx context.Context, req *admin.ListWorkersRequest) (*admin.ListWorkersResponse, error) {
+	if req.MethodId != "" {
+		if _, ok := s.o.pool(req.MethodId); !ok {
+			return nil, status.Errorf(codes.NotFound, "no pool for target type: %s", req.MethodId)
+		}
+	}
+
+	resp := &admin.ListWorkersResponse{}
+	for _, worker := range s.o.allWorkers() {
+		if req.MethodId == "" || worker.processType == req.MethodId {
+			resp.Workers = append(resp.Workers, describeWorker(worker))
+		}
+	}
+	sort.Slice(resp.Workers, func(i, j int) bool {
+		a, b := resp.Workers[i], resp.Workers[j]
+		if a.MethodId != b.MethodId {
+			return a.MethodId < b.MethodId
+		}
+		return a.Id < b.Id
+	})
+	return resp, nil
+}
+
+func (s *adminServer) ListPending(ctx context.Context, req *admin.ListPendingRequest) (*admin.ListPendingResponse, error) {
+	now := time.Now()
+	resp := &admin.ListPendingResponse{}
+	s.o.responseChannels.Range(func(key, value any) bool {
+		if pending, ok := value.(*pendingResponse); ok {
+			resp.Pending = append(resp.Pending, &admin.PendingResponse{
+				PacketId: key.(string),
+				MethodId: pending.methodID,
+				Target:   pending.target,
+				Age:      durationpb.New(now.Sub(pending.since)),
+			})
+		}
+		return true
+	})
+	sort.Slice(resp.Pending, func(i, j int) bool {
+		return resp.Pending[i].Age.AsDuration() > resp.Pending[j].Age.AsDuration()
+	})
+	return resp, nil
+}
+
+func (s *adminServer) RecentErrors(ctx context.Context, req *admin.RecentErrorsRequest) (*admin.RecentErrorsResponse, error) {
+	resp := &admin.RecentErrorsResponse{}
+	for _, e := range s.o.RecentErrors(int(req.Limit)) {
+		resp.Errors = append(resp.Errors, &admin.Error{
+			Time:     timestamppb.New(e.Time),
+			MethodId: e.MethodID,
+			PacketId: e.PacketID,
+			WorkerId: e.WorkerID,
+			Code:     e.Code.String(),
+			Message:  e.Message,
+		})
+	}
+	return resp, nil
+}
+
+func (s *adminServer) Scale(ctx context.Context, req *admin.ScaleRequest) (*admin.ScaleResponse, error) {
+	pool, ok := s.o.pool(req.MethodId)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no pool for target type: %s", req.MethodId)
+	}
+	var err error
+	if req.Autoscale {
+		err = s.o.ResumeAutoscaling(req.MethodId)
+	} else {
+		err = s.o.Scale(req.MethodId, int(req.Replicas))
+	}
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &admin.ScaleResponse{Workers: int32(pool.Size())}, nil
+}
+
+func (s *adminServer) Restart(ctx context.Context, req *admin.RestartRequest) (*admin.RestartResponse, error) {
+	pool, ok := s.o.pool(req.MethodId)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no pool for target type: %s", req.MethodId)
+	}
+	// A crash-looping pool may have no workers left, restarting it is how it is started again
+	if len(pool.localWorkers(DefaultVersion)) == 0 && !s.o.crashLooping(req.MethodId, DefaultVersion) {
+		return nil, status.Errorf(codes.FailedPrecondition, "%s has no workers started by the orchestrator", req.MethodId)
+	}
+	if err := s.o.Replace(req.MethodId, pool.binary()); err != nil {
+		return nil, status.Error(codes.Aborted, err.Error())
+	}
+	return &admin.RestartResponse{}, nil
+}
+
+func (s *adminServer) DrainWorker(ctx context.Context, req *admin.WorkerRequest) (*admin.WorkerResponse, error) {
+	if _, _, ok := s.o.findWorker(req.WorkerId); !ok {
+		return nil, status.Errorf(codes.NotFound, "no worker %s", req.WorkerId)
+	}
+	if err := s.o.DrainWorker(req.WorkerId); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &admin.WorkerResponse{}, nil
+}
+
+func (s *adminServer) KillWorker(ctx context.Context, req *admin.WorkerRequest) (*admin.WorkerResponse, error) {
+	if err := s.o.KillWorker(req.WorkerId); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &admin.WorkerResponse{}, nil
+}
+
+func (s *adminServer) ListRoutes(ctx context.Context, req *admin.ListRoutesRequest) (*admin.ListRoutesResponse, error) {
+	resp := &admin.ListRoutesResponse{}
+	seen := make(map[string]bool)
+	for _, route := range s.o.Routes() {
+		request := route.newRequest().ProtoReflect().Descriptor()
+		response := route.newResponse().ProtoReflect().Descriptor()
+		resp.Routes = append(resp.Routes, &admin.Route{
+			FullMethod:    route.FullMethod(),
+			MethodId:      route.MethodID,
+			RequestType:   string(request.FullName()),
+			ResponseType:  string(response.FullName()),
+			ClientStreams: route.ClientStreams,
+			ServerStreams: route.ServerStreams,
+		})
+		resp.Files = appendFiles(resp.Files, request.ParentFile(), seen)
+		resp.Files = appendFiles(resp.Files, response.ParentFile(), seen)
+	}
+	return resp, nil
+}
+
+func (s *adminServer) Call(ctx context.Context, req *admin.CallRequest) (*admin.CallResponse, error) {
+	route, ok := s.o.GetRoute(req.FullMethod)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no route for %s", req.FullMethod)
+	}
+	if route.IsStream() {
+		return nil, status.Errorf(codes.Unimplemented, "%s is a streaming method", req.FullMethod)
+	}
+	if err := proto.Unmarshal(req.Payload, route.newRequest()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "payload is not a valid request for %s: %v", req.FullMethod, err)
+	}
+
+	// Callers of the admin socket are operators, they may claim any priority
+	packet := factory.NewPacket(factory.GeneratePacketId(), factory.PacketType_PACKET_TYPE_REQUEST, route.MethodID, ingressContext(ctx, factory.Priority_PRIORITY_HIGH), req.Payload, nil)
+	resp := &admin.CallResponse{PacketId: packet.Id, MethodId: route.MethodID}
+
+	response, err := s.o.RouteRequestContext(ctx, packet)
+	if err != nil {
+		resp.Status = status.Convert(err).Proto()
+		resp.Hops = packet.Context.GetHops()
+		return resp, nil
+	}
+
+	resp.Status = status.New(codes.OK, "").Proto()
+	if st := response.GetError().GetStatus(); st != nil {
+		resp.Status = st
+	} else {
+		resp.Payload = response.Payload
+	}
+	resp.Hops = response.GetContext().GetHops()
+	return resp, nil
+}
+
+// appendFiles appends file to files, after the files it imports, unless seen has it already
+func appendFiles(files []*descriptorpb.FileDescriptorProto, file protoreflect.FileDescriptor, seen map[string]bool) []*descriptorpb.FileDescriptorProto {
+	if seen[file.Path()] {
+		return files
+	}
+	seen[file.Path()] = true
+
+	imports := file.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		files = appendFiles(files, imports.Get(i).FileDescriptor, seen)
+	}
+	return append(files, protodesc.ToFileDescriptorProto(file))
+}
+
+// describePool reports the settings and load of a pool
+func describePool(processType string, pool *WorkerPool) *admin.Pool {
+	timeout, policy, _ := pool.limits()
+
+	pool.mu.RLock()
+	balancer := pool.balancer
+	pool.mu.RUnlock()
+
+	described := &admin.Pool{
+		MethodId: processType,
+		Balancer: balancerName(balancer),
+		Timeout:  durationpb.New(timeout),
+		Retries:  int32(policy.MaxAttempts - 1),
+		Queued:   int32(pool.Queued()),
+		Breaker:  pool.breaker.State().String(),
+		Binary:   pool.binary(),
+		Latency:  durationpb.New(pool.Latency()),
+	}
+	described.Requests, described.Failures = pool.Requests()
+	for _, worker := range pool.snapshot() {
+		described.Workers++
+		if worker.IsHealthy() {
+			described.HealthyWorkers++
+		}
+		described.InFlight += worker.InFlight()
+	}
+
+	pool.scaleMu.Lock()
+	if policy := pool.scaling; policy != nil {
+		described.MinReplicas = int32(policy.MinReplicas)
+		described.MaxReplicas = int32(policy.MaxReplicas)
+	}
+	described.Pinned = pool.pinned != nil
+	pool.scaleMu.Unlock()
+	return described
+}
+
+// describeWorker reports the state of a worker
+func describeWorker(worker *Worker) *admin.Worker {
+	return &admin.Worker{
+		Id:       worker.id,
+		MethodId: worker.processType,
+		Pid:      int32(worker.pid()),
+		Origin:   worker.origin(),
+		Uptime:   durationpb.New(worker.Uptime()),
+		InFlight: worker.InFlight(),
+		Healthy:  worker.IsHealthy(),
+		Draining: worker.draining.Load(),
+		Version:  worker.version,
+		Binary:   worker.binaryPath,
+	}
+}
+
+// balancerName returns the name NewBalancer knows balancer by
+func balancerName(balancer Balancer) string {
+	switch balancer.(type) {
+	case *RoundRobinBalancer:
+		return "round-robin"
+	case *LeastOutstandingBalancer:
+		return "least-outstanding"
+	case *PowerOfTwoBalancer:
+		return "power-of-two"
+	case *WeightedBalancer:
+		return "weighted"
+	default:
+		return fmt.Sprintf("%T", balancer)
+	}
+}
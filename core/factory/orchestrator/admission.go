@@ -0,0 +1,194 @@
+package orchestrator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Limit caps the requests admitted to a method. A zero Limit admits everything.
+type Limit struct {
+	Rate          float64 // Requests per second, 0 is unlimited
+	Burst         int     // Requests admitted at once above Rate, 0 allows one second's worth
+	MaxConcurrent int     // Requests being routed at once, 0 is unlimited
+}
+
+// AdmissionPolicy limits the requests a method admits before they reach a worker.
+// Callers are the method IDs of the workers making internal requests,
+// requests coming in through the ingress servers share the caller "".
+type AdmissionPolicy struct {
+	Limit                      // Applies to all requests of the method together
+	PerCaller Limit            // Applies to the requests of each caller separately
+	Callers   map[string]Limit // Replaces PerCaller for the given callers
+}
+
+// limiter enforces a single Limit
+type limiter struct {
+	limit    Limit
+	now      func() time.Time
+	inFlight atomic.Int64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(limit Limit, now func() time.Time) *limiter {
+	if limit.Rate > 0 && limit.Burst <= 0 {
+		limit.Burst = max(1, int(math.Ceil(limit.Rate)))
+	}
+	return &limiter{limit: limit, now: now, tokens: float64(limit.Burst), last: now()}
+}
+
+// admit counts a request against the limiter. The returned release must be
+// called once the request is done, reason explains a rejection.
+func (l *limiter) admit() (release func(), reason string) {
+	if l.limit.MaxConcurrent > 0 && l.inFlight.Add(1) > int64(l.limit.MaxConcurrent) {
+		l.inFlight.Add(-1)
+		return nil, fmt.Sprintf("more than %d requests in flight", l.limit.MaxConcurrent)
+	}
+	release = func() {
+		if l.limit.MaxConcurrent > 0 {
+			l.inFlight.Add(-1)
+		}
+	}
+
+	if l.limit.Rate > 0 && !l.take() {
+		release()
+		return nil, fmt.Sprintf("more than %g requests per second", l.limit.Rate)
+	}
+	return release, ""
+}
+
+// take removes a token from the bucket, refilling it at the limit's rate
+func (l *limiter) take() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.tokens = min(float64(l.limit.Burst), l.tokens+now.Sub(l.last).Seconds()*l.limit.Rate)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// admission enforces the AdmissionPolicy of a pool
+type admission struct {
+	policy  AdmissionPolicy
+	now     func() time.Time // The clock token buckets refill with, replaced in tests
+	total   *limiter
+	callers sync.Map // Map[caller]*limiter, created on first use
+}
+
+func newAdmission(policy AdmissionPolicy) *admission {
+	return newAdmissionClock(policy, time.Now)
+}
+
+// newAdmissionClock is newAdmission with the clock of its token buckets
+func newAdmissionClock(policy AdmissionPolicy, now func() time.Time) *admission {
+	return &admission{policy: policy, now: now, total: newLimiter(policy.Limit, now)}
+}
+
+// admit counts a request of caller against the policy. The caller's own limit is
+// checked first so a caller over its limit does not use up the method's.
+func (a *admission) admit(caller string) (release func(), reason string) {
+	callerRelease, reason := a.callerLimiter(caller).admit()
+	if callerRelease == nil {
+		return nil, reason + " from " + callerName(caller)
+	}
+
+	totalRelease, reason := a.total.admit()
+	if totalRelease == nil {
+		callerRelease()
+		return nil, reason
+	}
+
+	return func() {
+		totalRelease()
+		callerRelease()
+	}, ""
+}
+
+// callerLimiter returns the limiter of caller, creating it on first use
+func (a *admission) callerLimiter(caller string) *limiter {
+	if l, ok := a.callers.Load(caller); ok {
+		return l.(*limiter)
+	}
+
+	limit, ok := a.policy.Callers[caller]
+	if !ok {
+		limit = a.policy.PerCaller
+	}
+	l, _ := a.callers.LoadOrStore(caller, newLimiter(limit, a.now))
+	return l.(*limiter)
+}
+
+// callerName returns how caller is shown in errors
+func callerName(caller string) string {
+	if caller == "" {
+		return "the ingress"
+	}
+	return caller
+}
+
+// callerOf returns the method ID of the worker that sent packet, taken from the
+// last hop of the packet that is not the orchestrator. Requests forwarded by a
+// peer orchestrator return its hop, "peer:name". Requests coming in through
+// the ingress servers have no such hop and return "".
+func callerOf(packet *factory.Packet) string {
+	hops := packet.GetContext().GetHops()
+	for i := len(hops) - 1; i >= 0; i-- {
+		id := hops[i].GetBinaryId()
+		if id == "orchestrator" {
+			continue
+		}
+		if strings.HasPrefix(id, peerHopPrefix) {
+			return id
+		}
+		// Worker ids are the method ID followed by "-" and a short suffix, see spawnWorker
+		if cut := strings.LastIndex(id, "-"); cut > 0 {
+			return id[:cut]
+		}
+		return id
+	}
+	return ""
+}
+
+// admit counts a request of caller against the pool's admission policy.
+// Rejections are RESOURCE_EXHAUSTED errors, marked as never processed.
+func (p *WorkerPool) admit(processType string, caller string) (release func(), err error) {
+	p.mu.RLock()
+	admission := p.admission
+	p.mu.RUnlock()
+
+	release, reason := admission.admit(caller)
+	if release == nil {
+		st := status.Newf(codes.ResourceExhausted, "%s rejected the request: %s", processType, reason)
+		return nil, factory.MarkNotProcessed(st).Err()
+	}
+	return release, nil
+}
+
+// SetAdmissionPolicy replaces the admission policy of the pool serving processType.
+// Requests already admitted do not count against the new limits.
+func (o *Orchestrator) SetAdmissionPolicy(processType string, policy AdmissionPolicy) error {
+	pool, ok := o.pool(processType)
+	if !ok {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.admission = newAdmission(policy)
+	return nil
+}
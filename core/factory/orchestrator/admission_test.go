@@ -0,0 +1,167 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// admissionStep is one thing that happens to an admission policy in TestAdmission
+type admissionStep struct {
+	op     string // "admit" expects the request of caller to be admitted, "reject" to be rejected, "release" ends the oldest admitted request, "wait" advances the clock
+	caller string
+	wait   time.Duration
+	reason string // Part of the rejection reason
+}
+
+func TestAdmission(t *testing.T) {
+	admit := func(caller string, n int) []admissionStep {
+		steps := make([]admissionStep, n)
+		for i := range steps {
+			steps[i] = admissionStep{op: "admit", caller: caller}
+		}
+		return steps
+	}
+	join := func(steps ...[]admissionStep) []admissionStep {
+		var joined []admissionStep
+		for _, s := range steps {
+			joined = append(joined, s...)
+		}
+		return joined
+	}
+
+	tests := []struct {
+		name   string
+		policy AdmissionPolicy
+		steps  []admissionStep
+	}{
+		{
+			name:   "zero policy admits everything",
+			policy: AdmissionPolicy{},
+			steps:  admit("", 100),
+		},
+		{
+			name:   "burst is admitted at once",
+			policy: AdmissionPolicy{Limit: Limit{Rate: 2, Burst: 3}},
+			steps: join(
+				admit("", 3),
+				[]admissionStep{{op: "reject", reason: "more than 2 requests per second"}},
+			),
+		},
+		{
+			name:   "burst defaults to one second of the rate",
+			policy: AdmissionPolicy{Limit: Limit{Rate: 2.5}},
+			steps: join(
+				admit("", 3),
+				[]admissionStep{{op: "reject"}},
+			),
+		},
+		{
+			name:   "tokens refill at the rate",
+			policy: AdmissionPolicy{Limit: Limit{Rate: 2, Burst: 2}},
+			steps: join(
+				admit("", 2),
+				[]admissionStep{
+					{op: "reject"},
+					{op: "wait", wait: 250 * time.Millisecond},
+					{op: "reject"},
+					{op: "wait", wait: 250 * time.Millisecond},
+					{op: "admit"},
+					{op: "reject"},
+				},
+			),
+		},
+		{
+			name:   "refill stops at the burst",
+			policy: AdmissionPolicy{Limit: Limit{Rate: 2, Burst: 2}},
+			steps: join(
+				admit("", 2),
+				[]admissionStep{{op: "wait", wait: time.Minute}},
+				admit("", 2),
+				[]admissionStep{{op: "reject"}},
+			),
+		},
+		{
+			name:   "concurrency cap frees a slot on release",
+			policy: AdmissionPolicy{Limit: Limit{MaxConcurrent: 2}},
+			steps: join(
+				admit("", 2),
+				[]admissionStep{
+					{op: "reject", reason: "more than 2 requests in flight"},
+					{op: "release"},
+					{op: "admit"},
+					{op: "reject"},
+				},
+			),
+		},
+		{
+			name:   "callers are limited separately",
+			policy: AdmissionPolicy{PerCaller: Limit{Rate: 1, Burst: 1}},
+			steps: []admissionStep{
+				{op: "admit", caller: "a"},
+				{op: "reject", caller: "a", reason: "from a"},
+				{op: "admit", caller: "b"},
+				{op: "admit", caller: ""},
+				{op: "reject", caller: "", reason: "from the ingress"},
+			},
+		},
+		{
+			name: "caller overrides replace the per caller limit",
+			policy: AdmissionPolicy{
+				PerCaller: Limit{MaxConcurrent: 1},
+				Callers:   map[string]Limit{"batch": {MaxConcurrent: 3}},
+			},
+			steps: join(
+				admit("batch", 3),
+				[]admissionStep{
+					{op: "reject", caller: "batch"},
+					{op: "admit", caller: "a"},
+					{op: "reject", caller: "a"},
+				},
+			),
+		},
+		{
+			name: "a caller over its limit does not use up the method's",
+			policy: AdmissionPolicy{
+				Limit:     Limit{Rate: 1, Burst: 2},
+				PerCaller: Limit{Rate: 1, Burst: 1},
+			},
+			steps: []admissionStep{
+				{op: "admit", caller: "a"},
+				{op: "reject", caller: "a", reason: "from a"},
+				{op: "reject", caller: "a", reason: "from a"},
+				{op: "admit", caller: "b"},
+				{op: "reject", caller: "c", reason: "more than 1 requests per second"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			admission := newAdmissionClock(tt.policy, clock.Now)
+			var releases []func()
+
+			for i, step := range tt.steps {
+				switch step.op {
+				case "admit", "reject":
+					release, reason := admission.admit(step.caller)
+					if admitted := release != nil; admitted != (step.op == "admit") {
+						t.Fatalf("step %d: request of %q admitted %t, rejected for %q", i, step.caller, admitted, reason)
+					}
+					if release != nil {
+						releases = append(releases, release)
+					}
+					if !strings.Contains(reason, step.reason) {
+						t.Errorf("step %d: rejected for %q, want %q", i, reason, step.reason)
+					}
+				case "release":
+					releases[0]()
+					releases = releases[1:]
+				case "wait":
+					clock.advance(step.wait)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// umaskMu serializes the umask changes of listenLocalSocket, the umask is process wide
+var umaskMu sync.Mutex
+
+// ListenAttachSocket listens on a Unix socket at path for workers launched by hand,
+// e.g. under a debugger, to join the pools with ServeRemoteWorkers. A socket left
+// behind by a previous orchestrator is replaced. Only the current user may connect.
+func ListenAttachSocket(path string) (net.Listener, error) {
+	return listenLocalSocket(path)
+}
+
+// listenLocalSocket listens on a Unix socket at path only the current user may connect to,
+// replacing a socket left behind by a previous orchestrator. A missing directory is
+// created for the current user only.
+func listenLocalSocket(path string) (net.Listener, error) {
+	if err := checkSocketDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another orchestrator", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// The socket is created without access for others, chmod after Listen would leave a window
+	umaskMu.Lock()
+	umask := unix.Umask(0177)
+	lis, err := net.Listen("unix", path)
+	unix.Umask(umask)
+	umaskMu.Unlock()
+	return lis, err
+}
+
+// checkSocketDir creates dir for the current user only if it is missing. An existing dir
+// must not let other users swap the socket: it is owned by the current user or root,
+// and only writable by others with the sticky bit set, like /tmp.
+func checkSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != uint32(os.Getuid()) && stat.Uid != 0 {
+		return fmt.Errorf("%s is owned by another user", dir)
+	}
+	if info.Mode().Perm()&0022 != 0 && info.Mode()&fs.ModeSticky == 0 {
+		return fmt.Errorf("%s is writable by other users", dir)
+	}
+	return nil
+}
+
+// peerName describes where a remote worker connected from for logs
+func peerName(conn net.Conn) string {
+	if _, ok := conn.(*net.UnixConn); ok {
+		return fmt.Sprintf("attached on %s", conn.LocalAddr())
+	}
+	return fmt.Sprintf("remote %s", conn.RemoteAddr())
+}
@@ -0,0 +1,59 @@
+package orchestrator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenLocalSocketPermissions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pipes")
+	path := filepath.Join(dir, "admin.sock")
+
+	lis, err := listenLocalSocket(path)
+	if err != nil {
+		t.Fatalf("listenLocalSocket() failed: %v", err)
+	}
+	defer lis.Close()
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("socket directory created with %v, want 0700", perm)
+	}
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("socket created with %v, want no access for others", perm)
+	}
+
+	if _, err := listenLocalSocket(path); err == nil {
+		t.Error("listenLocalSocket() replaced a socket still in use")
+	}
+}
+
+func TestListenLocalSocketRefusesSharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	if lis, err := listenLocalSocket(filepath.Join(dir, "admin.sock")); err == nil {
+		lis.Close()
+		t.Error("listenLocalSocket() accepted a directory other users can write to")
+	}
+
+	// The sticky bit stops other users from removing the socket, as in /tmp
+	if err := os.Chmod(dir, 0777|os.ModeSticky); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := listenLocalSocket(filepath.Join(dir, "admin.sock"))
+	if err != nil {
+		t.Fatalf("listenLocalSocket() refused a sticky directory: %v", err)
+	}
+	lis.Close()
+}
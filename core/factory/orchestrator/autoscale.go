@@ -0,0 +1,267 @@
+package orchestrator
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// latencyWeight is how much a single observation moves a pool's latency average
+const latencyWeight = 0.2
+
+// ScalingPolicy bounds the number of workers in a pool and decides when it grows or shrinks
+type ScalingPolicy struct {
+	MinReplicas       int           // The pool never shrinks below this
+	MaxReplicas       int           // The pool never grows beyond this
+	TargetInFlight    float64       // Average in-flight requests per worker above which the pool is saturated
+	TargetLatency     time.Duration // Average latency above which the pool is saturated, 0 is half the pool's timeout, negative ignores latency
+	ScaleUpCooldown   time.Duration // Minimum time between two scale ups
+	ScaleDownCooldown time.Duration // How long the pool has to be idle before a worker is retired
+}
+
+// DefaultScalingPolicy returns a policy for a pool of between min and max workers
+func DefaultScalingPolicy(min int, max int) ScalingPolicy {
+	return ScalingPolicy{
+		MinReplicas:       min,
+		MaxReplicas:       max,
+		TargetInFlight:    4,
+		TargetLatency:     0,
+		ScaleUpCooldown:   2 * time.Second,
+		ScaleDownCooldown: 30 * time.Second,
+	}
+}
+
+// autoscaleInterval is how often the autoscaler looks at the pools
+const autoscaleInterval = time.Second
+
+// SetScalingPolicy enables autoscaling of the pool serving processType, a size pinned
+// by Scale is dropped. The pool is grown to policy.MinReplicas straight away.
+func (o *Orchestrator) SetScalingPolicy(processType string, policy ScalingPolicy) error {
+	if policy.MinReplicas < 1 || policy.MaxReplicas < policy.MinReplicas {
+		return fmt.Errorf("invalid replica bounds %d-%d", policy.MinReplicas, policy.MaxReplicas)
+	}
+
+	pool, exists := o.pool(processType)
+	if !exists {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.scaleMu.Lock()
+	pool.scaling = &policy
+	pool.pinned = nil
+	pool.scaleMu.Unlock()
+
+	for pool.Size() < policy.MinReplicas {
+		if _, err := o.spawnWorker(processType, DefaultVersion, pool.binary()); err != nil {
+			return err
+		}
+	}
+
+	o.autoscaleOnce.Do(func() { go o.autoscale() })
+	return nil
+}
+
+// Scale starts or retires workers of the pool serving processType until it runs replicas
+// workers of its DefaultVersion, the least busy workers are retired first. Remote workers
+// and other versions are left alone. The pool keeps that size, the autoscaler leaves it
+// alone until ResumeAutoscaling.
+func (o *Orchestrator) Scale(processType string, replicas int) error {
+	if replicas < 0 {
+		return fmt.Errorf("invalid replica count %d", replicas)
+	}
+	pool, exists := o.pool(processType)
+	if !exists {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.scaleMu.Lock()
+	defer pool.scaleMu.Unlock()
+	if pool.rolling.Load() {
+		return fmt.Errorf("a rollout of %s is in progress", processType)
+	}
+	pool.pinned = &replicas
+
+	workers := pool.localWorkers(DefaultVersion)
+	for i := len(workers); i < replicas; i++ {
+		if _, err := o.spawnWorker(processType, DefaultVersion, pool.binary()); err != nil {
+			return err
+		}
+	}
+	if len(workers) > replicas {
+		sort.Slice(workers, func(i, j int) bool { return workers[i].InFlight() < workers[j].InFlight() })
+		for _, worker := range workers[:len(workers)-replicas] {
+			o.retireWorker(pool, worker)
+		}
+	}
+
+	log.Printf("[Orchestrator] Scaled %s from %d to %d workers", processType, len(workers), replicas)
+	return nil
+}
+
+// ResumeAutoscaling lets the autoscaler size the pool serving processType again
+// after Scale pinned it, within the bounds of its ScalingPolicy
+func (o *Orchestrator) ResumeAutoscaling(processType string) error {
+	pool, exists := o.pool(processType)
+	if !exists {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.scaleMu.Lock()
+	defer pool.scaleMu.Unlock()
+	if pool.scaling == nil {
+		return fmt.Errorf("%s has no scaling policy", processType)
+	}
+	pool.pinned = nil
+	pool.idleSince = time.Time{}
+	log.Printf("[Orchestrator] Autoscaling %s between %d and %d workers again", processType, pool.scaling.MinReplicas, pool.scaling.MaxReplicas)
+	return nil
+}
+
+// autoscale periodically resizes every pool that has a scaling policy
+func (o *Orchestrator) autoscale() {
+	ticker := time.NewTicker(autoscaleInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if o.draining.Load() {
+			return
+		}
+
+		o.poolsMu.RLock()
+		pools := make(map[string]*WorkerPool, len(o.pools))
+		for processType, pool := range o.pools {
+			pools[processType] = pool
+		}
+		o.poolsMu.RUnlock()
+
+		for processType, pool := range pools {
+			o.scalePool(processType, pool, time.Now())
+		}
+	}
+}
+
+// scalePool adds a worker to a saturated pool, or retires one from a pool
+// that has been idle for the cooldown
+func (o *Orchestrator) scalePool(processType string, pool *WorkerPool, now time.Time) {
+	pool.scaleMu.Lock()
+	defer pool.scaleMu.Unlock()
+
+	policy := pool.scaling
+	if policy == nil || pool.pinned != nil || o.crashLooping(processType, DefaultVersion) || pool.rolling.Load() {
+		return
+	}
+
+	workers := pool.snapshot()
+	var inFlight int64
+	for _, worker := range workers {
+		inFlight += worker.inFlight.Load()
+	}
+
+	size := len(workers)
+	load := float64(inFlight)
+	if size > 0 {
+		load /= float64(size)
+	}
+	latency := pool.Latency()
+	targetLatency := policy.TargetLatency
+	if targetLatency == 0 {
+		timeout, _, _ := pool.limits()
+		targetLatency = timeout / 2
+	}
+
+	// The latency average only moves with traffic, so it is ignored while the pool is idle
+	saturated := load >= policy.TargetInFlight ||
+		(targetLatency > 0 && inFlight > 0 && latency > targetLatency)
+
+	switch {
+	case size < policy.MinReplicas || (saturated && size < policy.MaxReplicas):
+		pool.idleSince = time.Time{}
+		if now.Sub(pool.lastScaleUp) < policy.ScaleUpCooldown {
+			return
+		}
+		pool.lastScaleUp = now
+
+		log.Printf("[Orchestrator] Scaling up %s: %d workers, %.1f in flight per worker, %v latency", processType, size, load, latency)
+		if _, err := o.spawnWorker(processType, DefaultVersion, pool.binary()); err != nil {
+			log.Printf("[Orchestrator] Failed to scale up %s: %v", processType, err)
+		}
+
+	case load < policy.TargetInFlight/2 && size > policy.MinReplicas:
+		if pool.idleSince.IsZero() {
+			pool.idleSince = now
+			return
+		}
+		if now.Sub(pool.idleSince) < policy.ScaleDownCooldown {
+			return
+		}
+
+		// Retire the least busy worker, further retirements wait for another cooldown.
+		// Remote workers were started elsewhere and are left alone, as are other versions.
+		var idlest *Worker
+		for _, worker := range pool.localWorkers(DefaultVersion) {
+			if idlest == nil || worker.inFlight.Load() < idlest.inFlight.Load() {
+				idlest = worker
+			}
+		}
+		pool.idleSince = now
+		if idlest == nil {
+			return
+		}
+
+		log.Printf("[Orchestrator] Scaling down %s: retiring %s, %d workers, %.1f in flight per worker", processType, idlest.id, size, load)
+		o.retireWorker(pool, idlest)
+
+	default:
+		pool.idleSince = time.Time{}
+	}
+}
+
+// atCapacity reports whether an autoscaled pool already has its maximum number of workers,
+// or a pool pinned by Scale its pinned number of local workers
+func (p *WorkerPool) atCapacity() bool {
+	p.scaleMu.Lock()
+	policy, pinned := p.scaling, p.pinned
+	p.scaleMu.Unlock()
+
+	if pinned != nil {
+		return len(p.localWorkers(DefaultVersion)) >= *pinned
+	}
+	return policy != nil && p.Size() >= policy.MaxReplicas
+}
+
+// retireWorker takes a worker out of rotation and lets it drain.
+// Requests already sent to it are allowed to finish.
+func (o *Orchestrator) retireWorker(pool *WorkerPool, worker *Worker) {
+	if pool.removeWorker(worker) {
+		o.retiring.Store(worker, struct{}{})
+		worker.drain()
+	}
+}
+
+// recordLatency folds the duration of an answered attempt, or the timeout of one that got
+// no answer, into the pool's average and keeps it as a sample for latencyPercentile
+func (p *WorkerPool) recordLatency(latency time.Duration) {
+	p.latencyMu.Lock()
+	defer p.latencyMu.Unlock()
+
+	if len(p.samples) < latencySamples {
+		p.samples = append(p.samples, latency)
+	} else {
+		p.samples[p.sampleAt] = latency
+		p.sampleAt = (p.sampleAt + 1) % latencySamples
+	}
+
+	if p.latency == 0 {
+		p.latency = latency
+		return
+	}
+	p.latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(p.latency))
+}
+
+// Latency returns the moving average of the pool's request latency
+func (p *WorkerPool) Latency() time.Duration {
+	p.latencyMu.Lock()
+	defer p.latencyMu.Unlock()
+	return p.latency
+}
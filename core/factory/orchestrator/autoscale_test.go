@@ -0,0 +1,67 @@
+package orchestrator
+
+import (
+	"io"
+	"net"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// localTestWorker returns a worker that counts as started by the orchestrator,
+// whose connection swallows the packets sent to it
+func localTestWorker(t *testing.T, id string) *Worker {
+	conn, workerSide := net.Pipe()
+	go io.Copy(io.Discard, workerSide)
+	t.Cleanup(func() { conn.Close() })
+
+	return NewWorker(id, "test", "/nonexistent", conn, &exec.Cmd{}, nil)
+}
+
+func TestScalePinsAutoscaledPool(t *testing.T) {
+	o := NewOrchestrator()
+	a, b := localTestWorker(t, "a"), localTestWorker(t, "b")
+	pool := NewWorkerPool([]*Worker{a, b}, time.Second, 0)
+	pool.binaryPath = "/nonexistent"
+	policy := DefaultScalingPolicy(1, 4)
+	pool.scaling = &policy
+	o.pools["test"] = pool
+
+	b.inFlight.Add(1)
+	if err := o.Scale("test", 1); err != nil {
+		t.Fatalf("Scale() of an autoscaled pool failed: %v", err)
+	}
+	if workers := pool.snapshot(); len(workers) != 1 || workers[0] != b {
+		t.Fatalf("Scale() kept %v, want the busy worker b", workers)
+	}
+
+	// A saturated pinned pool is not grown, and only restarts workers up to its pinned size
+	b.inFlight.Add(10)
+	o.scalePool("test", pool, time.Now())
+	if !pool.lastScaleUp.IsZero() {
+		t.Error("the autoscaler grew a pinned pool")
+	}
+	if !pool.atCapacity() {
+		t.Error("pinned pool at its size is not at capacity")
+	}
+
+	if err := o.ResumeAutoscaling("test"); err != nil {
+		t.Fatalf("ResumeAutoscaling() failed: %v", err)
+	}
+	if pool.atCapacity() {
+		t.Error("pool below its MaxReplicas is at capacity after ResumeAutoscaling")
+	}
+	o.scalePool("test", pool, time.Now())
+	if pool.lastScaleUp.IsZero() {
+		t.Error("the autoscaler did not grow the saturated pool after ResumeAutoscaling")
+	}
+}
+
+func TestResumeAutoscalingNeedsPolicy(t *testing.T) {
+	o := NewOrchestrator()
+	o.pools["test"] = NewWorkerPool(nil, time.Second, 0)
+
+	if err := o.ResumeAutoscaling("test"); err == nil {
+		t.Error("ResumeAutoscaling() accepted a pool without a scaling policy")
+	}
+}
@@ -0,0 +1,173 @@
+package orchestrator
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// Balancer picks which worker of a pool a request is sent to.
+// Select is given the healthy workers of the pool, it is never empty,
+// and is called concurrently from every request being routed.
+type Balancer interface {
+	Select(workers []*Worker) *Worker
+}
+
+// RoundRobinBalancer hands requests to each worker in turn
+type RoundRobinBalancer struct {
+	next atomic.Uint64
+}
+
+// NewRoundRobinBalancer returns the balancer pools use by default
+func NewRoundRobinBalancer() *RoundRobinBalancer {
+	return &RoundRobinBalancer{}
+}
+
+func (b *RoundRobinBalancer) Select(workers []*Worker) *Worker {
+	return workers[(b.next.Add(1)-1)%uint64(len(workers))]
+}
+
+// LeastOutstandingBalancer sends each request to the worker with the fewest requests in flight.
+// Ties are broken from a random starting point so equally loaded workers share the traffic.
+type LeastOutstandingBalancer struct{}
+
+// NewLeastOutstandingBalancer returns a least-outstanding-requests balancer
+func NewLeastOutstandingBalancer() *LeastOutstandingBalancer {
+	return &LeastOutstandingBalancer{}
+}
+
+func (b *LeastOutstandingBalancer) Select(workers []*Worker) *Worker {
+	start := rand.IntN(len(workers))
+	best := workers[start]
+	for i := 1; i < len(workers); i++ {
+		worker := workers[(start+i)%len(workers)]
+		if worker.InFlight() < best.InFlight() {
+			best = worker
+		}
+	}
+	return best
+}
+
+// PowerOfTwoBalancer picks two workers at random and sends the request to the less loaded one.
+// It is nearly as good as least-outstanding while only looking at two workers.
+type PowerOfTwoBalancer struct{}
+
+// NewPowerOfTwoBalancer returns a power-of-two-choices balancer
+func NewPowerOfTwoBalancer() *PowerOfTwoBalancer {
+	return &PowerOfTwoBalancer{}
+}
+
+func (b *PowerOfTwoBalancer) Select(workers []*Worker) *Worker {
+	if len(workers) == 1 {
+		return workers[0]
+	}
+
+	i := rand.IntN(len(workers))
+	j := rand.IntN(len(workers) - 1)
+	if j >= i {
+		j++ // j is any index but i
+	}
+
+	if workers[j].InFlight() < workers[i].InFlight() {
+		return workers[j]
+	}
+	return workers[i]
+}
+
+// WeightedBalancer spreads requests across workers in proportion to their weight,
+// using smooth weighted round robin so heavy workers are not picked in bursts.
+type WeightedBalancer struct {
+	weight func(*Worker) int
+
+	mu      sync.Mutex
+	current map[*Worker]int // The running score of each worker
+}
+
+// NewWeightedBalancer returns a balancer weighting each worker with weight.
+// Workers with a weight of 0 or less are only picked if no other worker is left.
+func NewWeightedBalancer(weight func(*Worker) int) *WeightedBalancer {
+	return &WeightedBalancer{
+		weight:  weight,
+		current: make(map[*Worker]int),
+	}
+}
+
+func (b *WeightedBalancer) Select(workers []*Worker) *Worker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Forget workers that are gone. workers is often a subset of the pool, e.g. the workers
+	// with a free slot, the scores of the others are kept for when they are candidates again.
+	if len(b.current) > len(workers) {
+		for worker := range b.current {
+			select {
+			case <-worker.exited:
+				delete(b.current, worker)
+			default:
+			}
+		}
+	}
+
+	var best *Worker
+	total := 0
+	for _, worker := range workers {
+		weight := b.weight(worker)
+		if weight <= 0 {
+			continue
+		}
+		total += weight
+		b.current[worker] += weight
+		if best == nil || b.current[worker] > b.current[best] {
+			best = worker
+		}
+	}
+
+	if best == nil {
+		return workers[0]
+	}
+	b.current[best] -= total
+	return best
+}
+
+// defaultCapacityWeight is the weight CapacityWeight gives workers that advertise no limit,
+// that of a worker with the processes package's default limit
+const defaultCapacityWeight = 100
+
+// CapacityWeight weighs a worker by the concurrency limit it advertises with its PONGs,
+// so larger workers get a larger share of the traffic
+func CapacityWeight(worker *Worker) int {
+	if limit := int(worker.Status().GetMaxConcurrency()); limit > 0 {
+		return limit
+	}
+	return defaultCapacityWeight
+}
+
+// NewBalancer returns the built-in balancer with the given name:
+// "round-robin", "least-outstanding", "power-of-two" or "weighted".
+// The weighted balancer weighs workers with CapacityWeight, other weights are
+// given to NewWeightedBalancer.
+func NewBalancer(name string) (Balancer, error) {
+	switch name {
+	case "weighted":
+		return NewWeightedBalancer(CapacityWeight), nil
+	case "round-robin":
+		return NewRoundRobinBalancer(), nil
+	case "least-outstanding":
+		return NewLeastOutstandingBalancer(), nil
+	case "power-of-two":
+		return NewPowerOfTwoBalancer(), nil
+	default:
+		return nil, fmt.Errorf("unknown balancer %q", name)
+	}
+}
+
+// SetBalancer replaces the strategy the pool serving processType uses to pick workers
+func (o *Orchestrator) SetBalancer(processType string, balancer Balancer) error {
+	pool, ok := o.pool(processType)
+	if !ok {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+	pool.SetBalancer(balancer)
+	return nil
+}
@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/bsmider/pipes/core/factory"
+)
+
+// testWorker returns a worker without a process or connection, for balancer and queue tests
+func testWorker(id string) *Worker {
+	return NewWorker(id, "test", "", nil, nil, nil)
+}
+
+func TestWeightedBalancerCandidateSubsets(t *testing.T) {
+	a, b, c := testWorker("a"), testWorker("b"), testWorker("c")
+	weights := map[*Worker]int{a: 3, b: 1, c: 1}
+	balancer := NewWeightedBalancer(func(w *Worker) int { return weights[w] })
+
+	// Calls given only some workers, e.g. those with a free slot, must not reset the others' scores
+	picked := map[*Worker]int{}
+	for i := 0; i < 500; i++ {
+		picked[balancer.Select([]*Worker{a, b, c})]++
+		balancer.Select([]*Worker{c})
+	}
+
+	if picked[a] != 300 || picked[b] != 100 || picked[c] != 100 {
+		t.Errorf("picked a=%d b=%d c=%d, want 300, 100 and 100", picked[a], picked[b], picked[c])
+	}
+}
+
+func TestWeightedBalancerForgetsExitedWorkers(t *testing.T) {
+	a, b := testWorker("a"), testWorker("b")
+	balancer := NewWeightedBalancer(func(*Worker) int { return 1 })
+	balancer.Select([]*Worker{a, b})
+
+	balancer.Select([]*Worker{a})
+	if len(balancer.current) != 2 {
+		t.Fatalf("scores of %d workers kept, want 2 while b is only busy", len(balancer.current))
+	}
+
+	close(b.exited)
+	balancer.Select([]*Worker{a})
+	if _, ok := balancer.current[b]; ok || len(balancer.current) != 1 {
+		t.Errorf("score of the exited worker kept: %v", balancer.current)
+	}
+}
+
+func TestCapacityWeight(t *testing.T) {
+	worker := testWorker("a")
+	if got := CapacityWeight(worker); got != defaultCapacityWeight {
+		t.Errorf("CapacityWeight() before the first pong = %d, want %d", got, defaultCapacityWeight)
+	}
+
+	worker.status.Store(&factory.WorkerStatus{MaxConcurrency: 8})
+	if got := CapacityWeight(worker); got != 8 {
+		t.Errorf("CapacityWeight() = %d, want 8", got)
+	}
+}
+
+func TestNewBalancer(t *testing.T) {
+	for _, name := range []string{"round-robin", "least-outstanding", "power-of-two", "weighted"} {
+		if _, err := NewBalancer(name); err != nil {
+			t.Errorf("NewBalancer(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := NewBalancer("random"); err == nil {
+		t.Error("NewBalancer accepted an unknown name")
+	}
+}
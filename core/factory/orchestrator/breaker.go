@@ -0,0 +1,226 @@
+package orchestrator
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BreakerState is the state of a pool's circuit breaker
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // Requests flow normally
+	BreakerOpen                         // Requests fail fast with UNAVAILABLE
+	BreakerHalfOpen                     // A few probe requests decide whether to close again
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// breakerFailureCodes are the statuses that count against a pool's health.
+// Other errors are answers of a working method, e.g. INVALID_ARGUMENT.
+var breakerFailureCodes = []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown}
+
+// BreakerPolicy decides when a pool's circuit breaker opens and closes again.
+// A zero policy never opens the breaker.
+type BreakerPolicy struct {
+	ConsecutiveFailures int           // Failures in a row that open the breaker, 0 disables the check
+	FailureRate         float64       // Share of failed requests in a window that opens the breaker, 0 disables the check
+	MinRequests         int           // Requests a window needs before FailureRate applies
+	Window              time.Duration // Span over which the failure rate is measured
+	OpenDuration        time.Duration // How long the breaker fails fast before letting probes through
+	HalfOpenProbes      int           // Probes allowed at once while half-open, that must all succeed to close
+}
+
+// DefaultBreakerPolicy returns the breaker policy pools start out with
+func DefaultBreakerPolicy() BreakerPolicy {
+	return BreakerPolicy{
+		ConsecutiveFailures: 5,
+		FailureRate:         0.5,
+		MinRequests:         20,
+		Window:              10 * time.Second,
+		OpenDuration:        5 * time.Second,
+		HalfOpenProbes:      1,
+	}
+}
+
+// CircuitBreaker stops sending requests to a pool whose workers keep failing
+type CircuitBreaker struct {
+	name string           // The process type of the pool, for logging
+	now  func() time.Time // The breaker's clock, replaced in tests
+
+	mu          sync.Mutex
+	policy      BreakerPolicy
+	state       BreakerState
+	generation  uint64    // Bumped on every state change, outcomes of older requests are ignored
+	openedAt    time.Time // When the breaker last opened
+	consecutive int       // Failures in a row while closed
+	windowStart time.Time
+	requests    int // Outcomes recorded in the current window
+	failures    int // Failed outcomes in the current window
+	probes      int // Probes in flight while half-open
+	successes   int // Successful probes while half-open
+}
+
+// NewCircuitBreaker returns a closed breaker for the pool of processType
+func NewCircuitBreaker(processType string, policy BreakerPolicy) *CircuitBreaker {
+	return &CircuitBreaker{name: processType, now: time.Now, policy: policy}
+}
+
+// SetPolicy replaces the breaker's policy and closes it
+func (b *CircuitBreaker) SetPolicy(policy BreakerPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.policy = policy
+	b.setState(BreakerClosed)
+}
+
+// State returns the current state of the breaker
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireOpen()
+	return b.state
+}
+
+// allow reports whether a request may be sent. The returned generation is
+// handed back to record with the request's outcome.
+func (b *CircuitBreaker) allow() (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.expireOpen()
+	switch b.state {
+	case BreakerOpen:
+		return 0, false
+	case BreakerHalfOpen:
+		if b.probes >= max(b.policy.HalfOpenProbes, 1) {
+			return 0, false
+		}
+		b.probes++
+	}
+	return b.generation, true
+}
+
+// record folds the outcome of a request allowed in generation into the breaker
+func (b *CircuitBreaker) record(generation uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probes--
+		if failed {
+			b.setState(BreakerOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= max(b.policy.HalfOpenProbes, 1) {
+			b.setState(BreakerClosed)
+		}
+
+	case BreakerClosed:
+		now := b.now()
+		if now.Sub(b.windowStart) > b.policy.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if !failed {
+			b.consecutive = 0
+			return
+		}
+		b.failures++
+		b.consecutive++
+
+		tooMany := b.policy.ConsecutiveFailures > 0 && b.consecutive >= b.policy.ConsecutiveFailures
+		tooOften := b.policy.FailureRate > 0 && b.requests >= b.policy.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.policy.FailureRate
+		if tooMany || tooOften {
+			b.setState(BreakerOpen)
+		}
+	}
+}
+
+// forget releases a request allowed in generation whose outcome says nothing
+// about the pool, e.g. because the caller gave up
+func (b *CircuitBreaker) forget(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation == b.generation && b.state == BreakerHalfOpen {
+		b.probes--
+	}
+}
+
+// expireOpen lets probes through once the breaker has been open long enough
+func (b *CircuitBreaker) expireOpen() {
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.policy.OpenDuration {
+		b.setState(BreakerHalfOpen)
+	}
+}
+
+// setState moves the breaker to state, starting it over
+func (b *CircuitBreaker) setState(state BreakerState) {
+	if state != b.state {
+		log.Printf("[Orchestrator] Circuit breaker for %s is %s, was %s", b.name, state, b.state)
+	}
+
+	b.state = state
+	b.generation++
+	b.consecutive, b.requests, b.failures = 0, 0, 0
+	b.windowStart = b.now()
+	b.probes, b.successes = 0, 0
+	if state == BreakerOpen {
+		b.openedAt = b.windowStart
+	}
+}
+
+// breakerFailed reports whether the outcome of routing a request counts against the pool
+func breakerFailed(response *factory.Packet, err error) bool {
+	if err == nil {
+		err = response.Error.ToGoError()
+	}
+	if err == nil {
+		return false
+	}
+	return slices.Contains(breakerFailureCodes, status.Code(err))
+}
+
+// BreakerState returns the state of the circuit breaker of the pool serving processType
+func (o *Orchestrator) BreakerState(processType string) (BreakerState, error) {
+	pool, ok := o.pool(processType)
+	if !ok {
+		return BreakerClosed, fmt.Errorf("no pool for target type: %s", processType)
+	}
+	return pool.breaker.State(), nil
+}
+
+// SetBreakerPolicy replaces the circuit breaker policy of the pool serving processType
+func (o *Orchestrator) SetBreakerPolicy(processType string, policy BreakerPolicy) error {
+	pool, ok := o.pool(processType)
+	if !ok {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+	pool.breaker.SetPolicy(policy)
+	return nil
+}
@@ -0,0 +1,199 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+)
+
+// testClock is a clock that only moves when told to
+type testClock struct {
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Unix(1_000_000, 0)}
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// breakerStep is one thing that happens to a breaker in TestCircuitBreaker
+type breakerStep struct {
+	op     string // "allow" takes a ticket, "reject" expects allow to refuse, "ok", "fail" and "forget" settle a ticket, "wait" advances the clock
+	ticket string
+	wait   time.Duration
+	want   BreakerState // The state after the step
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	consecutive := BreakerPolicy{ConsecutiveFailures: 3, OpenDuration: 5 * time.Second, HalfOpenProbes: 1}
+	rate := BreakerPolicy{FailureRate: 0.5, MinRequests: 4, Window: 10 * time.Second, OpenDuration: 5 * time.Second}
+	single := BreakerPolicy{ConsecutiveFailures: 1, OpenDuration: 5 * time.Second, HalfOpenProbes: 1}
+
+	// request settles a ticket taken while the breaker was closed
+	request := func(ticket string, op string, want BreakerState) []breakerStep {
+		return []breakerStep{{op: "allow", ticket: ticket, want: BreakerClosed}, {op: op, ticket: ticket, want: want}}
+	}
+	join := func(steps ...[]breakerStep) []breakerStep {
+		var joined []breakerStep
+		for _, s := range steps {
+			joined = append(joined, s...)
+		}
+		return joined
+	}
+
+	tests := []struct {
+		name   string
+		policy BreakerPolicy
+		steps  []breakerStep
+	}{
+		{
+			name:   "consecutive failures open the breaker",
+			policy: consecutive,
+			steps: join(
+				request("a", "fail", BreakerClosed),
+				request("b", "fail", BreakerClosed),
+				request("c", "fail", BreakerOpen),
+				[]breakerStep{{op: "reject", want: BreakerOpen}},
+			),
+		},
+		{
+			name:   "a success resets the consecutive failures",
+			policy: consecutive,
+			steps: join(
+				request("a", "fail", BreakerClosed),
+				request("b", "fail", BreakerClosed),
+				request("c", "ok", BreakerClosed),
+				request("d", "fail", BreakerClosed),
+				request("e", "fail", BreakerClosed),
+			),
+		},
+		{
+			name:   "failure rate opens the breaker once the window has enough requests",
+			policy: rate,
+			steps: join(
+				request("a", "fail", BreakerClosed),
+				request("b", "ok", BreakerClosed),
+				request("c", "fail", BreakerClosed),
+				request("d", "fail", BreakerOpen),
+			),
+		},
+		{
+			name:   "failures of a past window do not count",
+			policy: rate,
+			steps: join(
+				request("a", "fail", BreakerClosed),
+				request("b", "fail", BreakerClosed),
+				request("c", "fail", BreakerClosed),
+				[]breakerStep{{op: "wait", wait: 11 * time.Second, want: BreakerClosed}},
+				request("d", "fail", BreakerClosed),
+				request("e", "ok", BreakerClosed),
+				request("f", "ok", BreakerClosed),
+			),
+		},
+		{
+			name:   "open breaker lets a probe through after the open duration, its success closes it",
+			policy: single,
+			steps: join(
+				request("a", "fail", BreakerOpen),
+				[]breakerStep{
+					{op: "wait", wait: 4 * time.Second, want: BreakerOpen},
+					{op: "reject", want: BreakerOpen},
+					{op: "wait", wait: time.Second, want: BreakerHalfOpen},
+					{op: "allow", ticket: "probe", want: BreakerHalfOpen},
+					{op: "reject", want: BreakerHalfOpen},
+					{op: "ok", ticket: "probe", want: BreakerClosed},
+				},
+			),
+		},
+		{
+			name:   "a failed probe opens the breaker again",
+			policy: single,
+			steps: join(
+				request("a", "fail", BreakerOpen),
+				[]breakerStep{
+					{op: "wait", wait: 5 * time.Second, want: BreakerHalfOpen},
+					{op: "allow", ticket: "probe", want: BreakerHalfOpen},
+					{op: "fail", ticket: "probe", want: BreakerOpen},
+					{op: "reject", want: BreakerOpen},
+				},
+			),
+		},
+		{
+			name:   "a forgotten probe frees its slot",
+			policy: single,
+			steps: join(
+				request("a", "fail", BreakerOpen),
+				[]breakerStep{
+					{op: "wait", wait: 5 * time.Second, want: BreakerHalfOpen},
+					{op: "allow", ticket: "probe", want: BreakerHalfOpen},
+					{op: "forget", ticket: "probe", want: BreakerHalfOpen},
+					{op: "allow", ticket: "probe2", want: BreakerHalfOpen},
+					{op: "ok", ticket: "probe2", want: BreakerClosed},
+				},
+			),
+		},
+		{
+			name:   "outcomes of a past generation are ignored",
+			policy: single,
+			steps: []breakerStep{
+				{op: "allow", ticket: "slow", want: BreakerClosed},
+				{op: "allow", ticket: "a", want: BreakerClosed},
+				{op: "fail", ticket: "a", want: BreakerOpen},
+				{op: "wait", wait: 5 * time.Second, want: BreakerHalfOpen},
+				{op: "fail", ticket: "slow", want: BreakerHalfOpen},
+				{op: "allow", ticket: "probe", want: BreakerHalfOpen},
+				{op: "forget", ticket: "slow", want: BreakerHalfOpen},
+				{op: "reject", want: BreakerHalfOpen},
+			},
+		},
+		{
+			name:   "a zero policy never opens",
+			policy: BreakerPolicy{},
+			steps: join(
+				request("a", "fail", BreakerClosed),
+				request("b", "fail", BreakerClosed),
+				request("c", "fail", BreakerClosed),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			breaker := NewCircuitBreaker("test", tt.policy)
+			breaker.now = clock.Now
+			tickets := map[string]uint64{}
+
+			for i, step := range tt.steps {
+				switch step.op {
+				case "allow":
+					generation, ok := breaker.allow()
+					if !ok {
+						t.Fatalf("step %d: allow refused %s", i, step.ticket)
+					}
+					tickets[step.ticket] = generation
+				case "reject":
+					if _, ok := breaker.allow(); ok {
+						t.Fatalf("step %d: allow let a request through", i)
+					}
+				case "ok", "fail":
+					breaker.record(tickets[step.ticket], step.op == "fail")
+				case "forget":
+					breaker.forget(tickets[step.ticket])
+				case "wait":
+					clock.advance(step.wait)
+				}
+
+				if got := breaker.State(); got != step.want {
+					t.Fatalf("step %d (%s %s): state %s, want %s", i, step.op, step.ticket, got, step.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package orchestrator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recentErrorsKept is how many errors the orchestrator remembers for RecentErrors
+const recentErrorsKept = 100
+
+// RecentError is a failed attempt of a request, or a worker that exited without being asked to
+type RecentError struct {
+	Time     time.Time
+	MethodID string
+	PacketID string // empty for errors not tied to a request
+	WorkerID string
+	Code     codes.Code
+	Message  string
+}
+
+// errorLog is a ring of the most recent errors
+type errorLog struct {
+	mu     sync.Mutex
+	errors []RecentError
+	next   int // where the next error is written once the ring is full
+	size   int
+}
+
+func newErrorLog(size int) *errorLog {
+	return &errorLog{size: size}
+}
+
+// record adds an error to the log, replacing the oldest one once the log is full
+func (l *errorLog) record(e RecentError) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.errors) < l.size {
+		l.errors = append(l.errors, e)
+		return
+	}
+	l.errors[l.next] = e
+	l.next = (l.next + 1) % l.size
+}
+
+// recent returns up to limit errors, most recent first. A limit of 0 returns them all.
+func (l *errorLog) recent(limit int) []RecentError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := len(l.errors)
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	recent := make([]RecentError, 0, limit)
+	for i := 0; i < limit; i++ {
+		// The newest error sits just before next
+		recent = append(recent, l.errors[(l.next-1-i+2*n)%n])
+	}
+	return recent
+}
+
+// RecentErrors returns up to limit of the latest request failures and unexpected worker exits,
+// most recent first. A limit of 0 returns every error kept.
+func (o *Orchestrator) RecentErrors(limit int) []RecentError {
+	return o.errors.recent(limit)
+}
+
+// recordError remembers that the attempt to send packet to worker failed with st
+func (o *Orchestrator) recordError(packet *factory.Packet, worker *Worker, st *status.Status) {
+	o.errors.record(RecentError{
+		Time:     time.Now(),
+		MethodID: packet.TargetIoType,
+		PacketID: packet.Id,
+		WorkerID: worker.id,
+		Code:     st.Code(),
+		Message:  st.Message(),
+	})
+}
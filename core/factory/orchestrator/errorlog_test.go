@@ -0,0 +1,49 @@
+package orchestrator
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestErrorLogRecent(t *testing.T) {
+	const size = 5
+
+	tests := []struct {
+		name     string
+		recorded int
+		limit    int
+		want     []string // Packet ids, most recent first
+	}{
+		{"empty", 0, 0, nil},
+		{"not full", 3, 0, []string{"2", "1", "0"}},
+		{"just full", size, 0, []string{"4", "3", "2", "1", "0"}},
+		{"wrapped around", 7, 0, []string{"6", "5", "4", "3", "2"}},
+		{"wrapped around more than once", 13, 0, []string{"12", "11", "10", "9", "8"}},
+		{"limit below the errors kept", 13, 2, []string{"12", "11"}},
+		{"limit above the errors kept", 3, 10, []string{"2", "1", "0"}},
+		{"negative limit returns them all", 7, -1, []string{"6", "5", "4", "3", "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := newErrorLog(size)
+			for i := 0; i < tt.recorded; i++ {
+				log.record(RecentError{PacketID: strconv.Itoa(i)})
+			}
+
+			recent := log.recent(tt.limit)
+			var got []string
+			for _, e := range recent {
+				got = append(got, e.PacketID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("recent(%d) = %v, want %v", tt.limit, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("recent(%d) = %v, want %v", tt.limit, got, tt.want)
+				}
+			}
+		})
+	}
+}
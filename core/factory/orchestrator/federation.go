@@ -0,0 +1,368 @@
+package orchestrator
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"github.com/bsmider/pipes/core/factory/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// peerHopPrefix marks the hop recorded when a packet arrives from a peer orchestrator
+const peerHopPrefix = "peer:"
+
+// peerTimeout bounds a forwarded request that carries no deadline of its own
+const peerTimeout = 30 * time.Second
+
+// Redials of a lost peer back off between these delays
+const (
+	peerInitialBackoff = time.Second
+	peerMaxBackoff     = 30 * time.Second
+)
+
+// Peer is another orchestrator requests are forwarded to for the methods it serves.
+// Peers forward requests only to their own pools, never on to their peers.
+type Peer struct {
+	name    string
+	conn    net.Conn
+	writeMu sync.Mutex
+	done    chan struct{} // closed once the connection to the peer is gone
+
+	mu      sync.RWMutex
+	methods map[string]bool // the methods the peer announced
+}
+
+// Name returns the name the peer announced
+func (p *Peer) Name() string {
+	return p.name
+}
+
+// Methods returns the method IDs the peer serves
+func (p *Peer) Methods() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	methods := make([]string, 0, len(p.methods))
+	for methodID := range p.methods {
+		methods = append(methods, methodID)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (p *Peer) serves(methodID string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.methods[methodID]
+}
+
+// update replaces the peer's methods with those of an announcement
+func (p *Peer) update(announcement *factory.PeerAnnouncement) {
+	methods := make(map[string]bool, len(announcement.MethodIds))
+	for _, methodID := range announcement.MethodIds {
+		methods[methodID] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.methods = methods
+}
+
+func (p *Peer) sendPacket(packet *factory.Packet) error {
+	return utils.WriteMessage(p.conn, &p.writeMu, packet)
+}
+
+// cancel tells the peer to stop routing the request packet
+func (p *Peer) cancel(packet *factory.Packet) {
+	cancel := factory.NewPacket(packet.Id, factory.PacketType_PACKET_TYPE_CANCEL, packet.TargetIoType, nil, nil, nil)
+	if err := p.sendPacket(cancel); err != nil {
+		log.Printf("[Orchestrator] Failed to cancel %s on peer %s: %v", packet.Id, p.name, err)
+	}
+}
+
+// SetName sets the name announced to peers, it defaults to the host name
+func (o *Orchestrator) SetName(name string) {
+	o.peersMu.Lock()
+	defer o.peersMu.Unlock()
+	o.name = name
+}
+
+// Peers returns the peers currently connected
+func (o *Orchestrator) Peers() []*Peer {
+	o.peersMu.RLock()
+	defer o.peersMu.RUnlock()
+	return append([]*Peer(nil), o.peers...)
+}
+
+// ServePeers accepts peer orchestrators on lis. lis should authenticate them,
+// e.g. a listener from tls.NewListener with utils.ServerTLSConfig.
+// It blocks until lis is closed.
+func (o *Orchestrator) ServePeers(lis net.Listener) error {
+	log.Printf("[Orchestrator] Accepting peers on %s", lis.Addr())
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go func() {
+			peer, err := o.handshakePeer(conn)
+			if err != nil {
+				log.Printf("[Orchestrator] Refused peer %s: %v", conn.RemoteAddr(), err)
+				return
+			}
+			o.servePeer(peer)
+		}()
+	}
+}
+
+// AddPeer peers with the orchestrator serving peers on addr, authenticating with config,
+// and redials it whenever the connection is lost. A nil config dials plain TCP.
+func (o *Orchestrator) AddPeer(addr string, config *tls.Config) {
+	go func() {
+		// Failed dials in a row, a peer that was connected starts over from the initial delay
+		failures := 0
+		for !o.draining.Load() {
+			peer, err := o.dialPeer(addr, config)
+			if err == nil {
+				failures = 0
+				o.servePeer(peer)
+				err = fmt.Errorf("connection closed")
+			}
+			if o.draining.Load() {
+				return
+			}
+
+			delay := backoff(peerInitialBackoff, peerMaxBackoff, failures)
+			failures++
+			log.Printf("[Orchestrator] Lost peer %s: %v, redialing in %v", addr, err, delay)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-o.stopping:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// dialPeer connects to the peer on addr and exchanges announcements
+func (o *Orchestrator) dialPeer(addr string, config *tls.Config) (*Peer, error) {
+	dialer := &net.Dialer{Timeout: registerTimeout}
+
+	var conn net.Conn
+	var err error
+	if config != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, config)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o.handshakePeer(conn)
+}
+
+// handshakePeer sends this orchestrator's announcement on conn, reads the peer's
+// and registers the peer. The connection is closed if the handshake fails.
+func (o *Orchestrator) handshakePeer(conn net.Conn) (*Peer, error) {
+	conn.SetDeadline(time.Now().Add(registerTimeout))
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if err := utils.WriteMessage(conn, nil, o.announcement()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	hello := &factory.Packet{}
+	if err := utils.ReadMessageLimit(conn, hello, utils.MaxHandshakeSize); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	announcement := &factory.PeerAnnouncement{}
+	if hello.Type != factory.PacketType_PACKET_TYPE_PEER {
+		conn.Close()
+		return nil, fmt.Errorf("expected a peer packet, got %s", hello.Type)
+	}
+	if err := proto.Unmarshal(hello.Payload, announcement); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("invalid announcement: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	peer := &Peer{name: announcement.Name, conn: conn, done: make(chan struct{})}
+	peer.update(announcement)
+
+	o.peersMu.Lock()
+	o.peers = append(o.peers, peer)
+	o.peersMu.Unlock()
+
+	log.Printf("[Orchestrator] Peered with %s (%s), it serves %d methods", peer.name, conn.RemoteAddr(), len(announcement.MethodIds))
+	return peer, nil
+}
+
+// servePeer handles the packets the peer sends until its connection is gone
+func (o *Orchestrator) servePeer(peer *Peer) {
+	defer func() {
+		o.peersMu.Lock()
+		for i, p := range o.peers {
+			if p == peer {
+				o.peers = append(o.peers[:i], o.peers[i+1:]...)
+				break
+			}
+		}
+		o.peersMu.Unlock()
+
+		peer.conn.Close()
+		close(peer.done)
+		log.Printf("[Orchestrator] Peer %s disconnected", peer.name)
+	}()
+
+	reader := bufio.NewReader(peer.conn)
+	for {
+		packet := &factory.Packet{}
+		if err := utils.ReadMessage(reader, packet); err != nil {
+			if err != io.EOF {
+				log.Printf("Error reading from peer %s: %v", peer.name, err)
+			}
+			return
+		}
+
+		packet.Context.AddHop(peerHopPrefix + peer.name)
+
+		switch packet.Type {
+		case factory.PacketType_PACKET_TYPE_REQUEST:
+			// Requests from a peer only go to local pools, so they never loop between peers
+			go o.answerRequest(peerHopPrefix+peer.name, peer.sendPacket, packet, o.routeLocal)
+
+		case factory.PacketType_PACKET_TYPE_RESPONSE:
+			o.routeResponse(packet)
+
+		case factory.PacketType_PACKET_TYPE_CANCEL:
+			o.cancelRequest(packet.Id)
+
+		case factory.PacketType_PACKET_TYPE_PEER:
+			announcement := &factory.PeerAnnouncement{}
+			if err := proto.Unmarshal(packet.Payload, announcement); err != nil {
+				log.Printf("[Orchestrator] Invalid announcement from peer %s: %v", peer.name, err)
+				continue
+			}
+			peer.update(announcement)
+
+		default:
+			log.Printf("[Orchestrator] Warning: Drop packet %s from peer %s - %s packets are not forwarded between peers", packet.Id, peer.name, packet.Type)
+		}
+	}
+}
+
+// announcement returns a PEER packet listing the methods this orchestrator has a pool for
+func (o *Orchestrator) announcement() *factory.Packet {
+	o.peersMu.RLock()
+	announcement := &factory.PeerAnnouncement{Name: o.name}
+	o.peersMu.RUnlock()
+
+	o.poolsMu.RLock()
+	for methodID := range o.pools {
+		announcement.MethodIds = append(announcement.MethodIds, methodID)
+	}
+	o.poolsMu.RUnlock()
+	sort.Strings(announcement.MethodIds)
+
+	payload, err := proto.Marshal(announcement)
+	if err != nil {
+		log.Printf("[Orchestrator] Failed to encode announcement: %v", err)
+	}
+	return factory.NewPacket(factory.GeneratePacketId(), factory.PacketType_PACKET_TYPE_PEER, "", nil, payload, nil)
+}
+
+// announce tells every peer about the methods this orchestrator serves, after a pool was added
+func (o *Orchestrator) announce() {
+	packet := o.announcement()
+	for _, peer := range o.Peers() {
+		if err := peer.sendPacket(packet); err != nil {
+			log.Printf("[Orchestrator] Failed to announce methods to peer %s: %v", peer.name, err)
+		}
+	}
+}
+
+// peerFor picks a peer serving methodID, spreading requests over the peers that do
+func (o *Orchestrator) peerFor(methodID string) (*Peer, bool) {
+	var candidates []*Peer
+	for _, peer := range o.Peers() {
+		if peer.serves(methodID) {
+			candidates = append(candidates, peer)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	return candidates[o.peerNext.Add(1)%uint64(len(candidates))], true
+}
+
+// forward sends packet to peer and waits for its answer. The peer applies
+// the pool options, retries included, of its own pool.
+func (o *Orchestrator) forward(ctx context.Context, peer *Peer, packet *factory.Packet) (*factory.Packet, error) {
+	var cancel context.CancelFunc
+	if deadline := packet.Context.GetDeadline(); deadline != nil {
+		ctx, cancel = context.WithDeadline(ctx, deadline.AsTime())
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, peerTimeout)
+	}
+	defer cancel()
+
+	// The forwarded copy gets its own id, the request may also be routed locally
+	sent := proto.Clone(packet).(*factory.Packet)
+	sent.Id = packet.Id + "@" + peer.name
+	respChan := o.expectResponse(sent.Id, packet.TargetIoType, peerHopPrefix+peer.name)
+	defer o.responseChannels.Delete(sent.Id)
+
+	if err := peer.sendPacket(sent); err != nil {
+		st := status.Newf(codes.Unavailable, "peer %s send error: %v", peer.name, err)
+		return nil, factory.MarkNotProcessed(st).Err()
+	}
+
+	select {
+	case response := <-respChan:
+		response.Id = packet.Id
+		return response, nil
+
+	case <-peer.done:
+		return nil, status.Errorf(codes.Unavailable, "peer %s disconnected", peer.name)
+
+	case <-ctx.Done():
+		peer.cancel(sent)
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// hostname returns the default name of the orchestrator
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "orchestrator"
+	}
+	return name
+}
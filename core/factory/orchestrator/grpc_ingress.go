@@ -0,0 +1,160 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ServeGRPC serves every registered route as its original proto service on lis,
+// so existing gRPC clients can call split methods unchanged.
+// It blocks until the server stops.
+func (o *Orchestrator) ServeGRPC(lis net.Listener, opts ...grpc.ServerOption) error {
+	server := grpc.NewServer(opts...)
+
+	descs := o.serviceDescs()
+	if len(descs) == 0 {
+		return fmt.Errorf("no routes registered")
+	}
+	for _, desc := range descs {
+		server.RegisterService(desc, nil)
+	}
+
+	o.routesMu.Lock()
+	o.grpcServer = server
+	o.routesMu.Unlock()
+
+	log.Printf("[Orchestrator] gRPC ingress listening on %s", lis.Addr())
+	return server.Serve(lis)
+}
+
+// serviceDescs builds a grpc.ServiceDesc per proto service from the registered routes
+func (o *Orchestrator) serviceDescs() []*grpc.ServiceDesc {
+	descs := make(map[string]*grpc.ServiceDesc)
+	var ordered []*grpc.ServiceDesc
+
+	for _, route := range o.Routes() {
+		desc, ok := descs[route.Service]
+		if !ok {
+			desc = &grpc.ServiceDesc{
+				ServiceName: route.Service,
+				HandlerType: (*any)(nil),
+			}
+			descs[route.Service] = desc
+			ordered = append(ordered, desc)
+		}
+
+		if route.IsStream() {
+			desc.Streams = append(desc.Streams, grpc.StreamDesc{
+				StreamName:    route.Method,
+				Handler:       o.streamHandler(route),
+				ClientStreams: route.ClientStreams,
+				ServerStreams: route.ServerStreams,
+			})
+			continue
+		}
+
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: route.Method,
+			Handler:    o.unaryHandler(route),
+		})
+	}
+
+	return ordered
+}
+
+// unaryHandler returns the grpc method handler that forwards calls for route into the orchestrator
+func (o *Orchestrator) unaryHandler(route *Route) grpc.MethodHandler {
+	return func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		request := route.newRequest()
+		if err := dec(request); err != nil {
+			return nil, err
+		}
+
+		handler := func(ctx context.Context, req any) (any, error) {
+			return o.invoke(ctx, route, req.(proto.Message))
+		}
+		if interceptor == nil {
+			return handler(ctx, request)
+		}
+
+		info := &grpc.UnaryServerInfo{
+			Server:     o,
+			FullMethod: route.FullMethod(),
+		}
+		return interceptor(ctx, request, info, handler)
+	}
+}
+
+// streamHandler returns the grpc stream handler that pipes a streaming call for route
+// to a worker. Messages flow in both directions until the worker ends the stream.
+func (o *Orchestrator) streamHandler(route *Route) grpc.StreamHandler {
+	return func(_ any, serverStream grpc.ServerStream) error {
+		ctx := serverStream.Context()
+
+		// A server-streaming call opens the worker stream with its single request
+		var payload []byte
+		if !route.ClientStreams {
+			request := route.newRequest()
+			if err := serverStream.RecvMsg(request); err != nil {
+				return err
+			}
+			var err error
+			if payload, err = proto.Marshal(request); err != nil {
+				return status.Errorf(codes.Internal, "failed to encode request: %v", err)
+			}
+		}
+
+		stream, err := o.OpenStream(ctx, route.MethodID, ingressContext(ctx, o.ingressPriorityLimit()), payload)
+		if err != nil {
+			return err
+		}
+
+		if route.ClientStreams {
+			go forwardRequests(ctx, route, serverStream, stream)
+		}
+
+		for {
+			bytes, err := stream.Recv(ctx)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			response := route.newResponse()
+			if err := proto.Unmarshal(bytes, response); err != nil {
+				return status.Errorf(codes.Internal, "failed to decode response: %v", err)
+			}
+			if err := serverStream.SendMsg(response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// forwardRequests copies the messages a gRPC client streams in onto the worker stream
+func forwardRequests(ctx context.Context, route *Route, serverStream grpc.ServerStream, stream *factory.PacketStream) {
+	for {
+		request := route.newRequest()
+		if err := serverStream.RecvMsg(request); err != nil {
+			// Any other error means the call is gone, ctx cancels the worker stream
+			if err == io.EOF {
+				stream.CloseSend(nil)
+			}
+			return
+		}
+		if err := stream.Send(ctx, request); err != nil {
+			return
+		}
+	}
+}
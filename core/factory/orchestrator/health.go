@@ -0,0 +1,122 @@
+package orchestrator
+
+import (
+	"log"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/protobuf/proto"
+)
+
+// HealthCheckConfig controls the ping/pong heartbeat between the orchestrator and its workers
+type HealthCheckConfig struct {
+	Interval       time.Duration // How often each worker is pinged, 0 disables health checks
+	UnhealthyAfter int           // Missed pongs before the worker is skipped by SelectWorker
+	KillAfter      int           // Missed pongs before the worker process is killed
+}
+
+// DefaultHealthCheckConfig returns the health check settings used by NewOrchestrator
+func DefaultHealthCheckConfig() HealthCheckConfig {
+	return HealthCheckConfig{
+		Interval:       2 * time.Second,
+		UnhealthyAfter: 3,
+		KillAfter:      10,
+	}
+}
+
+// SetHealthCheckConfig replaces the health check settings for workers spawned from now on
+func (o *Orchestrator) SetHealthCheckConfig(config HealthCheckConfig) {
+	o.poolsMu.Lock()
+	defer o.poolsMu.Unlock()
+	o.healthCheck = config
+}
+
+// monitorHealth pings worker on an interval until its connection closes.
+// A worker that misses too many pongs is marked unhealthy, and killed if it stays that way.
+func (o *Orchestrator) monitorHealth(worker *Worker) {
+	o.poolsMu.RLock()
+	config := o.healthCheck
+	o.poolsMu.RUnlock()
+
+	if config.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-worker.done:
+			return
+		case <-ticker.C:
+		}
+
+		// Every ping still outstanding at this point went unanswered for at least one interval
+		missed := int(worker.pendingPings.Load())
+		if config.KillAfter > 0 && missed >= config.KillAfter {
+			log.Printf("[Orchestrator] Worker %s missed %d health checks, killing it", worker.id, missed)
+			worker.kill()
+			return
+		}
+		if config.UnhealthyAfter > 0 && missed >= config.UnhealthyAfter {
+			worker.markUnhealthy(missed)
+		}
+
+		worker.ping()
+	}
+}
+
+// ping sends the worker a health check, its pong is handled by recordPong
+func (w *Worker) ping() {
+	ping := factory.NewPacket(factory.GeneratePacketId(), factory.PacketType_PACKET_TYPE_PING, w.processType, nil, nil, nil)
+	w.pendingPings.Add(1)
+	if err := w.sendPacket(ping); err != nil {
+		log.Printf("[Orchestrator] Failed to ping worker %s: %v", w.id, err)
+	}
+}
+
+// recordPong resets the worker's missed ping count, marks it healthy again
+// and keeps the status the worker reported
+func (w *Worker) recordPong(pong *factory.Packet) {
+	if len(pong.Payload) > 0 {
+		status := &factory.WorkerStatus{}
+		if err := proto.Unmarshal(pong.Payload, status); err != nil {
+			log.Printf("[Orchestrator] Worker %s sent an invalid status: %v", w.id, err)
+		} else {
+			w.status.Store(status)
+		}
+	}
+
+	w.pendingPings.Store(0)
+	if !w.healthy.Swap(true) {
+		log.Printf("[Orchestrator] Worker %s is healthy again", w.id)
+	}
+}
+
+// markUnhealthy takes the worker out of SelectWorker's rotation
+func (w *Worker) markUnhealthy(missed int) {
+	if w.healthy.Swap(false) {
+		log.Printf("[Orchestrator] Worker %s missed %d health checks, marking it unhealthy", w.id, missed)
+	}
+}
+
+// IsHealthy reports whether the worker is answering health checks
+func (w *Worker) IsHealthy() bool {
+	return w.healthy.Load()
+}
+
+// Status returns the last status the worker reported, nil until its first pong
+func (w *Worker) Status() *factory.WorkerStatus {
+	return w.status.Load()
+}
+
+// limit returns the requests the worker accepts at once given the pool's limit,
+// the lower of the two. 0 is unlimited.
+func (w *Worker) limit(poolLimit int) int {
+	advertised := int(w.Status().GetMaxConcurrency())
+	if advertised == 0 || (poolLimit > 0 && poolLimit < advertised) {
+		return poolLimit
+	}
+	return advertised
+}
@@ -0,0 +1,200 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	latencySamples    = 128  // Recent latencies a pool keeps for percentiles
+	minLatencySamples = 20   // Fewer samples are not enough to estimate a percentile
+	hedgePercentile   = 0.95 // The latency after which a copy is hedged when no delay is set
+)
+
+// HedgingPolicy sends further copies of a slow request to other workers of the pool
+// and keeps whichever answer arrives first, cancelling the others.
+// Copies may all run to completion, so it is only meant for methods without side effects.
+// It is modeled on the hedgingPolicy of the gRPC service config and replaces the retry policy.
+type HedgingPolicy struct {
+	MaxAttempts   int           // Copies of a request at most, including the first. Below 2 disables hedging
+	Delay         time.Duration // Wait for an answer before sending the next copy, 0 uses the pool's p95 latency
+	NonFatalCodes []codes.Code  // Statuses that leave the other copies running, any other answer is returned at once
+}
+
+// DefaultHedgingPolicy returns a policy sending up to maxAttempts copies of a request
+// after the pool's p95 latency
+func DefaultHedgingPolicy(maxAttempts int) HedgingPolicy {
+	return HedgingPolicy{
+		MaxAttempts:   maxAttempts,
+		NonFatalCodes: []codes.Code{codes.Unavailable, codes.DeadlineExceeded},
+	}
+}
+
+// enabled reports whether requests are hedged at all
+func (p HedgingPolicy) enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+// fatal reports whether result settles the request
+func (p HedgingPolicy) fatal(result attemptResult) bool {
+	if result.status == nil {
+		return false
+	}
+	// A copy that never ran says nothing about the request
+	if result.turnedAway() {
+		return false
+	}
+	return !slices.Contains(p.NonFatalCodes, result.status.Code())
+}
+
+// workerSet is the set of workers the attempts of a request were sent to
+type workerSet struct {
+	mu       sync.Mutex
+	workers  map[*Worker]bool
+	fallback bool // a worker in the set may be picked again when no other is left
+}
+
+func newWorkerSet(fallback bool) *workerSet {
+	return &workerSet{workers: make(map[*Worker]bool), fallback: fallback}
+}
+
+// add records worker, a nil set records nothing
+func (s *workerSet) add(worker *Worker) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workers[worker] = true
+}
+
+// contains reports whether worker was recorded, a nil set contains nothing
+func (s *workerSet) contains(worker *Worker) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.workers[worker]
+}
+
+// empty reports whether no worker was recorded yet, a nil set is empty
+func (s *workerSet) empty() bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.workers) == 0
+}
+
+// hedge sends packet to a worker of the pool and, each time the hedging delay passes
+// without an answer, another copy to a worker that has not seen it yet.
+// The first answer that settles the request is returned and the other copies are cancelled.
+func (o *Orchestrator) hedge(ctx context.Context, pool *WorkerPool, packet *factory.Packet, policy HedgingPolicy, timeout time.Duration) (*factory.Packet, error) {
+	// Returning cancels the copies still running on their workers
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	delay := policy.Delay
+	if delay == 0 {
+		// Without enough samples no copies are sent until one fails
+		delay, _ = pool.latencyPercentile(hedgePercentile)
+	}
+
+	results := make(chan attemptResult, policy.MaxAttempts)
+	tried := newWorkerSet(false)
+	launched, outstanding := 0, 0
+	launch := func() {
+		n := launched
+		launched++
+		outstanding++
+		go func() {
+			results <- o.attempt(ctx, pool, packet, n, timeout, tried)
+		}()
+	}
+
+	// A nil channel never fires, copies are then only sent after failures
+	var hedgeTimer *time.Timer
+	var hedgeC <-chan time.Time
+	if delay > 0 {
+		hedgeTimer = time.NewTimer(delay)
+		defer hedgeTimer.Stop()
+		hedgeC = hedgeTimer.C
+	}
+
+	launch()
+	var last attemptResult
+	for {
+		select {
+		case <-hedgeC:
+			if launched < policy.MaxAttempts {
+				log.Printf("[Orchestrator] Hedging %s, no answer after %v", packet.Id, delay)
+				launch()
+				hedgeTimer.Reset(delay)
+			}
+
+		case result := <-results:
+			outstanding--
+			if result.status == nil || policy.fatal(result) {
+				return settle(result, launched)
+			}
+
+			// Keep waiting on the other copies, or send the next one right away
+			last = result
+			if launched < policy.MaxAttempts {
+				launch()
+				if hedgeTimer != nil {
+					hedgeTimer.Reset(delay)
+				}
+			} else if outstanding == 0 {
+				return settle(last, launched)
+			}
+
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// settle turns the result that ended routing after attempts tries into route's return values
+func settle(result attemptResult, attempts int) (*factory.Packet, error) {
+	if result.response != nil {
+		return result.response, nil
+	}
+	return nil, status.Errorf(result.status.Code(), "request failed after %d attempts. Last error: %s", attempts, result.status.Message())
+}
+
+// attemptID returns the id a single attempt of the request with the given id is sent with
+func attemptID(id string, attempt int) string {
+	return fmt.Sprintf("%s#%d", id, attempt)
+}
+
+// hedging returns the hedging policy of the pool
+func (p *WorkerPool) hedging() HedgingPolicy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.hedgingPolicy
+}
+
+// latencyPercentile returns the q-th percentile (0 to 1) of the pool's recent latency.
+// ok is false until the pool has seen enough requests.
+func (p *WorkerPool) latencyPercentile(q float64) (latency time.Duration, ok bool) {
+	p.latencyMu.Lock()
+	samples := slices.Clone(p.samples)
+	p.latencyMu.Unlock()
+
+	if len(samples) < minLatencySamples {
+		return 0, false
+	}
+	slices.Sort(samples)
+	return samples[int(q*float64(len(samples)-1))], true
+}
@@ -0,0 +1,263 @@
+package orchestrator
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"github.com/bsmider/pipes/core/factory/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// answer is how a worker in hedgedPool responds to an attempt of a request
+type answer struct {
+	after    time.Duration
+	response *factory.Packet
+}
+
+// hedgedPool is a pool of workers answering attempts as scripted, recording what they were sent
+type hedgedPool struct {
+	o    *Orchestrator
+	pool *WorkerPool
+
+	mu       sync.Mutex
+	requests []string // Ids of the attempts the workers were sent
+	cancels  []string // Ids of the attempts the workers were told to cancel
+}
+
+// newHedgedPool returns a pool of n workers answering attempt i of a request with answers[i]
+func newHedgedPool(t *testing.T, n int, answers []answer) *hedgedPool {
+	h := &hedgedPool{o: NewOrchestrator()}
+	var workers []*Worker
+	for i := 0; i < n; i++ {
+		conn, workerSide := net.Pipe()
+		t.Cleanup(func() { conn.Close() })
+		go h.serve(workerSide, answers)
+		workers = append(workers, NewWorker(strconv.Itoa(i), "test", "", conn, nil, nil))
+	}
+	h.pool = NewWorkerPool(workers, time.Second, 0)
+	return h
+}
+
+// serve reads the packets sent to a worker and answers its requests
+func (h *hedgedPool) serve(conn net.Conn, answers []answer) {
+	reader := bufio.NewReader(conn)
+	for {
+		packet := &factory.Packet{}
+		if err := utils.ReadMessage(reader, packet); err != nil {
+			return
+		}
+
+		h.mu.Lock()
+		if packet.Type == factory.PacketType_PACKET_TYPE_CANCEL {
+			h.cancels = append(h.cancels, packet.Id)
+			h.mu.Unlock()
+			continue
+		}
+		h.requests = append(h.requests, packet.Id)
+		h.mu.Unlock()
+
+		_, n, _ := strings.Cut(packet.Id, "#")
+		attempt, _ := strconv.Atoi(n)
+		a := answers[attempt]
+		response := &factory.Packet{Id: packet.Id, Type: factory.PacketType_PACKET_TYPE_RESPONSE, Payload: a.response.Payload, Error: a.response.Error}
+		time.AfterFunc(a.after, func() { h.o.routeResponse(response) })
+	}
+}
+
+// sent returns the ids of the attempts sent and cancelled so far
+func (h *hedgedPool) sent() (requests []string, cancels []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Sorted(slices.Values(h.requests)), slices.Sorted(slices.Values(h.cancels))
+}
+
+func TestHedge(t *testing.T) {
+	ok := func(body string) *factory.Packet { return &factory.Packet{Payload: []byte(body)} }
+	notFound := errorPacket(codes.NotFound, "no book")
+	overloaded := errorPacket(codes.Unavailable, "overloaded")
+
+	tests := []struct {
+		name         string
+		workers      int
+		policy       HedgingPolicy
+		answers      []answer
+		want         string     // Payload of the response
+		wantCode     codes.Code // Status of the response or error when there is no payload
+		wantRequests []string
+		wantCancels  []string
+	}{
+		{
+			name:         "a fast answer sends no copies",
+			workers:      2,
+			policy:       HedgingPolicy{MaxAttempts: 2, Delay: time.Second},
+			answers:      []answer{{0, ok("first")}, {0, ok("second")}},
+			want:         "first",
+			wantRequests: []string{"request#0"},
+		},
+		{
+			name:         "a slow answer is hedged after the delay and the copy wins",
+			workers:      2,
+			policy:       HedgingPolicy{MaxAttempts: 2, Delay: 20 * time.Millisecond},
+			answers:      []answer{{time.Minute, ok("first")}, {0, ok("second")}},
+			want:         "second",
+			wantRequests: []string{"request#0", "request#1"},
+			wantCancels:  []string{"request#0"},
+		},
+		{
+			name:         "a non fatal failure sends the next copy at once",
+			workers:      2,
+			policy:       HedgingPolicy{MaxAttempts: 2, Delay: time.Minute, NonFatalCodes: []codes.Code{codes.Unavailable}},
+			answers:      []answer{{0, overloaded}, {0, ok("second")}},
+			want:         "second",
+			wantRequests: []string{"request#0", "request#1"},
+		},
+		{
+			name:         "a fatal answer settles the request",
+			workers:      2,
+			policy:       HedgingPolicy{MaxAttempts: 2, Delay: time.Minute, NonFatalCodes: []codes.Code{codes.Unavailable}},
+			answers:      []answer{{0, notFound}, {0, ok("second")}},
+			wantCode:     codes.NotFound,
+			wantRequests: []string{"request#0"},
+		},
+		{
+			name:         "the last failure is returned once every copy failed",
+			workers:      3,
+			policy:       HedgingPolicy{MaxAttempts: 3, Delay: time.Minute, NonFatalCodes: []codes.Code{codes.Unavailable}},
+			answers:      []answer{{0, overloaded}, {0, overloaded}, {0, overloaded}},
+			wantCode:     codes.Unavailable,
+			wantRequests: []string{"request#0", "request#1", "request#2"},
+		},
+		{
+			name:         "no copies without a delay until the pool has enough latency samples",
+			workers:      2,
+			policy:       HedgingPolicy{MaxAttempts: 2},
+			answers:      []answer{{50 * time.Millisecond, ok("first")}, {0, ok("second")}},
+			want:         "first",
+			wantRequests: []string{"request#0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHedgedPool(t, tt.workers, tt.answers)
+			packet := &factory.Packet{Id: "request", Type: factory.PacketType_PACKET_TYPE_REQUEST, TargetIoType: "test"}
+
+			response, err := h.o.hedge(context.Background(), h.pool, packet, tt.policy, 5*time.Second)
+			if tt.want != "" {
+				if err != nil || string(response.GetPayload()) != tt.want {
+					t.Fatalf("hedge() = %v, %v, want %q", response, err, tt.want)
+				}
+			} else {
+				if response != nil {
+					err = response.Error.ToGoError()
+				}
+				if code := status.Code(err); code != tt.wantCode {
+					t.Fatalf("hedge() failed with %v, want %v", err, tt.wantCode)
+				}
+			}
+
+			// Cancels of the copies that lost are sent as hedge returns
+			deadline := time.Now().Add(time.Second)
+			requests, cancels := h.sent()
+			for len(cancels) < len(tt.wantCancels) && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+				requests, cancels = h.sent()
+			}
+			if !slices.Equal(requests, tt.wantRequests) {
+				t.Errorf("workers were sent %v, want %v", requests, tt.wantRequests)
+			}
+			if !slices.Equal(cancels, tt.wantCancels) {
+				t.Errorf("workers were told to cancel %v, want %v", cancels, tt.wantCancels)
+			}
+		})
+	}
+}
+
+func TestHedgeCancelled(t *testing.T) {
+	h := newHedgedPool(t, 1, []answer{{time.Minute, &factory.Packet{}}})
+	packet := &factory.Packet{Id: "request", Type: factory.PacketType_PACKET_TYPE_REQUEST, TargetIoType: "test"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := h.o.hedge(ctx, h.pool, packet, DefaultHedgingPolicy(2), 5*time.Second)
+	if code := status.Code(err); code != codes.DeadlineExceeded {
+		t.Errorf("hedge() past the caller's deadline failed with %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestHedgingPolicyFatal(t *testing.T) {
+	policy := DefaultHedgingPolicy(2)
+
+	tests := []struct {
+		name   string
+		result attemptResult
+		want   bool
+	}{
+		{"success", answered(&factory.Packet{Payload: []byte("ok")}), false},
+		{"status that is not in NonFatalCodes", answered(errorPacket(codes.NotFound, "no book")), true},
+		{"status in NonFatalCodes", answered(errorPacket(codes.Unavailable, "overloaded")), false},
+		{"attempt timed out", failed(codes.DeadlineExceeded, true, "no answer"), false},
+		{"resource exhausted by the request", answered(errorPacket(codes.ResourceExhausted, "too large")), true},
+		{"turned away by a worker at its limit", answered(notProcessedPacket(codes.ResourceExhausted)), false},
+		{"never left the orchestrator", failed(codes.Internal, false, "write failed"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.fatal(tt.result); got != tt.want {
+				t.Errorf("fatal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyPercentile(t *testing.T) {
+	pool := NewWorkerPool(nil, time.Second, 0)
+	for i := 1; i < minLatencySamples; i++ {
+		pool.recordLatency(time.Duration(i) * time.Millisecond)
+	}
+	if latency, ok := pool.latencyPercentile(hedgePercentile); ok {
+		t.Errorf("latencyPercentile() = %v with %d samples, want not enough samples", latency, minLatencySamples-1)
+	}
+
+	// Only the most recent latencySamples count
+	for i := 0; i < latencySamples; i++ {
+		pool.recordLatency(time.Hour)
+	}
+	for i := 1; i <= latencySamples; i++ {
+		pool.recordLatency(time.Duration(i) * time.Millisecond)
+	}
+
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{0.5, 64 * time.Millisecond},
+		{hedgePercentile, 121 * time.Millisecond},
+		{1, latencySamples * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if latency, ok := pool.latencyPercentile(tt.q); !ok || latency != tt.want {
+			t.Errorf("latencyPercentile(%v) = %v, %v, want %v", tt.q, latency, ok, tt.want)
+		}
+	}
+}
+
+func TestAttemptID(t *testing.T) {
+	if id := attemptID("request", 0); id != "request#0" {
+		t.Errorf("attemptID() = %q, want request#0", id)
+	}
+	if attemptID("request", 1) == attemptID("request", 2) {
+		t.Error("attempts of a request share an id")
+	}
+}
@@ -0,0 +1,148 @@
+package orchestrator
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxJSONBodySize caps the size of an HTTP ingress request body
+const maxJSONBodySize = 4 << 20
+
+// ServeHTTPIngress serves every registered route as a JSON endpoint on lis
+// at POST /{package}.{Service}/{Method}. It blocks until the server stops.
+func (o *Orchestrator) ServeHTTPIngress(lis net.Listener) error {
+	server := &http.Server{Handler: o.HTTPHandler()}
+
+	o.routesMu.Lock()
+	o.httpServer = server
+	o.routesMu.Unlock()
+
+	log.Printf("[Orchestrator] HTTP ingress listening on %s", lis.Addr())
+	err := server.Serve(lis)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// HTTPHandler returns the http.Handler behind the HTTP ingress, so it can be
+// mounted on an existing server.
+func (o *Orchestrator) HTTPHandler() http.Handler {
+	return http.HandlerFunc(o.handleHTTP)
+}
+
+func (o *Orchestrator) handleHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, status.Errorf(codes.Unimplemented, "method %s not allowed", r.Method))
+		return
+	}
+
+	route, ok := o.GetRoute(r.URL.Path)
+	if !ok {
+		writeJSONError(w, status.Errorf(codes.Unimplemented, "unknown method %s", r.URL.Path))
+		return
+	}
+	if route.IsStream() {
+		writeJSONError(w, status.Errorf(codes.Unimplemented, "streaming method %s is only served over gRPC", r.URL.Path))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJSONBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		st := status.Newf(codes.ResourceExhausted, "request body exceeds %d bytes", tooLarge.Limit)
+		writeJSON(w, http.StatusRequestEntityTooLarge, st.Proto())
+		return
+	}
+	if err != nil {
+		writeJSONError(w, status.Errorf(codes.InvalidArgument, "failed to read body: %v", err))
+		return
+	}
+
+	request := route.newRequest()
+	if len(body) > 0 {
+		if err := protojson.Unmarshal(body, request); err != nil {
+			writeJSONError(w, status.Errorf(codes.InvalidArgument, "failed to decode request: %v", err))
+			return
+		}
+	}
+
+	// Expose the trace, priority and version headers the same way the gRPC ingress sees them
+	ctx := r.Context()
+	md := metadata.MD{}
+	for _, header := range []string{TraceIDHeader, PriorityHeader, VersionHeader} {
+		if value := r.Header.Get(header); value != "" {
+			md.Set(header, value)
+		}
+	}
+	if len(md) > 0 {
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+
+	response, err := o.invoke(ctx, route, request)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// writeJSONError encodes err as a google.rpc.Status
+func writeJSONError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeJSON(w, HTTPStatusFromCode(st.Code()), st.Proto())
+}
+
+func writeJSON(w http.ResponseWriter, httpStatus int, message proto.Message) {
+	bytes, err := protojson.Marshal(message)
+	if err != nil {
+		httpStatus = http.StatusInternalServerError
+		bytes = []byte(`{"code":13,"message":"failed to encode response"}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	w.Write(bytes)
+}
+
+// HTTPStatusFromCode maps a gRPC status code onto the closest HTTP status,
+// following the mapping documented in google/rpc/code.proto.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client Closed Request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
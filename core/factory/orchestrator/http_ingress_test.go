@@ -0,0 +1,54 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bsmider/pipes/core/example/build/example"
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestHTTPIngressRejectsOversizedBody(t *testing.T) {
+	o := NewOrchestrator()
+	o.AddRoute(NewRoute[*example.GetBookRequest, *example.GetBookResponse]("BookService", "GetBook", "id"))
+
+	body := `{"bookId":"` + strings.Repeat("1", maxJSONBodySize) + `"}`
+	request := httptest.NewRequest(http.MethodPost, "/example.BookService/GetBook", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	o.HTTPHandler().ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusRequestEntityTooLarge, recorder.Body)
+	}
+}
+
+func TestIngressPriorityLimit(t *testing.T) {
+	high, normal, low := factory.Priority_PRIORITY_HIGH, factory.Priority_PRIORITY_NORMAL, factory.Priority_PRIORITY_LOW
+
+	tests := []struct {
+		header string
+		limit  factory.Priority
+		want   factory.Priority
+	}{
+		{header: "", limit: normal, want: normal},
+		{header: "high", limit: normal, want: normal},
+		{header: "low", limit: normal, want: low},
+		{header: "high", limit: high, want: high},
+		{header: "normal", limit: low, want: low},
+		{header: "urgent", limit: high, want: normal},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.header != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(PriorityHeader, tt.header))
+		}
+		if got := ingressContext(ctx, tt.limit).GetPriority(); got != tt.want {
+			t.Errorf("priority %q with limit %s = %s, want %s", tt.header, tt.limit, got, tt.want)
+		}
+	}
+}
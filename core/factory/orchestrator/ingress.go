@@ -0,0 +1,137 @@
+package orchestrator
+
+import (
+	"context"
+	"sort"
+
+	"github.com/bsmider/pipes/core/factory"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TraceIDHeader is the incoming metadata key used to propagate a caller's trace ID
+const TraceIDHeader = "x-trace-id"
+
+// PriorityHeader is the incoming metadata key carrying the priority of a request,
+// "high", "normal" or "low". The priority is inherited by every call the request makes.
+// Ingress callers may only claim up to the limit set with SetIngressPriorityLimit.
+const PriorityHeader = "x-priority"
+
+// VersionHeader is the incoming metadata key pinning a request to a version of the
+// methods it reaches, see SpawnVersion. It is inherited by every call the request makes.
+const VersionHeader = "x-version"
+
+// AddRoute exposes a method on the ingress servers.
+// Routes must be added before the ingress servers are started.
+func (o *Orchestrator) AddRoute(route *Route) {
+	o.routesMu.Lock()
+	defer o.routesMu.Unlock()
+	o.routes[route.FullMethod()] = route
+}
+
+// SetIngressPriorityLimit sets the highest priority requests coming in through the
+// ingress servers may claim with PriorityHeader, higher claims are lowered to it.
+// The limit is normal by default, so outside callers can only lower their priority.
+// Raising it to high lets any client that reaches the ingress jump the queues.
+func (o *Orchestrator) SetIngressPriorityLimit(limit factory.Priority) {
+	o.routesMu.Lock()
+	defer o.routesMu.Unlock()
+	o.ingressPriority = limit
+}
+
+// ingressPriorityLimit returns the highest priority ingress requests may claim
+func (o *Orchestrator) ingressPriorityLimit() factory.Priority {
+	o.routesMu.RLock()
+	defer o.routesMu.RUnlock()
+	return o.ingressPriority
+}
+
+// GetRoute looks up a route by its full method name e.g. "/example.BookService/GetBook"
+func (o *Orchestrator) GetRoute(fullMethod string) (*Route, bool) {
+	o.routesMu.RLock()
+	defer o.routesMu.RUnlock()
+	route, ok := o.routes[fullMethod]
+	return route, ok
+}
+
+// Routes returns every registered route, sorted by full method name
+func (o *Orchestrator) Routes() []*Route {
+	o.routesMu.RLock()
+	defer o.routesMu.RUnlock()
+
+	routes := make([]*Route, 0, len(o.routes))
+	for _, route := range o.routes {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].FullMethod() < routes[j].FullMethod()
+	})
+	return routes
+}
+
+// invoke wraps an ingress request into a packet, routes it to a worker and
+// unwraps the response. Errors are always returned as gRPC status errors.
+func (o *Orchestrator) invoke(ctx context.Context, route *Route, request proto.Message) (proto.Message, error) {
+	requestPacket, err := factory.CreateRequestPacket(route.MethodID, ingressContext(ctx, o.ingressPriorityLimit()), request, nil)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode request: %v", err)
+	}
+
+	responsePacket, err := o.RouteRequestContext(ctx, requestPacket)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	if err := responsePacket.Error.ToGoError(); err != nil {
+		return nil, err
+	}
+
+	response := route.newResponse()
+	if err := proto.Unmarshal(responsePacket.Payload, response); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decode response: %v", err)
+	}
+
+	return response, nil
+}
+
+// ingressContext builds the packet context for a request entering the orchestrator
+// from the outside, carrying over the caller's deadline, trace ID, priority and version.
+// The priority is capped at limit.
+func ingressContext(ctx context.Context, limit factory.Priority) *factory.Context {
+	var deadline *timestamppb.Timestamp
+	if d, ok := ctx.Deadline(); ok {
+		deadline = timestamppb.New(d)
+	}
+
+	traceID := uuid.NewString()
+	priority := factory.Priority_PRIORITY_NORMAL
+	version := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(TraceIDHeader); len(values) > 0 && values[0] != "" {
+			traceID = values[0]
+		}
+		if values := md.Get(PriorityHeader); len(values) > 0 {
+			// An unknown priority is treated as normal rather than failing the request
+			priority, _ = factory.ParsePriority(values[0])
+		}
+		if priority.Rank() > limit.Rank() {
+			priority = limit
+		}
+		if values := md.Get(VersionHeader); len(values) > 0 {
+			version = values[0]
+		}
+	}
+
+	requestContext := factory.NewContext(deadline, traceID, nil)
+	requestContext.Priority = priority
+	requestContext.Version = version
+	requestContext.AddHop("orchestrator")
+	return requestContext
+}
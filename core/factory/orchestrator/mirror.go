@@ -0,0 +1,293 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// mirrorMaxInFlight bounds the copies a pool has outstanding on its shadow pool,
+// further requests are not mirrored until some are answered
+const mirrorMaxInFlight = 64
+
+// MirrorPolicy copies requests of a pool to a shadow pool and compares the answers
+type MirrorPolicy struct {
+	Shadow   string  // The process type of the shadow pool, e.g. a new build spawned under another name
+	Fraction float64 // Share of the requests copied, from 0 to 1
+}
+
+// MirrorStats counts the requests a pool copied to its shadow pool
+type MirrorStats struct {
+	Shadow     string
+	Mirrored   uint64 // Copies sent to the shadow pool
+	Matched    uint64 // Copies answered like the primary request
+	Mismatched uint64 // Copies answered differently, each one is logged
+	Dropped    uint64 // Requests not copied because the shadow pool had mirrorMaxInFlight outstanding
+}
+
+// MirrorMismatch is the report logged when a shadow answer differs from the primary one
+type MirrorMismatch struct {
+	Method       string   `json:"method"`
+	Shadow       string   `json:"shadow"`
+	RequestID    string   `json:"request_id"`
+	Fields       []string `json:"fields,omitempty"` // Response fields that differ, "payload" if they could not be decoded
+	PrimaryError string   `json:"primary_error,omitempty"`
+	ShadowError  string   `json:"shadow_error,omitempty"`
+}
+
+// mirror is the mirroring state of a pool
+type mirror struct {
+	policy   MirrorPolicy
+	inFlight atomic.Int64
+
+	mirrored   atomic.Uint64
+	matched    atomic.Uint64
+	mismatched atomic.Uint64
+	dropped    atomic.Uint64
+}
+
+// outcome is how a request was answered, a nil response comes with a status error
+type outcome struct {
+	response *factory.Packet
+	err      error
+}
+
+// shadowRequest is a copy of a request sent to the shadow pool, waiting for the primary outcome
+type shadowRequest struct {
+	primary chan *outcome // receives the primary outcome, nil if the caller gave up
+}
+
+// Mirror copies policy.Fraction of the requests of the pool serving processType to the pool
+// serving policy.Shadow. Copies are sent alongside the primary request, once, whatever the
+// retry policy of either pool, and their answers are never returned to callers: they are
+// compared with the primary answers and mismatches are logged as a MirrorMismatch.
+// Calls the shadow workers make are routed like any other, so shadow builds should not
+// repeat side effects the primary workers already have. Mirroring replaces any previous
+// policy of the pool.
+func (o *Orchestrator) Mirror(processType string, policy MirrorPolicy) error {
+	if policy.Shadow == processType || policy.Fraction <= 0 || policy.Fraction > 1 {
+		return fmt.Errorf("invalid mirror policy for %s: shadow %q, fraction %v", processType, policy.Shadow, policy.Fraction)
+	}
+	pool, ok := o.pool(processType)
+	if !ok {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+	if _, ok := o.pool(policy.Shadow); !ok {
+		return fmt.Errorf("no pool for shadow type: %s", policy.Shadow)
+	}
+
+	pool.mu.Lock()
+	pool.mirror = &mirror{policy: policy}
+	pool.mu.Unlock()
+
+	log.Printf("[Orchestrator] Mirroring %.0f%% of %s to %s", policy.Fraction*100, processType, policy.Shadow)
+	return nil
+}
+
+// StopMirror stops copying the requests of the pool serving processType, copies in flight are still compared
+func (o *Orchestrator) StopMirror(processType string) error {
+	pool, ok := o.pool(processType)
+	if !ok {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.mu.Lock()
+	pool.mirror = nil
+	pool.mu.Unlock()
+	return nil
+}
+
+// MirrorStats reports how the shadow pool of the pool serving processType compares,
+// since its mirror policy was set
+func (o *Orchestrator) MirrorStats(processType string) (MirrorStats, error) {
+	pool, ok := o.pool(processType)
+	if !ok {
+		return MirrorStats{}, fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.mu.RLock()
+	m := pool.mirror
+	pool.mu.RUnlock()
+	if m == nil {
+		return MirrorStats{}, fmt.Errorf("%s is not mirrored", processType)
+	}
+
+	return MirrorStats{
+		Shadow:     m.policy.Shadow,
+		Mirrored:   m.mirrored.Load(),
+		Matched:    m.matched.Load(),
+		Mismatched: m.mismatched.Load(),
+		Dropped:    m.dropped.Load(),
+	}, nil
+}
+
+// mirrorRequest sends a copy of packet to the shadow pool if the pool mirrors it.
+// The returned request is nil if packet is not mirrored.
+func (o *Orchestrator) mirrorRequest(pool *WorkerPool, packet *factory.Packet) *shadowRequest {
+	pool.mu.RLock()
+	m := pool.mirror
+	pool.mu.RUnlock()
+	if m == nil || rand.Float64() >= m.policy.Fraction {
+		return nil
+	}
+
+	shadow, ok := o.pool(m.policy.Shadow)
+	if !ok {
+		return nil
+	}
+	if m.inFlight.Add(1) > mirrorMaxInFlight {
+		m.inFlight.Add(-1)
+		m.dropped.Add(1)
+		return nil
+	}
+	m.mirrored.Add(1)
+
+	// The copy gets its own id so its answer never reaches the primary attempts
+	sent := proto.Clone(packet).(*factory.Packet)
+	sent.Id = packet.Id + "~shadow"
+	sent.TargetIoType = m.policy.Shadow
+
+	request := &shadowRequest{primary: make(chan *outcome, 1)}
+	go func() {
+		defer m.inFlight.Add(-1)
+
+		// The copy outlives the caller, it is bounded by the shadow pool's timeout only
+		timeout, _, _ := shadow.limits()
+		result := o.attempt(context.Background(), shadow, sent, 0, timeout, nil)
+		shadowOutcome := &outcome{response: result.response}
+		if result.response == nil {
+			shadowOutcome.err = result.status.Err()
+		}
+
+		primary := <-request.primary
+		if primary == nil {
+			return
+		}
+
+		report := o.compareOutcomes(packet, primary, shadowOutcome)
+		if report == nil {
+			m.matched.Add(1)
+			return
+		}
+		report.Shadow = m.policy.Shadow
+		m.mismatched.Add(1)
+
+		encoded, err := json.Marshal(report)
+		if err != nil {
+			log.Printf("[Orchestrator] Failed to encode mirror mismatch of %s: %v", packet.Id, err)
+			return
+		}
+		log.Printf("[Orchestrator] Mirror mismatch %s", encoded)
+	}()
+	return request
+}
+
+// compare hands the primary outcome to the shadow request, without waiting for the shadow answer
+func (r *shadowRequest) compare(response *factory.Packet, err error) {
+	if r != nil {
+		r.primary <- &outcome{response: response, err: err}
+	}
+}
+
+// abandon tells the shadow request the caller gave up, there is nothing to compare with
+func (r *shadowRequest) abandon() {
+	if r != nil {
+		r.primary <- nil
+	}
+}
+
+// compareOutcomes returns the mismatches between the primary and shadow outcomes of packet, nil if they agree
+func (o *Orchestrator) compareOutcomes(packet *factory.Packet, primary *outcome, shadow *outcome) *MirrorMismatch {
+	report := &MirrorMismatch{Method: packet.TargetIoType, RequestID: packet.Id}
+
+	primaryStatus, shadowStatus := outcomeStatus(primary), outcomeStatus(shadow)
+	if primaryStatus.Code() != shadowStatus.Code() || primaryStatus.Message() != shadowStatus.Message() {
+		report.PrimaryError = describeStatus(primaryStatus)
+		report.ShadowError = describeStatus(shadowStatus)
+	}
+	report.Fields = o.diffPayloads(packet.TargetIoType, primary.response.GetPayload(), shadow.response.GetPayload())
+
+	if report.PrimaryError == "" && len(report.Fields) == 0 {
+		return nil
+	}
+	return report
+}
+
+// diffPayloads returns the names of the response fields that differ between a and b.
+// Methods without a route have no known response type, their payloads are compared as bytes.
+func (o *Orchestrator) diffPayloads(methodID string, a []byte, b []byte) []string {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	route, ok := o.routeFor(methodID)
+	if !ok {
+		return []string{"payload"}
+	}
+
+	primary, shadow := route.newResponse(), route.newResponse()
+	if proto.Unmarshal(a, primary) != nil || proto.Unmarshal(b, shadow) != nil {
+		return []string{"payload"}
+	}
+
+	// Compare field by field, copying each into an otherwise empty message
+	var fields []string
+	primaryMessage, shadowMessage := primary.ProtoReflect(), shadow.ProtoReflect()
+	descriptors := primaryMessage.Descriptor().Fields()
+	for i := 0; i < descriptors.Len(); i++ {
+		field := descriptors.Get(i)
+		primaryField, shadowField := route.newResponse().ProtoReflect(), route.newResponse().ProtoReflect()
+		if primaryMessage.Has(field) {
+			primaryField.Set(field, primaryMessage.Get(field))
+		}
+		if shadowMessage.Has(field) {
+			shadowField.Set(field, shadowMessage.Get(field))
+		}
+		if !proto.Equal(primaryField.Interface(), shadowField.Interface()) {
+			fields = append(fields, string(field.Name()))
+		}
+	}
+	if len(fields) == 0 && !bytes.Equal(primaryMessage.GetUnknown(), shadowMessage.GetUnknown()) {
+		fields = append(fields, "unknown fields")
+	}
+	return fields
+}
+
+// routeFor returns a route served by the pool of methodID
+func (o *Orchestrator) routeFor(methodID string) (*Route, bool) {
+	o.routesMu.RLock()
+	defer o.routesMu.RUnlock()
+	for _, route := range o.routes {
+		if route.MethodID == methodID {
+			return route, true
+		}
+	}
+	return nil, false
+}
+
+// outcomeStatus returns the status a request was answered with, OK for a successful response
+func outcomeStatus(o *outcome) *status.Status {
+	if o.err != nil {
+		return status.Convert(o.err)
+	}
+	if err := o.response.GetError().ToGoError(); err != nil {
+		return status.Convert(err)
+	}
+	return status.New(codes.OK, "")
+}
+
+// describeStatus formats st for a mismatch report
+func describeStatus(st *status.Status) string {
+	if st.Code() == codes.OK {
+		return "OK"
+	}
+	return fmt.Sprintf("%s: %s", st.Code(), st.Message())
+}
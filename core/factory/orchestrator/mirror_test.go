@@ -0,0 +1,175 @@
+package orchestrator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bsmider/pipes/core/example/build/example"
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// bookPayload encodes a Book response
+func bookPayload(t *testing.T, book *example.Book) []byte {
+	payload, err := proto.Marshal(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+// errorPacket returns a response packet answered with a status error
+func errorPacket(code codes.Code, message string) *factory.Packet {
+	return &factory.Packet{Error: factory.NewError(status.New(code, message).Proto())}
+}
+
+func TestCompareOutcomes(t *testing.T) {
+	book := bookPayload(t, &example.Book{BookId: "1", AuthorId: "2", Title: "Dune"})
+	retitled := bookPayload(t, &example.Book{BookId: "1", AuthorId: "2", Title: "Dune Messiah"})
+	renumbered := bookPayload(t, &example.Book{BookId: "3", AuthorId: "2", Title: "Dune Messiah"})
+	withUnknown := protowire.AppendString(protowire.AppendTag(append([]byte{}, book...), 99, protowire.BytesType), "extra")
+
+	tests := []struct {
+		name    string
+		method  string
+		primary *outcome
+		shadow  *outcome
+		want    *MirrorMismatch // nil when the outcomes agree
+	}{
+		{
+			name:    "same answer",
+			method:  "book",
+			primary: &outcome{response: &factory.Packet{Payload: book}},
+			shadow:  &outcome{response: &factory.Packet{Payload: book}},
+		},
+		{
+			name:    "one field differs",
+			method:  "book",
+			primary: &outcome{response: &factory.Packet{Payload: book}},
+			shadow:  &outcome{response: &factory.Packet{Payload: retitled}},
+			want:    &MirrorMismatch{Fields: []string{"title"}},
+		},
+		{
+			name:    "several fields differ",
+			method:  "book",
+			primary: &outcome{response: &factory.Packet{Payload: book}},
+			shadow:  &outcome{response: &factory.Packet{Payload: renumbered}},
+			want:    &MirrorMismatch{Fields: []string{"bookId", "title"}},
+		},
+		{
+			name:    "only the status differs",
+			method:  "book",
+			primary: &outcome{response: errorPacket(codes.NotFound, "no book 1")},
+			shadow:  &outcome{err: status.Error(codes.Unavailable, "pool has no active workers")},
+			want:    &MirrorMismatch{PrimaryError: "NotFound: no book 1", ShadowError: "Unavailable: pool has no active workers"},
+		},
+		{
+			name:    "same error",
+			method:  "book",
+			primary: &outcome{response: errorPacket(codes.NotFound, "no book 1")},
+			shadow:  &outcome{response: errorPacket(codes.NotFound, "no book 1")},
+		},
+		{
+			name:    "undecodable payload",
+			method:  "book",
+			primary: &outcome{response: &factory.Packet{Payload: book}},
+			shadow:  &outcome{response: &factory.Packet{Payload: []byte{0xff}}},
+			want:    &MirrorMismatch{Fields: []string{"payload"}},
+		},
+		{
+			name:    "method without a route is compared as bytes",
+			method:  "unrouted",
+			primary: &outcome{response: &factory.Packet{Payload: book}},
+			shadow:  &outcome{response: &factory.Packet{Payload: retitled}},
+			want:    &MirrorMismatch{Fields: []string{"payload"}},
+		},
+		{
+			name:    "only unknown fields differ",
+			method:  "book",
+			primary: &outcome{response: &factory.Packet{Payload: book}},
+			shadow:  &outcome{response: &factory.Packet{Payload: withUnknown}},
+			want:    &MirrorMismatch{Fields: []string{"unknown fields"}},
+		},
+	}
+
+	o := NewOrchestrator()
+	o.AddRoute(NewRoute[*example.GetBookRequest, *example.Book]("BookService", "GetBook", "book"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet := &factory.Packet{Id: "request", TargetIoType: tt.method}
+			got := o.compareOutcomes(packet, tt.primary, tt.shadow)
+			if tt.want != nil {
+				tt.want.Method, tt.want.RequestID = tt.method, "request"
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareOutcomes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// mirroredPool returns an orchestrator whose "primary" pool mirrors every request
+// to a "shadow" pool without workers, which answers every copy at once
+func mirroredPool(t *testing.T) (*Orchestrator, *WorkerPool) {
+	o := NewOrchestrator()
+	o.pools["primary"] = NewWorkerPool(nil, time.Second, 0)
+	o.pools["shadow"] = NewWorkerPool(nil, time.Second, 0)
+	if err := o.Mirror("primary", MirrorPolicy{Shadow: "shadow", Fraction: 1}); err != nil {
+		t.Fatalf("Mirror() failed: %v", err)
+	}
+	return o, o.pools["primary"]
+}
+
+// waitShadows waits until the copies of pool are done
+func waitShadows(t *testing.T, pool *WorkerPool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.mirror.inFlight.Load() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d shadow copies still in flight", pool.mirror.inFlight.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMirrorDropsCopiesOverTheLimit(t *testing.T) {
+	o, pool := mirroredPool(t)
+	pool.mirror.inFlight.Store(mirrorMaxInFlight)
+
+	packet := &factory.Packet{Id: "request", TargetIoType: "primary"}
+	if request := o.mirrorRequest(pool, packet); request != nil {
+		t.Fatal("a request was mirrored with mirrorMaxInFlight copies outstanding")
+	}
+	stats, _ := o.MirrorStats("primary")
+	if stats.Dropped != 1 || stats.Mirrored != 0 {
+		t.Errorf("MirrorStats() = %+v, want one dropped copy", stats)
+	}
+	if inFlight := pool.mirror.inFlight.Load(); inFlight != mirrorMaxInFlight {
+		t.Errorf("%d copies in flight after a drop, want %d", inFlight, mirrorMaxInFlight)
+	}
+}
+
+func TestMirrorComparesAndAbandons(t *testing.T) {
+	o, pool := mirroredPool(t)
+	packet := &factory.Packet{Id: "request", TargetIoType: "primary"}
+
+	// The shadow pool has no workers, a primary failing the same way matches
+	request := o.mirrorRequest(pool, packet)
+	if request == nil {
+		t.Fatal("request not mirrored with a fraction of 1")
+	}
+	request.compare(nil, status.Error(codes.Unavailable, "pool shadow has no active workers"))
+
+	// An abandoned copy is not compared, its goroutine must still end
+	o.mirrorRequest(pool, packet).abandon()
+
+	waitShadows(t, pool)
+	stats, _ := o.MirrorStats("primary")
+	if stats.Mirrored != 2 || stats.Matched+stats.Mismatched != 1 {
+		t.Errorf("MirrorStats() = %+v, want 2 mirrored and only the compared one counted", stats)
+	}
+}
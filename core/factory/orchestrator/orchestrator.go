@@ -1,79 +1,157 @@
 package orchestrator
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/bsmider/pipes/core/factory"
 	"github.com/google/uuid"
 	"golang.org/x/sys/unix"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type Orchestrator struct {
 	pools            map[string]*WorkerPool
 	poolsMu          sync.RWMutex
-	responseChannels sync.Map // Map[packetID]chan *factory.IOPacket
+	responseChannels sync.Map // Map[packetID]*pendingResponse
+	cancels          sync.Map // Map[packetID]context.CancelFunc, for internal requests being routed
+	streams          sync.Map // Map[streamID]*streamSession, for open streams
+	openingStreams   sync.Map // Map[streamID]*openingStream, for streams opened by workers still waiting for a callee
+	retiring         sync.Map // Map[*Worker]struct{}, workers scaled down but still draining
+	healthCheck      HealthCheckConfig
+	restartPolicy    RestartPolicy
+	rolloutPolicy    RolloutPolicy
+	restarts         map[restartKey]*restartState // Map[processType, version]*restartState
+	restartsMu       sync.Mutex
+	routes           map[string]*Route // Map[fullMethod]*Route, used by the ingress servers
+	routesMu         sync.RWMutex
+	ingressPriority  factory.Priority // highest priority ingress requests may claim, see SetIngressPriorityLimit
+	grpcServer       *grpc.Server
+	httpServer       *http.Server
+	draining         atomic.Bool  // set by Shutdown, refuses new requests and restarts
+	inFlight         atomic.Int64 // requests currently being routed
+	autoscaleOnce    sync.Once    // starts the autoscaler with the first scaling policy
+	name             string       // announced to peers, see SetName
+	peers            []*Peer      // connected peer orchestrators
+	peersMu          sync.RWMutex
+	peerNext         atomic.Uint64 // spreads forwarded requests over the peers
+	errors           *errorLog     // recent request failures and worker exits, see RecentErrors
+	stopping         chan struct{} // closed by Shutdown, wakes loops waiting to try again
+	adminServer      *grpc.Server
 }
 
 func NewOrchestrator() *Orchestrator {
 	return &Orchestrator{
-		pools: make(map[string]*WorkerPool),
-		// responseChannels: make(map[string]chan *factory.IOPacket), ... instantiates itself
+		pools:           make(map[string]*WorkerPool),
+		healthCheck:     DefaultHealthCheckConfig(),
+		restartPolicy:   DefaultRestartPolicy(),
+		rolloutPolicy:   DefaultRolloutPolicy(),
+		restarts:        make(map[restartKey]*restartState),
+		routes:          make(map[string]*Route),
+		ingressPriority: factory.Priority_PRIORITY_NORMAL,
+		name:            hostname(),
+		errors:          newErrorLog(recentErrorsKept),
+		stopping:        make(chan struct{}),
+		// responseChannels: make(map[string]*pendingResponse), ... instantiates itself
 	}
 }
 
 // --- Lifecycle Management ---
 
+// pool returns the pool serving processType
+func (o *Orchestrator) pool(processType string) (*WorkerPool, bool) {
+	o.poolsMu.RLock()
+	defer o.poolsMu.RUnlock()
+	pool, ok := o.pools[processType]
+	return pool, ok
+}
+
 func (o *Orchestrator) Spawn(processType string, binaryPath string, count int) error {
 	for i := 0; i < count; i++ {
-		// 1. Create Socketpair
-		fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
-		if err != nil {
+		if _, err := o.spawnWorker(processType, DefaultVersion, binaryPath); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		uuid := uuid.New().String()
-		id := fmt.Sprintf("%s-%.4s", processType, uuid)
-		cmd := exec.Command(binaryPath, "--id", id)
-		workerSide := os.NewFile(uintptr(fds[1]), "worker-socket")
-		cmd.ExtraFiles = []*os.File{workerSide}
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+// spawnWorker starts a single worker process of version and registers it in the pool for processType
+func (o *Orchestrator) spawnWorker(processType string, version string, binaryPath string) (*Worker, error) {
+	worker, err := o.startWorker(processType, version, binaryPath)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := cmd.Start(); err != nil {
-			workerSide.Close()
-			return err
-		}
-		// Close parent's copy of the child's end
-		workerSide.Close()
+	// 4. Register in Pool
+	pool := o.ensurePool(processType, binaryPath)
+	pool.addWorker(worker)
 
-		// 3. Prepare Parent Connection
-		orchSide := os.NewFile(uintptr(fds[0]), "orch-socket")
-		conn, err := net.FileConn(orchSide)
-		if err != nil {
-			return err
-		}
+	// 5. Start the Listen Loop for this specific worker
+	o.runWorker(worker)
 
-		mailbox := make(chan *factory.Packet)
-		worker := NewWorker(id, processType, binaryPath, conn, cmd, mailbox)
+	return worker, nil
+}
 
-		// 4. Register in Pool
-		o.poolsMu.Lock()
-		if _, ok := o.pools[processType]; !ok {
-			o.pools[processType] = NewWorkerPool([]*Worker{}, 3*time.Second, 1)
-		}
-		o.pools[processType].workers = append(o.pools[processType].workers, worker)
-		o.poolsMu.Unlock()
+// startWorker starts a worker process of binaryPath, as version, connected by a socketpair.
+// It is not part of any pool yet and nothing reads from it until runWorker.
+func (o *Orchestrator) startWorker(processType string, version string, binaryPath string) (*Worker, error) {
+	// 1. Create Socketpair
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, err
+	}
 
-		// 5. Start the Listen Loop for this specific worker
-		go worker.listen()
-		go o.handleWorkerMailbox(worker)
+	uuid := uuid.New().String()
+	id := fmt.Sprintf("%s-%.4s", processType, uuid)
+	cmd := exec.Command(binaryPath, "--id", id)
+	workerSide := os.NewFile(uintptr(fds[1]), "worker-socket")
+	orchSide := os.NewFile(uintptr(fds[0]), "orch-socket")
+	cmd.ExtraFiles = []*os.File{workerSide}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// Keep terminal signals away from the worker, the orchestrator drains it on shutdown
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		workerSide.Close()
+		orchSide.Close()
+		return nil, err
 	}
-	return nil
+	// Close parent's copy of the child's end
+	workerSide.Close()
+
+	// 3. Prepare Parent Connection
+	conn, err := net.FileConn(orchSide)
+	orchSide.Close() // FileConn dups the descriptor
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+
+	mailbox := make(chan *factory.Packet)
+	worker := NewWorker(id, processType, binaryPath, conn, cmd, mailbox)
+	worker.version = version
+	return worker, nil
+}
+
+// runWorker starts reading from worker, handling its packets and checking its health
+func (o *Orchestrator) runWorker(worker *Worker) {
+	go worker.listen()
+	go o.handleWorkerMailbox(worker)
+	go o.monitorHealth(worker)
 }
 
 func (o *Orchestrator) handleWorkerMailbox(worker *Worker) {
@@ -86,128 +164,286 @@ func (o *Orchestrator) handleWorkerMailbox(worker *Worker) {
 		if packet.Type == factory.PacketType_PACKET_TYPE_RESPONSE {
 			o.routeResponse(packet)
 		}
+
+		if packet.Type == factory.PacketType_PACKET_TYPE_PONG {
+			worker.recordPong(packet)
+		}
+
+		if packet.Type == factory.PacketType_PACKET_TYPE_CANCEL {
+			if !o.cancelStream(packet.Id) {
+				o.cancelRequest(packet.Id)
+			}
+		}
+
+		if packet.Type == factory.PacketType_PACKET_TYPE_STREAM_OPEN {
+			o.openInternalStream(worker, packet)
+		}
+
+		switch packet.Type {
+		case factory.PacketType_PACKET_TYPE_STREAM_DATA,
+			factory.PacketType_PACKET_TYPE_STREAM_END,
+			factory.PacketType_PACKET_TYPE_STREAM_WINDOW:
+			o.routeStreamPacket(worker, packet)
+		}
 	}
+
+	// The mailbox is closed once the worker's connection is gone
+	o.handleWorkerExit(worker)
 }
 
+// RouteRequest routes a request packet to a worker of its TargetIoType and waits for the response.
 func (o *Orchestrator) RouteRequest(packet *factory.Packet) (*factory.Packet, error) {
-	o.poolsMu.RLock()
-	pool, exists := o.pools[packet.TargetIoType]
-	o.poolsMu.RUnlock()
+	return o.RouteRequestContext(context.Background(), packet)
+}
 
-	if !exists {
-		return nil, fmt.Errorf("no workers available for target type: %s", packet.TargetIoType)
+// RouteRequestContext is RouteRequest bound to ctx. When ctx is done the worker
+// handling the packet is sent a cancel and ctx's error is returned.
+func (o *Orchestrator) RouteRequestContext(ctx context.Context, packet *factory.Packet) (*factory.Packet, error) {
+	if o.draining.Load() {
+		return nil, fmt.Errorf("orchestrator is shutting down")
 	}
 
-	var lastErr error
+	o.inFlight.Add(1)
+	defer o.inFlight.Add(-1)
 
-	// The retry loop: attempt 0 is the first try, then up to pool.retries
-	for attempt := 0; attempt <= pool.retries; attempt++ {
+	return o.route(ctx, packet)
+}
 
-		// 1. Select a worker for this specific attempt
-		worker := pool.SelectWorker()
-		if worker == nil {
-			lastErr = fmt.Errorf("pool %s has no active workers", packet.TargetIoType)
-			continue
-		}
+func (o *Orchestrator) handleInternalRequest(requester *Worker, packet *factory.Packet) {
+	o.answerRequest(requester.id, requester.sendPacket, packet, o.route)
+}
 
-		// 2. Setup the response tracking channel
-		// We use a buffer of 1 so the 'RouteResponse' logic doesn't block
-		// if this loop has already timed out.
-		respChan := make(chan *factory.Packet, 1)
-		o.responseChannels.Store(packet.Id, respChan)
-
-		// 3. Dispatch the packet
-		if err := worker.sendPacket(packet); err != nil {
-			o.responseChannels.Delete(packet.Id)
-			lastErr = fmt.Errorf("worker %s send error: %w", worker.id, err)
-			continue // Try next attempt with a different worker
+// answerRequest routes a request sent by a worker or a peer with route, and sends
+// the response back with reply
+func (o *Orchestrator) answerRequest(requester string, reply func(*factory.Packet) error, packet *factory.Packet, route func(context.Context, *factory.Packet) (*factory.Packet, error)) {
+	// Internal requests are still served while shutting down,
+	// in-flight requests may depend on them to finish
+	o.inFlight.Add(1)
+	defer o.inFlight.Add(-1)
+
+	// The requester can cancel the call with a CANCEL packet carrying the same id
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancels.Store(packet.Id, cancel)
+	defer func() {
+		o.cancels.Delete(packet.Id)
+		cancel()
+	}()
+
+	response, err := route(ctx, packet)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			// The requester gave up, nobody is waiting for an answer
+			return
 		}
+		response = factory.NewPacket(packet.Id, factory.PacketType_PACKET_TYPE_RESPONSE, packet.TargetIoType, packet.Context, nil, (&factory.Error{}).FromGoError(err))
+	}
 
-		// 4. Wait for response OR timeout
-		//
-		select {
-		case response := <-respChan:
-			// SUCCESS: Cleanup and return the result
-			o.responseChannels.Delete(packet.Id)
-			return response, nil
-
-		case <-time.After(pool.timeout):
-			// TIMEOUT: Cleanup and log
-			o.responseChannels.Delete(packet.Id)
-			lastErr = fmt.Errorf("attempt %d: timed out after %v", attempt, pool.timeout)
-			// Loop continues to next retry
-		}
+	if err := reply(response); err != nil {
+		log.Printf("[Orchestrator] Failed to send response %s to %s: %v", packet.Id, requester, err)
 	}
+}
 
-	return nil, fmt.Errorf("request failed after %d retries. Last error: %v", pool.retries, lastErr)
+// cancelRequest stops routing the packet with the given id, which in turn cancels it on its worker
+func (o *Orchestrator) cancelRequest(packetID string) {
+	if cancel, ok := o.cancels.Load(packetID); ok {
+		cancel.(context.CancelFunc)()
+	}
 }
 
-func (o *Orchestrator) handleInternalRequest(requester *Worker, packet *factory.Packet) (*factory.Packet, error) {
-	o.poolsMu.RLock()
-	pool, exists := o.pools[packet.TargetIoType]
-	o.poolsMu.RUnlock()
+// route sends packet to a worker of its pool, or forwards it to a peer serving its
+// method when there is no local pool or the local pool has no workers.
+func (o *Orchestrator) route(ctx context.Context, packet *factory.Packet) (*factory.Packet, error) {
+	if pool, exists := o.pool(packet.TargetIoType); !exists || pool.Size() == 0 {
+		if peer, ok := o.peerFor(packet.TargetIoType); ok {
+			return o.forward(ctx, peer, packet)
+		}
+	}
+	return o.routeLocal(ctx, packet)
+}
 
+// routeLocal sends packet to a worker of its pool unless the pool's admission policy
+// rejects it or its circuit breaker is open.
+// A worker's error status is returned in the response packet, failures to get
+// an answer at all are returned as gRPC status errors.
+func (o *Orchestrator) routeLocal(ctx context.Context, packet *factory.Packet) (response *factory.Packet, err error) {
+	pool, exists := o.pool(packet.TargetIoType)
 	if !exists {
-		return nil, fmt.Errorf("no workers available for target type: %s", packet.TargetIoType)
+		return nil, status.Errorf(codes.Unavailable, "no workers available for target type: %s", packet.TargetIoType)
 	}
+	defer func() { pool.countRequest(response, err) }()
 
-	var lastErr error
+	// Turn the request away if the method or its caller is over its limits
+	release, err := pool.admit(packet.TargetIoType, callerOf(packet))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	// The retry loop: attempt 0 is the first try, then up to pool.retries
-	for attempt := 0; attempt <= pool.retries; attempt++ {
+	// Fail fast while the pool keeps failing, the request never reaches a worker
+	generation, ok := pool.breaker.allow()
+	if !ok {
+		st := status.Newf(codes.Unavailable, "circuit breaker for %s is open", packet.TargetIoType)
+		return nil, factory.MarkNotProcessed(st).Err()
+	}
 
-		// 1. Select a worker for this specific attempt
-		worker := pool.SelectWorker()
-		if worker == nil {
-			lastErr = fmt.Errorf("pool %s has no active workers", packet.TargetIoType)
-			continue
+	// A copy for the shadow pool, if any, runs alongside and is compared in the background
+	shadow := o.mirrorRequest(pool, packet)
+
+	response, err = o.send(ctx, pool, packet)
+	if ctx.Err() != nil && response == nil {
+		// The caller gave up, that says nothing about the pool
+		pool.breaker.forget(generation)
+		shadow.abandon()
+	} else {
+		pool.breaker.record(generation, breakerFailed(response, err))
+		shadow.compare(response, err)
+	}
+	return response, err
+}
+
+// send sends packet to a worker of pool, retrying failed attempts as the
+// pool's RetryPolicy allows or hedging them as its HedgingPolicy does.
+func (o *Orchestrator) send(ctx context.Context, pool *WorkerPool, packet *factory.Packet) (*factory.Packet, error) {
+	// Honour the deadline the packet carries
+	if deadline := packet.Context.GetDeadline(); deadline != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.AsTime())
+		defer cancel()
+	}
+
+	timeout, policy, _ := pool.limits()
+	if policy.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Deadline)
+		defer cancel()
+	}
+
+	if hedging := pool.hedging(); hedging.enabled() {
+		return o.hedge(ctx, pool, packet, hedging, timeout)
+	}
+
+	// Retries go to another worker where there is one
+	tried := newWorkerSet(true)
+	for attempt := 0; ; attempt++ {
+		result := o.attempt(ctx, pool, packet, attempt, timeout, tried)
+		if ctx.Err() != nil && result.response == nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
 		}
 
-		// 2. Setup the response tracking channel
-		// We use a buffer of 1 so the 'RouteResponse' logic doesn't block
-		// if this loop has already timed out.
-		respChan := make(chan *factory.Packet, 1)
-		o.responseChannels.Store(packet.Id, respChan)
-
-		// 3. Dispatch the packet
-		if err := worker.sendPacket(packet); err != nil {
-			o.responseChannels.Delete(packet.Id)
-			lastErr = fmt.Errorf("worker %s send error: %w", worker.id, err)
-			continue // Try next attempt with a different worker
+		if !policy.shouldRetry(result, attempt) {
+			return settle(result, attempt+1)
 		}
 
-		// 4. Wait for response OR timeout
-		select {
-		case response := <-respChan:
-			// SUCCESS: Cleanup and return the result
-			requester.sendPacket(response)
-			o.responseChannels.Delete(packet.Id)
-			return nil, nil
-
-		case <-time.After(pool.timeout):
-			// TIMEOUT: Cleanup and log
-			o.responseChannels.Delete(packet.Id)
-			lastErr = fmt.Errorf("attempt %d: timed out after %v", attempt, pool.timeout)
-			// Loop continues to next retry
+		// Back off before the next attempt
+		delay := policy.backoff(attempt)
+		log.Printf("[Orchestrator] Retrying %s in %v after attempt %d failed: %s", packet.Id, delay, attempt+1, result.status.Message())
+		if err := sleep(ctx, delay); err != nil {
+			return nil, status.FromContextError(err).Err()
 		}
 	}
+}
+
+// attempt sends packet to a single worker of the pool that is not in tried and waits for its answer.
+// The worker is added to tried, which may be nil.
+func (o *Orchestrator) attempt(ctx context.Context, pool *WorkerPool, packet *factory.Packet, attempt int, timeout time.Duration, tried *workerSet) attemptResult {
+	// 1. Select a worker for this specific attempt, waiting up to the
+	// timeout for one with a free slot. Each attempt picks a version anew.
+	version := pool.pickVersion(packet.Context.GetVersion())
+	acquireCtx, cancelAcquire := context.WithTimeout(ctx, timeout)
+	worker, err := pool.acquire(acquireCtx, tried, packet.Context.GetPriority(), version)
+	cancelAcquire()
+	if err != nil {
+		return failed(codes.Unavailable, false, "attempt %d: no worker had a free slot within %v", attempt+1, timeout)
+	}
+	if worker == nil {
+		return failed(codes.Unavailable, false, "pool %s has no active workers", packet.TargetIoType)
+	}
+	tried.add(worker)
+	defer pool.release(worker)
+
+	// 2. Setup the response tracking channel
+	// Every attempt is sent with its own id, a late response to an earlier
+	// attempt then finds no channel and is dropped by routeResponse.
+	// We use a buffer of 1 so the 'RouteResponse' logic doesn't block
+	// if this attempt has already timed out.
+	sent := proto.Clone(packet).(*factory.Packet)
+	sent.Id = attemptID(packet.Id, attempt)
+	respChan := o.expectResponse(sent.Id, packet.TargetIoType, worker.id)
+	defer o.responseChannels.Delete(sent.Id)
+
+	// 3. Dispatch the packet
+	start := time.Now()
+	if err := worker.sendPacket(sent); err != nil {
+		result := failed(codes.Unavailable, false, "worker %s send error: %v", worker.id, err)
+		o.recordError(packet, worker, result.status)
+		return result
+	}
+
+	// 4. Wait for response, timeout OR cancellation
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
-	return nil, fmt.Errorf("request failed after %d retries. Last error: %v", pool.retries, lastErr)
+	select {
+	case response := <-respChan:
+		pool.recordLatency(time.Since(start))
+		pool.recordVersion(worker.version, time.Since(start), response.Error.ToGoError() != nil)
+		response.Id = packet.Id
+		result := answered(response)
+		if result.status != nil {
+			o.recordError(packet, worker, result.status)
+		}
+		return result
+
+	case <-timer.C:
+		// TIMEOUT: stop the worker from running it any further
+		worker.cancel(sent)
+		pool.recordLatency(timeout)
+		pool.recordVersion(worker.version, timeout, true)
+		result := failed(codes.DeadlineExceeded, true, "attempt %d: timed out after %v", attempt+1, timeout)
+		o.recordError(packet, worker, result.status)
+		return result
+
+	case <-ctx.Done():
+		// CANCELLED: the caller gave up, pass it on to the worker
+		worker.cancel(sent)
+		return failed(codes.Canceled, true, "attempt %d: %v", attempt+1, ctx.Err())
+	}
+}
+
+// pendingResponse is a request sent to a worker or peer, waiting in responseChannels for its answer
+type pendingResponse struct {
+	ch       chan *factory.Packet
+	methodID string
+	target   string // the worker or peer the request was sent to
+	since    time.Time
+}
+
+// expectResponse registers a channel for the response to the packet with id, sent to target.
+// The caller removes it from responseChannels once it stops waiting.
+func (o *Orchestrator) expectResponse(id string, methodID string, target string) chan *factory.Packet {
+	pending := &pendingResponse{
+		ch:       make(chan *factory.Packet, 1),
+		methodID: methodID,
+		target:   target,
+		since:    time.Now(),
+	}
+	o.responseChannels.Store(id, pending)
+	return pending.ch
 }
 
 func (o *Orchestrator) routeResponse(packet *factory.Packet) error {
-	responseChannel, ok := o.responseChannels.Load(packet.Id)
+	value, ok := o.responseChannels.Load(packet.Id)
 	if !ok {
 		return fmt.Errorf("no response channel found for packet ID: %s", packet.Id)
 	}
 
-	ch, ok := responseChannel.(chan *factory.Packet)
+	pending, ok := value.(*pendingResponse)
 	if !ok {
 		return fmt.Errorf("response channel for packet ID %s is not a channel", packet.Id)
 	}
 
 	select {
-	case ch <- packet:
+	case pending.ch <- packet:
 		return nil
 	default:
 		return fmt.Errorf("requester channel for ID %s is full", packet.Id)
@@ -0,0 +1,164 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+)
+
+// PoolOptions configures the pool serving a single method
+type PoolOptions struct {
+	Timeout        time.Duration   // How long an attempt waits for a response before it is retried
+	Retries        int             // Extra attempts after the first one, 0 never retries
+	Replicas       int             // Workers started with the pool, 0 leaves the method to remote workers
+	MaxReplicas    int             // The pool autoscales up to this many workers, at most Replicas disables autoscaling. Ignored when Replicas is 0
+	TargetLatency  time.Duration   // Average latency above which an autoscaled pool grows, see ScalingPolicy
+	MaxConcurrency int             // Requests in flight per worker, further requests wait for a free slot. 0 is unlimited
+	Balancer       Balancer        // How workers are picked, nil keeps round robin
+	Retry          RetryPolicy     // Which failed attempts are retried, its MaxAttempts defaults to Retries + 1
+	Hedging        HedgingPolicy   // Copies of slow requests sent to other workers, replaces Retry when enabled
+	Breaker        BreakerPolicy   // When the pool stops taking requests because its workers keep failing
+	Admission      AdmissionPolicy // Rate limits and concurrency caps of the method, per caller too
+}
+
+// DefaultPoolOptions returns the options Spawn uses
+func DefaultPoolOptions() PoolOptions {
+	return PoolOptions{
+		Timeout:  3 * time.Second,
+		Retries:  1,
+		Replicas: 1,
+		Retry:    DefaultRetryPolicy(),
+		Breaker:  DefaultBreakerPolicy(),
+	}
+}
+
+// SpawnWithOptions starts opts.Replicas workers of binaryPath for processType and
+// configures their pool with opts. Options of an existing pool are replaced.
+// With zero Replicas the pool only takes remote workers and is never autoscaled.
+func (o *Orchestrator) SpawnWithOptions(processType string, binaryPath string, opts PoolOptions) error {
+	if opts.Timeout <= 0 || opts.Retries < 0 || opts.MaxConcurrency < 0 || opts.Retry.MaxAttempts < 0 || opts.Hedging.Delay < 0 {
+		return fmt.Errorf("invalid pool options for %s: timeout %v, retries %d, max concurrency %d, retry max attempts %d, hedging delay %v",
+			processType, opts.Timeout, opts.Retries, opts.MaxConcurrency, opts.Retry.MaxAttempts, opts.Hedging.Delay)
+	}
+
+	pool := o.ensurePool(processType, binaryPath)
+	pool.configure(opts)
+
+	if err := o.Spawn(processType, binaryPath, opts.Replicas-pool.Size()); err != nil {
+		return err
+	}
+
+	if opts.Replicas > 0 && opts.MaxReplicas > opts.Replicas {
+		policy := DefaultScalingPolicy(opts.Replicas, opts.MaxReplicas)
+		policy.TargetLatency = opts.TargetLatency
+		// A pool whose workers are all at their limit is saturated
+		if opts.MaxConcurrency > 0 && float64(opts.MaxConcurrency) < policy.TargetInFlight {
+			policy.TargetInFlight = float64(opts.MaxConcurrency)
+		}
+		return o.SetScalingPolicy(processType, policy)
+	}
+	return nil
+}
+
+// ensurePool returns the pool for processType, creating it with the default options if needed
+// Peers are told about the new method.
+func (o *Orchestrator) ensurePool(processType string, binaryPath string) *WorkerPool {
+	o.poolsMu.Lock()
+	pool, ok := o.pools[processType]
+	if !ok {
+		defaults := DefaultPoolOptions()
+		pool = NewWorkerPool([]*Worker{}, defaults.Timeout, defaults.Retries)
+		pool.binaryPath = binaryPath
+		pool.breaker = NewCircuitBreaker(processType, defaults.Breaker)
+		o.pools[processType] = pool
+	}
+	o.poolsMu.Unlock()
+
+	if !ok {
+		o.announce()
+	}
+	return pool
+}
+
+// configure applies opts to the pool
+func (p *WorkerPool) configure(opts PoolOptions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.timeout = opts.Timeout
+	p.retry = opts.Retry
+	if p.retry.MaxAttempts == 0 {
+		p.retry.MaxAttempts = opts.Retries + 1
+	}
+	p.hedgingPolicy = opts.Hedging
+	p.breaker.SetPolicy(opts.Breaker)
+	p.admission = newAdmission(opts.Admission)
+	p.maxConcurrency = opts.MaxConcurrency
+	if opts.Balancer != nil {
+		p.balancer = opts.Balancer
+	}
+}
+
+// limits returns the attempt timeout, retry policy and per-worker concurrency of the pool
+func (p *WorkerPool) limits() (time.Duration, RetryPolicy, int) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.timeout, p.retry, p.maxConcurrency
+}
+
+// selectWithCapacity lets the balancer pick among the healthy workers of version outside exclude
+// with a free slot, an empty version matches every worker.
+// A worker's slots are bounded by the pool's limit and the limit the worker advertised.
+// full reports that there are such workers but all of them are busy.
+// Workers in a fallback exclude set are picked once no other healthy worker is left.
+func (p *WorkerPool) selectWithCapacity(exclude *workerSet, version string) (worker *Worker, full bool) {
+	p.mu.RLock()
+	workers, balancer, limit := p.workers, p.balancer, p.maxConcurrency
+	p.mu.RUnlock()
+
+	for {
+		candidates := make([]*Worker, 0, len(workers))
+		healthy, excluded := 0, 0
+		for _, w := range workers {
+			if !w.IsHealthy() || (version != "" && w.version != version) {
+				continue
+			}
+			if exclude.contains(w) {
+				excluded++
+				continue
+			}
+			healthy++
+			if limit := w.limit(limit); limit == 0 || w.InFlight() < int64(limit) {
+				candidates = append(candidates, w)
+			}
+		}
+		if len(candidates) == 0 {
+			if healthy == 0 && excluded > 0 && exclude.fallback {
+				// Only workers already tried are left, pick among them
+				exclude = nil
+				continue
+			}
+			return nil, healthy > 0
+		}
+
+		worker = balancer.Select(candidates)
+		if worker.tryAcquire(worker.limit(limit)) {
+			return worker, false
+		}
+		// Another request took the last slot first, look again
+	}
+}
+
+// tryAcquire counts a request against the worker unless it already has limit in flight.
+// A limit of 0 is unlimited.
+func (w *Worker) tryAcquire(limit int) bool {
+	for {
+		current := w.inFlight.Load()
+		if limit > 0 && current >= int64(limit) {
+			return false
+		}
+		if w.inFlight.CompareAndSwap(current, current+1) {
+			return true
+		}
+	}
+}
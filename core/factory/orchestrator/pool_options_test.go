@@ -0,0 +1,55 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpawnWithOptionsRejectsInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*PoolOptions)
+		want   string // part of the error naming the failing field
+	}{
+		{"timeout", func(o *PoolOptions) { o.Timeout = 0 }, "timeout 0s"},
+		{"retries", func(o *PoolOptions) { o.Retries = -1 }, "retries -1"},
+		{"max concurrency", func(o *PoolOptions) { o.MaxConcurrency = -1 }, "max concurrency -1"},
+		{"retry max attempts", func(o *PoolOptions) { o.Retry.MaxAttempts = -2 }, "retry max attempts -2"},
+		{"hedging delay", func(o *PoolOptions) { o.Hedging.Delay = -time.Second }, "hedging delay -1s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultPoolOptions()
+			tt.modify(&opts)
+
+			err := NewOrchestrator().SpawnWithOptions("test", "/nonexistent", opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("SpawnWithOptions() = %v, want an error with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpawnWithOptionsZeroReplicas(t *testing.T) {
+	o := NewOrchestrator()
+	opts := DefaultPoolOptions()
+	opts.Replicas = 0
+	opts.MaxReplicas = 4 // The generated orchestrator's default, ignored for remote only pools
+
+	if err := o.SpawnWithOptions("test", "/nonexistent", opts); err != nil {
+		t.Fatalf("SpawnWithOptions() with zero replicas failed: %v", err)
+	}
+
+	pool, ok := o.pool("test")
+	if !ok {
+		t.Fatal("no pool was created for remote workers to register into")
+	}
+	if size := pool.Size(); size != 0 {
+		t.Errorf("pool started %d local workers, want 0", size)
+	}
+	if pool.scaling != nil {
+		t.Errorf("pool of remote workers only is autoscaled: %+v", *pool.scaling)
+	}
+}
@@ -0,0 +1,171 @@
+package orchestrator
+
+import (
+	"container/heap"
+	"context"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+)
+
+// priorityAging is how long a queued request waits before it is dispatched as if it
+// had the next higher priority, so low priority traffic is never starved for good
+const priorityAging = time.Second
+
+// waiter is a request queued for a free slot in a saturated pool
+type waiter struct {
+	priority factory.Priority
+	exclude  *workerSet
+	version  string // only workers of this version are handed over, "" for any
+	queued   time.Time
+	seq      uint64
+	handoff  chan *Worker // receives the worker the request may use, nil if the pool has none left
+	index    int          // position in the pool's queue, -1 once it left it
+}
+
+// due orders the queue: the time the waiter was queued, moved back priorityAging for
+// every priority class above low. It does not change while the waiter ages, so the
+// queue stays a heap.
+func (w *waiter) due() time.Time {
+	return w.queued.Add(-time.Duration(w.priority.Rank()) * priorityAging)
+}
+
+// waitQueue orders the waiters of a pool by due time, a heap for container/heap
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if due, other := q[i].due(), q[j].due(); !due.Equal(other) {
+		return due.Before(other)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// acquire picks a worker of version that is not in exclude and counts the request against it.
+// An empty version matches every worker.
+// When every worker is at its concurrency limit the request is queued until ctx is done,
+// and queued requests are handed free slots by priority, see dispatch.
+// A nil worker without an error means the pool has no healthy workers left to pick.
+func (p *WorkerPool) acquire(ctx context.Context, exclude *workerSet, priority factory.Priority, version string) (*Worker, error) {
+	p.queueMu.Lock()
+	// Only go straight to a worker if nobody is queued ahead
+	if len(p.queue) == 0 {
+		worker, full := p.selectWithCapacity(exclude, version)
+		if worker != nil || !full {
+			p.queueMu.Unlock()
+			return worker, nil
+		}
+	}
+
+	w := &waiter{
+		priority: priority,
+		exclude:  exclude,
+		version:  version,
+		queued:   time.Now(),
+		handoff:  make(chan *Worker, 1),
+	}
+	p.enqueue(w)
+	p.queueMu.Unlock()
+
+	// A slot may be free for the request even with others queued ahead, e.g. one they exclude
+	p.dispatch()
+
+	select {
+	case worker := <-w.handoff:
+		return worker, nil
+	case <-ctx.Done():
+		p.queueMu.Lock()
+		p.dequeue(w)
+		p.queueMu.Unlock()
+
+		// A worker handed over before the waiter left the queue goes back
+		select {
+		case worker := <-w.handoff:
+			if worker != nil {
+				p.release(worker)
+			}
+		default:
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// release gives back the slot a request held on worker
+func (p *WorkerPool) release(worker *Worker) {
+	worker.inFlight.Add(-1)
+	p.dispatch()
+}
+
+// dispatch hands free slots to queued requests, highest priority first
+func (p *WorkerPool) dispatch() {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+
+	// Waiters passed over because they exclude the free workers go back once dispatch is done
+	var skipped []*waiter
+	defer func() {
+		for _, w := range skipped {
+			heap.Push(&p.queue, w)
+		}
+	}()
+
+	for len(p.queue) > 0 {
+		w := p.queue[0]
+		worker, full := p.selectWithCapacity(w.exclude, w.version)
+		if worker == nil && full {
+			if w.exclude.empty() && w.version == "" {
+				// Nothing is free, waiters further back cannot be served either
+				return
+			}
+			skipped = append(skipped, heap.Pop(&p.queue).(*waiter))
+			continue
+		}
+
+		heap.Pop(&p.queue)
+		w.handoff <- worker
+	}
+}
+
+// enqueue adds w to the queue, behind the waiters of its priority queued before it.
+// The caller holds queueMu.
+func (p *WorkerPool) enqueue(w *waiter) {
+	p.queued++
+	w.seq = p.queued
+	heap.Push(&p.queue, w)
+}
+
+// dequeue removes w from the queue if it is still in it
+func (p *WorkerPool) dequeue(w *waiter) {
+	if w.index >= 0 {
+		heap.Remove(&p.queue, w.index)
+	}
+}
+
+// Queued returns the number of requests waiting for a free slot
+func (p *WorkerPool) Queued() int {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	return len(p.queue)
+}
@@ -0,0 +1,171 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+)
+
+// queued is a request put in a saturated pool's queue in TestQueueOrder
+type queued struct {
+	name     string
+	priority factory.Priority
+	waited   time.Duration // How long before the dispatch it was queued
+}
+
+func TestQueueOrder(t *testing.T) {
+	high, normal, low := factory.Priority_PRIORITY_HIGH, factory.Priority_PRIORITY_NORMAL, factory.Priority_PRIORITY_LOW
+
+	tests := []struct {
+		name   string
+		queued []queued
+		want   []string // Names in the order free slots are handed to them
+	}{
+		{
+			name:   "higher priorities first",
+			queued: []queued{{"low", low, 0}, {"normal", normal, 0}, {"high", high, 0}},
+			want:   []string{"high", "normal", "low"},
+		},
+		{
+			name:   "first come first served within a priority",
+			queued: []queued{{"a", normal, 0}, {"b", normal, 0}, {"c", normal, 0}},
+			want:   []string{"a", "b", "c"},
+		},
+		{
+			name:   "waiting less than priorityAging keeps the priority order",
+			queued: []queued{{"low", low, priorityAging / 2}, {"normal", normal, 0}},
+			want:   []string{"normal", "low"},
+		},
+		{
+			name:   "a request ages into the next priority",
+			queued: []queued{{"low", low, 3 * priorityAging / 2}, {"normal", normal, 0}},
+			want:   []string{"low", "normal"},
+		},
+		{
+			name:   "aging is not capped at high",
+			queued: []queued{{"high", high, 0}, {"low", low, 3 * priorityAging}},
+			want:   []string{"low", "high"},
+		},
+		{
+			name: "requests due at the same time keep their queue order",
+			queued: []queued{
+				{"normal", normal, 2 * priorityAging},
+				{"low", low, 3 * priorityAging},
+				{"high", high, 0},
+			},
+			want: []string{"normal", "low", "high"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := testWorker("a")
+			pool := NewWorkerPool([]*Worker{worker}, time.Second, 0)
+			pool.maxConcurrency = 1
+			worker.inFlight.Add(1)
+
+			now := time.Now()
+			waiters := make(map[*waiter]string)
+			pool.queueMu.Lock()
+			for _, q := range tt.queued {
+				w := &waiter{priority: q.priority, queued: now.Add(-q.waited), handoff: make(chan *Worker, 1)}
+				pool.enqueue(w)
+				waiters[w] = q.name
+			}
+			pool.queueMu.Unlock()
+
+			var got []string
+			for range tt.queued {
+				pool.release(worker)
+				handed := 0
+				for w, name := range waiters {
+					select {
+					case <-w.handoff:
+						got = append(got, name)
+						delete(waiters, w)
+						handed++
+					default:
+					}
+				}
+				if handed != 1 {
+					t.Fatalf("a free slot was handed to %d requests, want 1", handed)
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("handed to %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("handed to %v, want %v", got, tt.want)
+				}
+			}
+			if queued := pool.Queued(); queued != 0 {
+				t.Errorf("%d requests left in the queue", queued)
+			}
+		})
+	}
+}
+
+func TestQueueSkipsRequestsExcludingTheFreeWorker(t *testing.T) {
+	a, b := testWorker("a"), testWorker("b")
+	pool := NewWorkerPool([]*Worker{a, b}, time.Second, 0)
+	pool.maxConcurrency = 1
+	a.inFlight.Add(1)
+	b.inFlight.Add(1)
+
+	tried := newWorkerSet(false)
+	tried.add(a)
+	retry := &waiter{priority: factory.Priority_PRIORITY_HIGH, exclude: tried, queued: time.Now(), handoff: make(chan *Worker, 1)}
+	fresh := &waiter{priority: factory.Priority_PRIORITY_LOW, queued: time.Now(), handoff: make(chan *Worker, 1)}
+	pool.queueMu.Lock()
+	pool.enqueue(retry)
+	pool.enqueue(fresh)
+	pool.queueMu.Unlock()
+
+	// Only a is free, the retry that already tried it stays queued ahead
+	pool.release(a)
+	select {
+	case worker := <-fresh.handoff:
+		if worker != a {
+			t.Fatalf("handed %s, want a", worker.ID())
+		}
+	default:
+		t.Fatal("the request behind a retry excluding the free worker was not handed it")
+	}
+
+	pool.release(b)
+	select {
+	case worker := <-retry.handoff:
+		if worker != b {
+			t.Fatalf("handed %s to the retry, want b", worker.ID())
+		}
+	default:
+		t.Fatal("the retry was not handed the worker it had not tried")
+	}
+}
+
+func TestWaiterLeavesQueue(t *testing.T) {
+	pool := NewWorkerPool(nil, time.Second, 0)
+	var waiters []*waiter
+	pool.queueMu.Lock()
+	for i := 0; i < 5; i++ {
+		w := &waiter{queued: time.Now(), handoff: make(chan *Worker, 1)}
+		pool.enqueue(w)
+		waiters = append(waiters, w)
+	}
+	pool.dequeue(waiters[2])
+	pool.dequeue(waiters[2])
+	pool.dequeue(waiters[0])
+	pool.queueMu.Unlock()
+
+	if queued := pool.Queued(); queued != 3 {
+		t.Fatalf("Queued() = %d, want 3", queued)
+	}
+	for i, w := range pool.queue {
+		if w.index != i {
+			t.Errorf("waiter at %d has index %d", i, w.index)
+		}
+	}
+}
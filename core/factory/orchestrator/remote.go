@@ -0,0 +1,107 @@
+package orchestrator
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"github.com/bsmider/pipes/core/factory/utils"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// registerTimeout is how long a worker connecting has to complete the TLS handshake, if any, and register
+const registerTimeout = 10 * time.Second
+
+// ServeRemoteWorkers accepts workers started elsewhere on lis and registers each into the
+// pool of the method it names. lis should authenticate the workers, e.g. a listener from
+// tls.NewListener with utils.ServerTLSConfig, or only be reachable locally, see ListenAttachSocket.
+// Only methods that already have a pool are accepted, use SpawnWithOptions with zero Replicas
+// for a method served by remote workers only. It blocks until lis is closed.
+func (o *Orchestrator) ServeRemoteWorkers(lis net.Listener) error {
+	log.Printf("[Orchestrator] Accepting remote workers on %s", lis.Addr())
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go o.registerRemoteWorker(conn)
+	}
+}
+
+// registerRemoteWorker performs the REGISTER handshake on a new connection and
+// adds the worker to its pool, or closes the connection if it is refused
+func (o *Orchestrator) registerRemoteWorker(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(registerTimeout))
+
+	// 1. Authenticate the worker before reading anything from it
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			log.Printf("[Orchestrator] Refused remote worker %s: %v", peerName(conn), err)
+			conn.Close()
+			return
+		}
+	}
+
+	// 2. Read the method the worker serves
+	hello := &factory.Packet{}
+	if err := utils.ReadMessageLimit(conn, hello, utils.MaxHandshakeSize); err != nil {
+		log.Printf("[Orchestrator] Refused remote worker %s: %v", peerName(conn), err)
+		conn.Close()
+		return
+	}
+	if hello.Type != factory.PacketType_PACKET_TYPE_REGISTER {
+		o.refuseRemoteWorker(conn, hello, status.Newf(codes.InvalidArgument, "expected a register packet, got %s", hello.Type))
+		return
+	}
+
+	processType := hello.TargetIoType
+	pool, ok := o.pool(processType)
+	if !ok {
+		o.refuseRemoteWorker(conn, hello, status.Newf(codes.NotFound, "no pool for target type: %s", processType))
+		return
+	}
+	if o.draining.Load() {
+		o.refuseRemoteWorker(conn, hello, status.New(codes.Unavailable, "orchestrator is shutting down"))
+		return
+	}
+
+	// 3. Assign the worker an id and acknowledge it
+	id := fmt.Sprintf("%s-%.4s", processType, uuid.New().String())
+	ack := factory.NewPacket(id, factory.PacketType_PACKET_TYPE_REGISTER, processType, nil, nil, nil)
+	if err := utils.WriteMessage(conn, nil, ack); err != nil {
+		log.Printf("[Orchestrator] Failed to register remote worker %s: %v", peerName(conn), err)
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	// 4. Register it like a spawned worker
+	mailbox := make(chan *factory.Packet)
+	worker := NewWorker(id, processType, "", conn, nil, mailbox)
+	worker.peer = peerName(conn)
+	pool.addWorker(worker)
+
+	o.runWorker(worker)
+
+	log.Printf("[Orchestrator] Worker %s (%s) registered", worker.id, worker.origin())
+}
+
+// refuseRemoteWorker answers a REGISTER with st and closes the connection
+func (o *Orchestrator) refuseRemoteWorker(conn net.Conn, hello *factory.Packet, st *status.Status) {
+	log.Printf("[Orchestrator] Refused remote worker %s: %v", peerName(conn), st.Message())
+
+	refusal := factory.NewPacket(hello.Id, factory.PacketType_PACKET_TYPE_REGISTER, hello.TargetIoType, nil, nil, (&factory.Error{}).FromGoError(st.Err()))
+	if err := utils.WriteMessage(conn, nil, refusal); err != nil {
+		log.Printf("[Orchestrator] Failed to refuse remote worker %s: %v", peerName(conn), err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,39 @@
+package orchestrator
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory/utils"
+)
+
+func TestRegisterRefusesOversizedHello(t *testing.T) {
+	o := NewOrchestrator()
+	conn, workerSide := net.Pipe()
+	defer workerSide.Close()
+
+	done := make(chan struct{})
+	go func() {
+		o.registerRemoteWorker(conn)
+		close(done)
+	}()
+
+	// Only the header is sent, the connection must be refused without waiting for the payload
+	header := make([]byte, utils.HeaderSize)
+	binary.BigEndian.PutUint32(header, utils.MaxHandshakeSize+1)
+	if _, err := workerSide.Write(header); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("registerRemoteWorker() waited for the payload of an oversized hello")
+	}
+	if _, err := workerSide.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("connection still open after an oversized hello: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package orchestrator
+
+import (
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// RestartPolicy controls how the orchestrator respawns workers whose process exits
+type RestartPolicy struct {
+	InitialBackoff time.Duration // Delay before the first restart
+	MaxBackoff     time.Duration // Upper bound for the exponential backoff
+	MaxRestarts    int           // Restart budget per version of a process type within Window, 0 disables restarts. Once used up the version is only started again by Replace or Promote
+	Window         time.Duration // Restarts older than this no longer count against the budget
+}
+
+// DefaultRestartPolicy returns the restart policy used by NewOrchestrator
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		MaxRestarts:    5,
+		Window:         time.Minute,
+	}
+}
+
+// restartKey names the workers sharing a restart budget, those of one version of a process type.
+// A crash-looping canary does not use up the budget of the DefaultVersion.
+type restartKey struct {
+	processType string
+	version     string
+}
+
+// restartState tracks recent restarts of one version of a process type
+type restartState struct {
+	restarts []time.Time // When each restart within the window was scheduled
+	gaveUp   bool        // The budget ran out, no more restarts until the state is cleared
+}
+
+// next decides whether a worker that exited at now may be restarted, and after which delay
+func (s *restartState) next(policy RestartPolicy, now time.Time) (time.Duration, bool) {
+	if policy.MaxRestarts <= 0 || s.gaveUp {
+		return 0, false
+	}
+
+	// Forget restarts that fell out of the window
+	recent := s.restarts[:0]
+	for _, t := range s.restarts {
+		if now.Sub(t) < policy.Window {
+			recent = append(recent, t)
+		}
+	}
+	s.restarts = recent
+
+	if len(s.restarts) >= policy.MaxRestarts {
+		s.gaveUp = true
+		return 0, false
+	}
+
+	delay := backoff(policy.InitialBackoff, policy.MaxBackoff, len(s.restarts))
+	s.restarts = append(s.restarts, now)
+	return delay, true
+}
+
+// SetRestartPolicy replaces the policy used for workers that exit from now on
+func (o *Orchestrator) SetRestartPolicy(policy RestartPolicy) {
+	o.restartsMu.Lock()
+	defer o.restartsMu.Unlock()
+	o.restartPolicy = policy
+}
+
+// handleWorkerExit is called once a worker's connection is closed. It removes the
+// worker from its pool, reaps the process and schedules a replacement.
+// Remote workers are only removed.
+func (o *Orchestrator) handleWorkerExit(worker *Worker) {
+	o.poolsMu.RLock()
+	pool, exists := o.pools[worker.processType]
+	o.poolsMu.RUnlock()
+
+	if exists {
+		pool.removeWorker(worker)
+	}
+	o.endWorkerStreams(worker)
+
+	// Remote workers were started elsewhere, whoever started them restarts them
+	if worker.remote() {
+		close(worker.exited)
+		o.retiring.Delete(worker)
+		log.Printf("[Orchestrator] Worker %s (%s) disconnected", worker.id, worker.origin())
+		return
+	}
+
+	err := worker.cmd.Wait()
+	close(worker.exited)
+	o.retiring.Delete(worker)
+	log.Printf("[Orchestrator] Worker %s (%s) exited: %v", worker.id, worker.origin(), exitReason(err))
+
+	// Workers that were asked to drain are meant to go away
+	if o.draining.Load() || worker.draining.Load() {
+		return
+	}
+	o.errors.record(RecentError{
+		Time:     time.Now(),
+		MethodID: worker.processType,
+		WorkerID: worker.id,
+		Code:     codes.Unavailable,
+		Message:  "worker exited: " + exitReason(err),
+	})
+	// A rollout whose new worker exits is rolled back instead, and workers of
+	// a binary the pool no longer starts are not replaced either, see Replace
+	if worker.onTrial.Load() || (exists && worker.binaryPath != pool.binaryOf(worker.version)) {
+		return
+	}
+
+	o.scheduleRestart(worker.processType, worker.version, worker.binaryPath)
+}
+
+// scheduleRestart respawns a worker of version for processType after an exponential backoff.
+// Once the version used up its restart budget it is given up on, see RestartPolicy.
+func (o *Orchestrator) scheduleRestart(processType string, version string, binaryPath string) {
+	o.restartsMu.Lock()
+	policy := o.restartPolicy
+	key := restartKey{processType, version}
+	state, ok := o.restarts[key]
+	if !ok {
+		state = &restartState{}
+		o.restarts[key] = state
+	}
+
+	wasGivenUp := state.gaveUp
+	delay, ok := state.next(policy, time.Now())
+	o.restartsMu.Unlock()
+
+	if !ok {
+		if policy.MaxRestarts > 0 && !wasGivenUp {
+			log.Printf("[Orchestrator] %s version %s restarted %d times within %v, it is crash-looping and is no longer restarted until it is rolled out again", processType, version, policy.MaxRestarts, policy.Window)
+		}
+		return
+	}
+
+	log.Printf("[Orchestrator] Restarting %s in %v", processType, delay)
+	time.AfterFunc(delay, func() {
+		if o.draining.Load() {
+			return
+		}
+		// The autoscaler may have replaced the worker in the meantime
+		pool, ok := o.pool(processType)
+		if ok && version == DefaultVersion && pool.atCapacity() {
+			return
+		}
+		// A rollout may have changed the binary in the meantime, or the version was retired
+		if ok {
+			if binaryPath = pool.binaryOf(version); binaryPath == "" {
+				return
+			}
+		}
+		if _, err := o.spawnWorker(processType, version, binaryPath); err != nil {
+			log.Printf("[Orchestrator] Failed to restart %s: %v", processType, err)
+			o.scheduleRestart(processType, version, binaryPath)
+		}
+	})
+}
+
+// crashLooping reports whether version of processType used up its restart budget
+func (o *Orchestrator) crashLooping(processType string, version string) bool {
+	o.restartsMu.Lock()
+	defer o.restartsMu.Unlock()
+	state, ok := o.restarts[restartKey{processType, version}]
+	return ok && state.gaveUp
+}
+
+// forgetRestarts gives version of processType a fresh restart budget
+func (o *Orchestrator) forgetRestarts(processType string, version string) {
+	o.restartsMu.Lock()
+	defer o.restartsMu.Unlock()
+	delete(o.restarts, restartKey{processType, version})
+}
+
+// backoff returns initial * 2^attempt, capped at max
+func backoff(initial time.Duration, max time.Duration, attempt int) time.Duration {
+	delay := initial
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// exitReason describes the result of cmd.Wait for logging
+func exitReason(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+	return err.Error()
+}
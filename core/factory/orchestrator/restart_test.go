@@ -0,0 +1,94 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartBudget(t *testing.T) {
+	policy := RestartPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		MaxRestarts:    3,
+		Window:         time.Minute,
+	}
+	start := time.Unix(0, 0)
+
+	tests := []struct {
+		name    string
+		exits   []time.Duration // When each worker exits, relative to start
+		delays  []time.Duration // The delay of each restart, -1 when it is refused
+		givenUp bool            // Whether the budget is used up after the last exit
+	}{
+		{
+			name:   "backoff doubles",
+			exits:  []time.Duration{0, time.Second, 2 * time.Second},
+			delays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond},
+		},
+		{
+			name:    "budget used up within the window",
+			exits:   []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second},
+			delays:  []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, -1, -1},
+			givenUp: true,
+		},
+		{
+			name:   "restarts out of the window no longer count",
+			exits:  []time.Duration{0, time.Second, 2 * time.Second, 61 * time.Second},
+			delays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:    "a used up budget is not renewed by time",
+			exits:   []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 62 * time.Second, time.Hour},
+			delays:  []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, -1, -1, -1},
+			givenUp: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &restartState{}
+			for i, exit := range tt.exits {
+				delay, ok := state.next(policy, start.Add(exit))
+				if want := tt.delays[i]; (want < 0) == ok || (ok && delay != want) {
+					t.Errorf("exit %d: next() = %v, %t, want %v", i, delay, ok, want)
+				}
+			}
+			if state.gaveUp != tt.givenUp {
+				t.Errorf("gaveUp = %t, want %t", state.gaveUp, tt.givenUp)
+			}
+		})
+	}
+}
+
+func TestRestartBudgetDisabled(t *testing.T) {
+	state := &restartState{}
+	if _, ok := state.next(RestartPolicy{Window: time.Minute}, time.Now()); ok {
+		t.Error("next() allowed a restart with a zero MaxRestarts")
+	}
+}
+
+func TestRestartBudgetPerVersion(t *testing.T) {
+	o := NewOrchestrator()
+	// Restarts are scheduled an hour out so none of them runs during the test
+	o.SetRestartPolicy(RestartPolicy{InitialBackoff: time.Hour, MaxBackoff: time.Hour, MaxRestarts: 2, Window: time.Minute})
+	pool := NewWorkerPool(nil, time.Second, 0)
+	pool.version("canary").binaryPath = "/nonexistent"
+	o.pools["test"] = pool
+
+	for i := 0; i < 3; i++ {
+		o.scheduleRestart("test", "canary", "/nonexistent")
+	}
+	if !o.crashLooping("test", "canary") {
+		t.Fatal("canary is not crash-looping after using up its budget")
+	}
+	if o.crashLooping("test", DefaultVersion) {
+		t.Fatal("the crash-looping canary used up the budget of the default version")
+	}
+
+	if err := o.RetireVersion("test", "canary"); err != nil {
+		t.Fatalf("RetireVersion() failed: %v", err)
+	}
+	if o.crashLooping("test", "canary") {
+		t.Error("the retired canary kept its used up budget")
+	}
+}
@@ -0,0 +1,114 @@
+package orchestrator
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy decides which failed attempts of a method are tried again.
+// It is modeled on the retryPolicy of the gRPC service config.
+type RetryPolicy struct {
+	MaxAttempts       int           // Attempts including the first one, 0 takes PoolOptions.Retries + 1
+	InitialBackoff    time.Duration // Upper bound of the delay before the first retry
+	MaxBackoff        time.Duration // Cap on the backoff as it grows
+	BackoffMultiplier float64       // Growth of the backoff after every retry
+	RetryableCodes    []codes.Code  // Statuses that are retried, an attempt timing out counts as DEADLINE_EXCEEDED
+	Deadline          time.Duration // Budget for all attempts together, 0 leaves it to the caller's deadline
+	NonIdempotent     bool          // Never retry once the request may have reached a worker
+}
+
+// DefaultRetryPolicy returns the retry policy pools start out with
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialBackoff:    50 * time.Millisecond,
+		MaxBackoff:        time.Second,
+		BackoffMultiplier: 2,
+		RetryableCodes:    []codes.Code{codes.Unavailable, codes.DeadlineExceeded},
+	}
+}
+
+// attemptResult is the outcome of sending a request to one worker
+type attemptResult struct {
+	response  *factory.Packet // set once a worker answered, possibly with an error status
+	status    *status.Status  // why the attempt failed, nil on success
+	delivered bool            // the request may have reached a worker
+}
+
+// failed returns the result of an attempt that got no usable answer
+func failed(code codes.Code, delivered bool, format string, args ...any) attemptResult {
+	return attemptResult{status: status.Newf(code, format, args...), delivered: delivered}
+}
+
+// answered returns the result of an attempt that a worker responded to
+func answered(response *factory.Packet) attemptResult {
+	result := attemptResult{response: response, delivered: true}
+	if err := response.Error.ToGoError(); err != nil {
+		result.status = status.Convert(err)
+		// A worker that turned the request away never ran it
+		result.delivered = !factory.NotProcessed(result.status)
+	}
+	return result
+}
+
+// shouldRetry reports whether the policy allows another attempt after result
+func (p RetryPolicy) shouldRetry(result attemptResult, attempt int) bool {
+	if result.status == nil || attempt+1 >= p.MaxAttempts {
+		return false
+	}
+
+	// Requests that never left the orchestrator or were turned away are always safe to try again
+	if result.turnedAway() {
+		return true
+	}
+	if result.delivered && p.NonIdempotent {
+		return false
+	}
+	return slices.Contains(p.RetryableCodes, result.status.Code())
+}
+
+// backoff returns the delay before retry number n (starting at 0), picked at random
+// between 0 and the exponentially growing cap so that retries do not synchronize
+func (p RetryPolicy) backoff(n int) time.Duration {
+	limit := float64(p.InitialBackoff)
+	for i := 0; i < n && limit < float64(p.MaxBackoff); i++ {
+		limit *= p.BackoffMultiplier
+	}
+	limit = min(limit, float64(p.MaxBackoff))
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(limit) + 1))
+}
+
+// sleep waits for d or until ctx is done, whichever comes first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// turnedAway reports whether the request of the attempt never ran: it never left the
+// orchestrator, or the worker turned it away because it was draining or at its limit.
+// Another worker may well take it.
+func (r attemptResult) turnedAway() bool {
+	if r.delivered {
+		return false
+	}
+	if r.response == nil {
+		return true
+	}
+	code := r.status.Code()
+	return code == codes.Unavailable || code == codes.ResourceExhausted
+}
@@ -0,0 +1,134 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// notProcessedPacket returns a response of a worker that turned the request away
+func notProcessedPacket(code codes.Code) *factory.Packet {
+	st := factory.MarkNotProcessed(status.New(code, "draining"))
+	return &factory.Packet{Error: factory.NewError(st.Proto())}
+}
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		result        attemptResult
+		attempt       int
+		nonIdempotent bool
+		want          bool
+	}{
+		{
+			name:   "success",
+			result: answered(&factory.Packet{Payload: []byte("ok")}),
+			want:   false,
+		},
+		{
+			name:   "retryable status from a worker",
+			result: answered(errorPacket(codes.Unavailable, "overloaded")),
+			want:   true,
+		},
+		{
+			name:   "attempt timed out",
+			result: failed(codes.DeadlineExceeded, true, "no answer"),
+			want:   true,
+		},
+		{
+			name:   "status that is not retryable",
+			result: answered(errorPacket(codes.NotFound, "no book")),
+			want:   false,
+		},
+		{
+			name:          "non idempotent request that reached a worker",
+			result:        answered(errorPacket(codes.Unavailable, "overloaded")),
+			nonIdempotent: true,
+			want:          false,
+		},
+		{
+			name:          "non idempotent request that never left the orchestrator",
+			result:        failed(codes.Internal, false, "write failed"),
+			nonIdempotent: true,
+			want:          true,
+		},
+		{
+			name:          "non idempotent request turned away by a draining worker",
+			result:        answered(notProcessedPacket(codes.Unavailable)),
+			nonIdempotent: true,
+			want:          true,
+		},
+		{
+			name:          "non idempotent request turned away by a worker at its limit",
+			result:        answered(notProcessedPacket(codes.ResourceExhausted)),
+			nonIdempotent: true,
+			want:          true,
+		},
+		{
+			name:   "not processed with a status that is not retryable",
+			result: answered(notProcessedPacket(codes.Internal)),
+			want:   false,
+		},
+		{
+			name:    "last attempt",
+			result:  failed(codes.Unavailable, false, "no workers"),
+			attempt: 2,
+			want:    false,
+		},
+		{
+			name:    "attempt before the last",
+			result:  answered(errorPacket(codes.Unavailable, "overloaded")),
+			attempt: 1,
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultRetryPolicy()
+			policy.MaxAttempts = 3
+			policy.NonIdempotent = tt.nonIdempotent
+
+			if got := policy.shouldRetry(tt.result, tt.attempt); got != tt.want {
+				t.Errorf("shouldRetry(attempt %d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := DefaultRetryPolicy()
+
+	// The cap doubles from InitialBackoff on every retry until it reaches MaxBackoff
+	limits := []time.Duration{
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	const samples = 1000
+	for n, limit := range limits {
+		var longest time.Duration
+		for i := 0; i < samples; i++ {
+			delay := policy.backoff(n)
+			if delay < 0 || delay > limit {
+				t.Fatalf("backoff(%d) = %v, want between 0 and %v", n, delay, limit)
+			}
+			longest = max(longest, delay)
+		}
+		// The jitter spreads the delays over the whole range
+		if longest < limit/2 {
+			t.Errorf("longest of %d backoff(%d) = %v, want close to %v", samples, n, longest, limit)
+		}
+	}
+
+	if delay := (RetryPolicy{}).backoff(3); delay != 0 {
+		t.Errorf("backoff() without an InitialBackoff = %v, want 0", delay)
+	}
+}
@@ -0,0 +1,199 @@
+package orchestrator
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// rolloutPollInterval is how often a rollout checks on the workers it started
+const rolloutPollInterval = 50 * time.Millisecond
+
+// RolloutPolicy controls how Replace moves a pool to a new binary
+type RolloutPolicy struct {
+	StartTimeout time.Duration // How long new workers have to answer their first health check
+	Observe      time.Duration // How long new workers must stay healthy before the old ones are drained
+}
+
+// DefaultRolloutPolicy returns the rollout policy used by NewOrchestrator
+func DefaultRolloutPolicy() RolloutPolicy {
+	return RolloutPolicy{
+		StartTimeout: 10 * time.Second,
+		Observe:      10 * time.Second,
+	}
+}
+
+// SetRolloutPolicy replaces the policy used by rollouts started from now on
+func (o *Orchestrator) SetRolloutPolicy(policy RolloutPolicy) {
+	o.restartsMu.Lock()
+	defer o.restartsMu.Unlock()
+	o.rolloutPolicy = policy
+}
+
+// Replace moves the local workers of the pool serving processType to binaryPath without downtime,
+// see RolloutPolicy. It blocks until the rollout is done and returns an error if it was rolled back.
+func (o *Orchestrator) Replace(processType string, binaryPath string) error {
+	pool, ok := o.pool(processType)
+	if !ok {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+	if !pool.rolling.CompareAndSwap(false, true) {
+		return fmt.Errorf("a rollout of %s is already in progress", processType)
+	}
+	defer pool.rolling.Store(false)
+
+	o.restartsMu.Lock()
+	policy := o.rolloutPolicy
+	o.restartsMu.Unlock()
+
+	old := pool.localWorkers(DefaultVersion)
+	previous := pool.binary()
+
+	// 1. Start the new workers, nothing is sent to them yet
+	count := max(1, len(old))
+	log.Printf("[Orchestrator] Rolling out %s: starting %d workers from %s", processType, count, binaryPath)
+
+	fresh := make([]*Worker, 0, count)
+	for i := 0; i < count; i++ {
+		worker, err := o.startWorker(processType, DefaultVersion, binaryPath)
+		if err != nil {
+			abandonWorkers(fresh)
+			return fmt.Errorf("rollout of %s failed to start a worker: %w", processType, err)
+		}
+		worker.onTrial.Store(true)
+		o.runWorker(worker)
+		worker.ping()
+		fresh = append(fresh, worker)
+	}
+
+	if err := awaitHealthy(fresh, policy.StartTimeout); err != nil {
+		abandonWorkers(fresh)
+		log.Printf("[Orchestrator] Rolled back %s: %v", processType, err)
+		return fmt.Errorf("rolled back %s: %w", processType, err)
+	}
+
+	// 2. Shift the traffic, the old workers keep running in case the new ones fail
+	pool.setBinary(binaryPath)
+	for _, worker := range fresh {
+		pool.addWorker(worker)
+	}
+	for _, worker := range old {
+		if pool.removeWorker(worker) {
+			o.retiring.Store(worker, struct{}{})
+		}
+	}
+	log.Printf("[Orchestrator] Rolling out %s: %d new workers are taking traffic", processType, count)
+
+	// 3. Keep the new workers only if they stay healthy
+	if err := observeHealthy(fresh, policy.Observe); err != nil {
+		pool.setBinary(previous)
+		for _, worker := range old {
+			if alive(worker) {
+				o.retiring.Delete(worker)
+				pool.addWorker(worker)
+			}
+		}
+		for _, worker := range fresh {
+			o.retireWorker(pool, worker)
+		}
+		log.Printf("[Orchestrator] Rolled back %s: %v", processType, err)
+		return fmt.Errorf("rolled back %s: %w", processType, err)
+	}
+
+	for _, worker := range fresh {
+		worker.onTrial.Store(false)
+	}
+	for _, worker := range old {
+		worker.drain()
+	}
+	// The new build starts with a fresh restart budget
+	o.forgetRestarts(processType, DefaultVersion)
+
+	log.Printf("[Orchestrator] Rolled out %s, draining %d old workers", processType, len(old))
+	return nil
+}
+
+// ReplaceAll rolls every pool out to a new build of the binary it was started from,
+// e.g. after the binaries were replaced on disk. Pools are rolled out one at a time,
+// pools served by remote workers only are skipped. Crash-looping pools are started again.
+func (o *Orchestrator) ReplaceAll() error {
+	o.poolsMu.RLock()
+	pools := make(map[string]*WorkerPool, len(o.pools))
+	for processType, pool := range o.pools {
+		pools[processType] = pool
+	}
+	o.poolsMu.RUnlock()
+
+	var failed []string
+	for processType, pool := range pools {
+		if len(pool.localWorkers(DefaultVersion)) == 0 && !o.crashLooping(processType, DefaultVersion) {
+			continue
+		}
+		if err := o.Replace(processType, pool.binary()); err != nil {
+			failed = append(failed, processType)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("rolled back %d pools: %v", len(failed), failed)
+	}
+	return nil
+}
+
+// awaitHealthy waits until every worker has answered a health check
+func awaitHealthy(workers []*Worker, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ready := 0
+		for _, worker := range workers {
+			if !alive(worker) {
+				return fmt.Errorf("new worker %s exited", worker.id)
+			}
+			if worker.Status() != nil {
+				ready++
+			}
+		}
+		if ready == len(workers) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d of %d new workers did not answer a health check within %v", len(workers)-ready, len(workers), timeout)
+		}
+		time.Sleep(rolloutPollInterval)
+	}
+}
+
+// observeHealthy fails as soon as one of workers exits or misses its health checks within period
+func observeHealthy(workers []*Worker, period time.Duration) error {
+	deadline := time.Now().Add(period)
+	for time.Now().Before(deadline) {
+		for _, worker := range workers {
+			if !alive(worker) {
+				return fmt.Errorf("new worker %s exited", worker.id)
+			}
+			if !worker.IsHealthy() {
+				return fmt.Errorf("new worker %s failed its health checks", worker.id)
+			}
+		}
+		time.Sleep(rolloutPollInterval)
+	}
+	return nil
+}
+
+// abandonWorkers kills workers of a failed rollout that never took traffic
+func abandonWorkers(workers []*Worker) {
+	for _, worker := range workers {
+		if alive(worker) {
+			worker.kill()
+		}
+	}
+}
+
+// alive reports whether the connection to worker is still open
+func alive(worker *Worker) bool {
+	select {
+	case <-worker.done:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,93 @@
+package orchestrator
+
+import (
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Route maps an externally visible RPC (e.g. "/example.BookService/GetBook")
+// onto the pool of workers that serves it.
+type Route struct {
+	Service  string // Fully qualified proto service name e.g. "example.BookService"
+	Method   string // e.g. "GetBook"
+	MethodID string // The TargetIoType of the pool serving this method
+	// ClientStreams and ServerStreams mirror the stream keywords of the rpc
+	ClientStreams bool
+	ServerStreams bool
+	newRequest    func() proto.Message
+	newResponse   func() proto.Message
+}
+
+// NewRoute creates a Route for a unary method. serviceName is the service name as it appears
+// in the service file (e.g. "BookService"), its proto package is taken from the service
+// descriptor registered with the generated code, see serviceFullName.
+func NewRoute[RequestType proto.Message, ResponseType proto.Message](serviceName string, methodName string, methodID string) *Route {
+	request := newMessage[RequestType]().ProtoReflect().Descriptor()
+	response := newMessage[ResponseType]().ProtoReflect().Descriptor()
+
+	return &Route{
+		Service:     serviceFullName(serviceName, methodName, request, response),
+		Method:      methodName,
+		MethodID:    methodID,
+		newRequest:  func() proto.Message { return newMessage[RequestType]() },
+		newResponse: func() proto.Message { return newMessage[ResponseType]() },
+	}
+}
+
+// NewStreamRoute creates a Route for a streaming method
+func NewStreamRoute[RequestType proto.Message, ResponseType proto.Message](serviceName string, methodName string, methodID string, clientStreams bool, serverStreams bool) *Route {
+	route := NewRoute[RequestType, ResponseType](serviceName, methodName, methodID)
+	route.ClientStreams = clientStreams
+	route.ServerStreams = serverStreams
+	return route
+}
+
+// IsStream reports whether the route is a streaming method
+func (r *Route) IsStream() bool {
+	return r.ClientStreams || r.ServerStreams
+}
+
+// FullMethod returns the gRPC style name of the route e.g. "/example.BookService/GetBook"
+func (r *Route) FullMethod() string {
+	return "/" + r.Service + "/" + r.Method
+}
+
+// serviceFullName returns the fully qualified name of the registered service that declares
+// methodName with these request and response types. The request and response may come from
+// any package (e.g. google.protobuf.Empty), so only the service says which package it is in.
+// A service that is not registered falls back to the package of the request type.
+func serviceFullName(serviceName string, methodName string, request protoreflect.MessageDescriptor, response protoreflect.MessageDescriptor) string {
+	if strings.Contains(serviceName, ".") {
+		return serviceName
+	}
+
+	var found []string
+	protoregistry.GlobalFiles.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		service := file.Services().ByName(protoreflect.Name(serviceName))
+		if service == nil {
+			return true
+		}
+		method := service.Methods().ByName(protoreflect.Name(methodName))
+		if method != nil && method.Input().FullName() == request.FullName() && method.Output().FullName() == response.FullName() {
+			found = append(found, string(service.FullName()))
+		}
+		return true
+	})
+	if len(found) == 1 {
+		return found[0]
+	}
+
+	if pkg := request.ParentFile().Package(); pkg != "" {
+		return string(pkg) + "." + serviceName
+	}
+	return serviceName
+}
+
+// newMessage allocates a new instance of the message type that Type points to
+func newMessage[Type proto.Message]() Type {
+	return reflect.New(reflect.TypeOf(*new(Type)).Elem()).Interface().(Type)
+}
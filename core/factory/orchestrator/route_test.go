@@ -0,0 +1,80 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/bsmider/pipes/core/example/build/example"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// registerHealthService registers a service whose messages all come from another package
+func registerHealthService(t *testing.T) {
+	t.Helper()
+	if _, err := protoregistry.GlobalFiles.FindFileByPath("routetest/health.proto"); err == nil {
+		return
+	}
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("routetest/health.proto"),
+		Package:    proto.String("routetest"),
+		Dependency: []string{"google/protobuf/empty.proto"},
+		Syntax:     proto.String("proto3"),
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("HealthService"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Check"),
+				InputType:  proto.String(".google.protobuf.Empty"),
+				OutputType: proto.String(".google.protobuf.Empty"),
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("Failed to build the test service: %v", err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		t.Fatalf("Failed to register the test service: %v", err)
+	}
+}
+
+func TestNewRouteServiceName(t *testing.T) {
+	registerHealthService(t)
+
+	tests := []struct {
+		name  string
+		route *Route
+		want  string
+	}{
+		{
+			name:  "messages from the service package",
+			route: NewRoute[*example.GetBookRequest, *example.GetBookResponse]("BookService", "GetBook", "id"),
+			want:  "/example.BookService/GetBook",
+		},
+		{
+			name:  "messages from another package",
+			route: NewRoute[*emptypb.Empty, *emptypb.Empty]("HealthService", "Check", "id"),
+			want:  "/routetest.HealthService/Check",
+		},
+		{
+			name:  "fully qualified service",
+			route: NewRoute[*emptypb.Empty, *emptypb.Empty]("other.HealthService", "Check", "id"),
+			want:  "/other.HealthService/Check",
+		},
+		{
+			name:  "unregistered service",
+			route: NewRoute[*example.GetBookRequest, *example.GetBookResponse]("MissingService", "GetBook", "id"),
+			want:  "/example.MissingService/GetBook",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.route.FullMethod(); got != tt.want {
+				t.Errorf("FullMethod() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+)
+
+// drainPollInterval is how often Shutdown checks whether in-flight requests have finished
+const drainPollInterval = 10 * time.Millisecond
+
+// Shutdown gracefully stops the orchestrator:
+//  1. new ingress requests are refused and the ingress servers stop
+//  2. in-flight requests are given until ctx is done to finish
+//  3. every worker is asked to drain, and is reaped once it exits
+//
+// Workers still running when ctx is done are killed and ctx.Err() is returned.
+func (o *Orchestrator) Shutdown(ctx context.Context) error {
+	if o.draining.Swap(true) {
+		return nil
+	}
+	close(o.stopping)
+	log.Printf("[Orchestrator] Shutting down, %d requests in flight", o.inFlight.Load())
+
+	// 1. Stop the ingress servers
+	o.stopIngress(ctx)
+
+	// 2. Wait for in-flight requests
+	if err := o.waitForInFlight(ctx); err != nil {
+		log.Printf("[Orchestrator] Gave up waiting for %d in-flight requests", o.inFlight.Load())
+	}
+
+	// 3. Drain and reap the workers
+	workers := o.allWorkers()
+	for _, worker := range workers {
+		worker.drain()
+	}
+
+	var err error
+	for _, worker := range workers {
+		select {
+		case <-worker.exited:
+		case <-ctx.Done():
+			log.Printf("[Orchestrator] Worker %s did not exit in time, killing it", worker.id)
+			worker.kill()
+			<-worker.exited
+			err = ctx.Err()
+		}
+	}
+
+	o.stopAdmin()
+	log.Printf("[Orchestrator] Shutdown complete")
+	return err
+}
+
+// waitForInFlight blocks until no requests are in flight or ctx is done
+func (o *Orchestrator) waitForInFlight(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for o.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// stopIngress stops the gRPC and HTTP ingress servers, waiting for their
+// in-flight calls until ctx is done.
+func (o *Orchestrator) stopIngress(ctx context.Context) {
+	o.routesMu.RLock()
+	grpcServer, httpServer := o.grpcServer, o.httpServer
+	o.routesMu.RUnlock()
+
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			httpServer.Close()
+		}
+	}
+
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			grpcServer.Stop()
+		}
+	}
+}
+
+// allWorkers returns a snapshot of the workers in every pool,
+// including retired workers that are still draining
+func (o *Orchestrator) allWorkers() []*Worker {
+	o.poolsMu.RLock()
+	defer o.poolsMu.RUnlock()
+
+	var workers []*Worker
+	for _, pool := range o.pools {
+		pool.mu.RLock()
+		workers = append(workers, pool.workers...)
+		pool.mu.RUnlock()
+	}
+	o.retiring.Range(func(key, _ any) bool {
+		workers = append(workers, key.(*Worker))
+		return true
+	})
+	return workers
+}
+
+// drain asks the worker to finish its in-flight requests and exit.
+// A drained worker is not restarted.
+func (w *Worker) drain() {
+	if w.draining.Swap(true) {
+		return
+	}
+
+	packet := factory.NewPacket(factory.GeneratePacketId(), factory.PacketType_PACKET_TYPE_DRAIN, w.processType, nil, nil, nil)
+	if err := w.sendPacket(packet); err != nil {
+		log.Printf("[Orchestrator] Failed to drain worker %s: %v", w.id, err)
+	}
+}
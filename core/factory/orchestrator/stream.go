@@ -0,0 +1,294 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// packetSink is anything the orchestrator can hand packets to
+type packetSink interface {
+	sendPacket(packet *factory.Packet) error
+}
+
+// streamSession links the end that opened a stream with the worker serving it
+type streamSession struct {
+	caller packetSink // a worker, or a localStream for ingress streams
+	callee *Worker
+}
+
+// openingStream is a stream opened by a worker while its callee is being acquired.
+// The caller's packets for it are held until the callee has the STREAM_OPEN.
+type openingStream struct {
+	caller   *Worker
+	mu       sync.Mutex
+	buffered []*factory.Packet
+	done     bool               // Set once the stream is open, failed or was abandoned
+	cancel   context.CancelFunc // Stops acquiring the callee
+}
+
+// localStream lets the orchestrator itself be the caller of a stream
+type localStream struct {
+	stream *factory.PacketStream
+}
+
+func (l *localStream) sendPacket(packet *factory.Packet) error {
+	l.stream.Deliver(packet)
+	return nil
+}
+
+// OpenStream opens a stream to a worker of methodID on behalf of the orchestrator.
+// payload is the request of a server-streaming call and nil otherwise.
+// The stream is cancelled on the worker when ctx is done.
+func (o *Orchestrator) OpenStream(ctx context.Context, methodID string, requestContext *factory.Context, payload []byte) (*factory.PacketStream, error) {
+	if o.draining.Load() {
+		return nil, status.Error(codes.Unavailable, "orchestrator is shutting down")
+	}
+
+	openPacket := factory.NewPacket(factory.GeneratePacketId(), factory.PacketType_PACKET_TYPE_STREAM_OPEN, methodID, requestContext, payload, nil)
+	callee, err := o.selectStreamWorker(ctx, methodID, requestContext)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := factory.NewPacketStream(openPacket.Id, methodID, requestContext, callee.sendPacket)
+	if err := o.startSession(openPacket, &localStream{stream: stream}, callee); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			if o.endSession(openPacket.Id) {
+				callee.cancel(openPacket)
+			}
+			stream.Abort(status.FromContextError(ctx.Err()).Err())
+		case <-stream.Finished():
+		}
+	}()
+
+	if payload != nil {
+		// A server-streaming call sends nothing after its request
+		stream.CloseSend(nil)
+	}
+
+	return stream, nil
+}
+
+// openInternalStream handles a STREAM_OPEN sent by a worker. The callee is acquired in the
+// background, acquiring may wait for a free slot and the requester's mailbox must keep flowing.
+func (o *Orchestrator) openInternalStream(requester *Worker, openPacket *factory.Packet) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opening := &openingStream{caller: requester, cancel: cancel}
+	o.openingStreams.Store(openPacket.Id, opening)
+
+	go func() {
+		defer cancel()
+		callee, err := o.selectStreamWorker(ctx, openPacket.TargetIoType, openPacket.Context)
+
+		opening.mu.Lock()
+		o.openingStreams.Delete(openPacket.Id)
+		if opening.done {
+			// The requester cancelled the stream or went away meanwhile
+			opening.mu.Unlock()
+			if err == nil {
+				o.releaseStreamWorker(callee)
+			}
+			return
+		}
+		opening.done = true
+
+		// Held under the lock so packets routed meanwhile queue up behind the buffered ones
+		if err == nil {
+			err = o.startSession(openPacket, requester, callee)
+		}
+		if err == nil {
+			for _, packet := range opening.buffered {
+				if err := callee.sendPacket(packet); err != nil {
+					log.Printf("[Orchestrator] Failed to forward stream packet %s: %v", packet.Id, err)
+				}
+			}
+		}
+		opening.buffered = nil
+		opening.mu.Unlock()
+
+		if err != nil {
+			o.failInternalStream(requester, openPacket, err)
+		}
+	}()
+}
+
+// failInternalStream ends a stream a worker opened with the error it could not be opened with
+func (o *Orchestrator) failInternalStream(requester *Worker, openPacket *factory.Packet, err error) {
+	end := factory.NewPacket(openPacket.Id, factory.PacketType_PACKET_TYPE_STREAM_END, openPacket.TargetIoType, openPacket.Context, nil, (&factory.Error{}).FromGoError(err))
+	if err := requester.sendPacket(end); err != nil {
+		log.Printf("[Orchestrator] Failed to end stream %s on %s: %v", openPacket.Id, requester.id, err)
+	}
+}
+
+// selectStreamWorker picks the worker a new stream is opened on and counts the stream against it.
+// Streams are not retried once open, so there is a single attempt.
+func (o *Orchestrator) selectStreamWorker(ctx context.Context, methodID string, requestContext *factory.Context) (*Worker, error) {
+	pool, exists := o.pool(methodID)
+	if !exists {
+		return nil, status.Errorf(codes.Unavailable, "no workers available for target type: %s", methodID)
+	}
+
+	timeout, _, _ := pool.limits()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	worker, err := pool.acquire(ctx, nil, requestContext.GetPriority(), pool.pickVersion(requestContext.GetVersion()))
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "pool %s had no free slot within %v", methodID, timeout)
+	}
+	if worker == nil {
+		return nil, status.Errorf(codes.Unavailable, "pool %s has no active workers", methodID)
+	}
+	return worker, nil
+}
+
+// bufferOpeningStream holds a caller's packet for a stream that is still waiting for its callee.
+// It reports false once the stream is no longer opening, the packet is then routed as usual.
+func (o *Orchestrator) bufferOpeningStream(packet *factory.Packet) bool {
+	value, ok := o.openingStreams.Load(packet.Id)
+	if !ok {
+		return false
+	}
+	opening := value.(*openingStream)
+
+	opening.mu.Lock()
+	defer opening.mu.Unlock()
+	if opening.done {
+		return false
+	}
+	opening.buffered = append(opening.buffered, packet)
+	return true
+}
+
+// abandonOpeningStream stops opening a stream whose caller gave up, it reports whether the stream was opening
+func (o *Orchestrator) abandonOpeningStream(streamID string) bool {
+	value, ok := o.openingStreams.LoadAndDelete(streamID)
+	if !ok {
+		return false
+	}
+	opening := value.(*openingStream)
+
+	opening.mu.Lock()
+	defer opening.mu.Unlock()
+	if opening.done {
+		return false
+	}
+	opening.done = true
+	opening.buffered = nil
+	opening.cancel()
+	return true
+}
+
+// releaseStreamWorker gives back the slot a stream was counted against on its callee
+func (o *Orchestrator) releaseStreamWorker(callee *Worker) {
+	if pool, ok := o.pool(callee.processType); ok {
+		pool.release(callee)
+	}
+}
+
+// startSession registers the session and forwards the STREAM_OPEN to the callee
+func (o *Orchestrator) startSession(openPacket *factory.Packet, caller packetSink, callee *Worker) error {
+	o.streams.Store(openPacket.Id, &streamSession{caller: caller, callee: callee})
+	o.inFlight.Add(1)
+
+	if err := callee.sendPacket(openPacket); err != nil {
+		o.endSession(openPacket.Id)
+		return status.Errorf(codes.Unavailable, "worker %s send error: %v", callee.id, err)
+	}
+	return nil
+}
+
+// endSession forgets a stream, it reports whether the stream was still open
+func (o *Orchestrator) endSession(streamID string) bool {
+	if session, ok := o.streams.LoadAndDelete(streamID); ok {
+		o.inFlight.Add(-1)
+		o.releaseStreamWorker(session.(*streamSession).callee)
+		return true
+	}
+	return false
+}
+
+// routeStreamPacket forwards a STREAM_DATA, STREAM_END or STREAM_WINDOW packet
+// sent by from to the other end of its stream
+func (o *Orchestrator) routeStreamPacket(from *Worker, packet *factory.Packet) {
+	if o.bufferOpeningStream(packet) {
+		return
+	}
+
+	value, ok := o.streams.Load(packet.Id)
+	if !ok {
+		log.Printf("[Orchestrator] Dropping packet for unknown stream %s", packet.Id)
+		return
+	}
+	session := value.(*streamSession)
+
+	var to packetSink = session.callee
+	if from == session.callee {
+		to = session.caller
+
+		// The callee's STREAM_END carries the final status, the call is over
+		if packet.Type == factory.PacketType_PACKET_TYPE_STREAM_END {
+			o.endSession(packet.Id)
+		}
+	}
+
+	if err := to.sendPacket(packet); err != nil {
+		log.Printf("[Orchestrator] Failed to forward stream packet %s: %v", packet.Id, err)
+	}
+}
+
+// cancelStream forwards a caller's CANCEL to the worker serving the stream.
+// It reports whether packetID named an open stream.
+func (o *Orchestrator) cancelStream(packetID string) bool {
+	if o.abandonOpeningStream(packetID) {
+		return true
+	}
+
+	value, ok := o.streams.Load(packetID)
+	if !ok || !o.endSession(packetID) {
+		return false
+	}
+
+	session := value.(*streamSession)
+	session.callee.cancel(factory.NewPacket(packetID, factory.PacketType_PACKET_TYPE_STREAM_OPEN, session.callee.processType, nil, nil, nil))
+	return true
+}
+
+// endWorkerStreams closes every stream a worker took part in after it went away
+func (o *Orchestrator) endWorkerStreams(worker *Worker) {
+	o.openingStreams.Range(func(key, value any) bool {
+		if value.(*openingStream).caller == worker {
+			o.abandonOpeningStream(key.(string))
+		}
+		return true
+	})
+
+	o.streams.Range(func(key, value any) bool {
+		streamID := key.(string)
+		session := value.(*streamSession)
+
+		switch {
+		case session.callee == worker:
+			if o.endSession(streamID) {
+				gone := status.Newf(codes.Unavailable, "worker %s exited", worker.id)
+				end := factory.NewPacket(streamID, factory.PacketType_PACKET_TYPE_STREAM_END, worker.processType, nil, nil, factory.NewError(gone.Proto()))
+				session.caller.sendPacket(end)
+			}
+		case session.caller == packetSink(worker):
+			if o.endSession(streamID) {
+				session.callee.cancel(factory.NewPacket(streamID, factory.PacketType_PACKET_TYPE_STREAM_OPEN, session.callee.processType, nil, nil, nil))
+			}
+		}
+		return true
+	})
+}
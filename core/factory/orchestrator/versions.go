@@ -0,0 +1,282 @@
+package orchestrator
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultVersion is the version of the workers started by Spawn, autoscaling and Replace
+const DefaultVersion = "default"
+
+// defaultVersionWeight is the weight of DefaultVersion until it is changed
+const defaultVersionWeight = 100
+
+// poolVersion is one build of a pool's method, running side by side with the others
+type poolVersion struct {
+	binaryPath string // unused for DefaultVersion, whose binary is the pool's
+	weight     int    // share of the traffic relative to the other versions' weights
+
+	requests atomic.Uint64 // attempts answered or timed out
+	failures atomic.Uint64 // attempts answered with an error or timed out
+
+	latencyMu sync.Mutex
+	latency   time.Duration // moving average of the attempts' latency
+}
+
+// VersionStats reports how one version of a method is doing, to decide whether to promote it
+type VersionStats struct {
+	Version   string
+	Weight    int
+	Workers   int
+	Requests  uint64
+	Failures  uint64
+	ErrorRate float64
+	Latency   time.Duration
+}
+
+// SpawnVersion starts count workers of binaryPath as version of the pool serving processType.
+// The version gets weight out of the weights of all versions of the pool, 0 only serves
+// requests pinned to it with the VersionHeader. Workers of a version are restarted from
+// its own binary but the pool only autoscales the DefaultVersion.
+func (o *Orchestrator) SpawnVersion(processType string, version string, binaryPath string, count int, weight int) error {
+	if version == "" || version == DefaultVersion {
+		return fmt.Errorf("version must be named and differ from %q", DefaultVersion)
+	}
+	if weight < 0 {
+		return fmt.Errorf("invalid weight %d for version %s", weight, version)
+	}
+	pool, ok := o.pool(processType)
+	if !ok {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.mu.Lock()
+	pool.version(DefaultVersion)
+	v := pool.version(version)
+	v.binaryPath = binaryPath
+	v.weight = weight
+	pool.mu.Unlock()
+
+	for i := 0; i < count; i++ {
+		if _, err := o.spawnWorker(processType, version, binaryPath); err != nil {
+			return err
+		}
+	}
+	log.Printf("[Orchestrator] Started %d workers of %s version %s with weight %d", count, processType, version, weight)
+	return nil
+}
+
+// SetVersionWeights changes the share of the traffic of the given versions of the pool
+// serving processType, versions that are not listed keep their weight
+func (o *Orchestrator) SetVersionWeights(processType string, weights map[string]int) error {
+	pool, ok := o.pool(processType)
+	if !ok {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	for version, weight := range weights {
+		if _, ok := pool.versions[version]; !ok && version != DefaultVersion {
+			return fmt.Errorf("no version %s of %s", version, processType)
+		}
+		if weight < 0 {
+			return fmt.Errorf("invalid weight %d for version %s", weight, version)
+		}
+	}
+	for version, weight := range weights {
+		pool.version(version).weight = weight
+	}
+	log.Printf("[Orchestrator] Version weights of %s are now %v", processType, weights)
+	return nil
+}
+
+// RetireVersion drains the workers of version and forgets it, its traffic goes to the other versions
+func (o *Orchestrator) RetireVersion(processType string, version string) error {
+	if version == DefaultVersion {
+		return fmt.Errorf("the %s version cannot be retired, replace its binary instead", DefaultVersion)
+	}
+	pool, ok := o.pool(processType)
+	if !ok {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.mu.Lock()
+	_, ok = pool.versions[version]
+	delete(pool.versions, version)
+	pool.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no version %s of %s", version, processType)
+	}
+
+	o.forgetRestarts(processType, version)
+	workers := pool.localWorkers(version)
+	for _, worker := range workers {
+		o.retireWorker(pool, worker)
+	}
+	log.Printf("[Orchestrator] Retired %s version %s, draining %d workers", processType, version, len(workers))
+	return nil
+}
+
+// Promote makes version the DefaultVersion of the pool serving processType: the default
+// workers are replaced by workers of the version's binary, see Replace, and the version's
+// own workers are retired. The version is kept if the rollout is rolled back.
+func (o *Orchestrator) Promote(processType string, version string) error {
+	pool, ok := o.pool(processType)
+	if !ok {
+		return fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.mu.RLock()
+	v, ok := pool.versions[version]
+	pool.mu.RUnlock()
+	if !ok || version == DefaultVersion {
+		return fmt.Errorf("no version %s of %s", version, processType)
+	}
+
+	if err := o.Replace(processType, v.binaryPath); err != nil {
+		return err
+	}
+	return o.RetireVersion(processType, version)
+}
+
+// VersionStats reports the traffic and workers of each version of the pool serving processType
+func (o *Orchestrator) VersionStats(processType string) ([]VersionStats, error) {
+	pool, ok := o.pool(processType)
+	if !ok {
+		return nil, fmt.Errorf("no pool for target type: %s", processType)
+	}
+
+	pool.mu.Lock()
+	pool.version(DefaultVersion)
+	workers := make(map[string]int)
+	for _, worker := range pool.workers {
+		workers[worker.version]++
+	}
+	stats := make([]VersionStats, 0, len(pool.versions))
+	for name, v := range pool.versions {
+		s := VersionStats{
+			Version:  name,
+			Weight:   v.weight,
+			Workers:  workers[name],
+			Requests: v.requests.Load(),
+			Failures: v.failures.Load(),
+		}
+		if s.Requests > 0 {
+			s.ErrorRate = float64(s.Failures) / float64(s.Requests)
+		}
+		v.latencyMu.Lock()
+		s.Latency = v.latency
+		v.latencyMu.Unlock()
+		stats = append(stats, s)
+	}
+	pool.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Version < stats[j].Version })
+	return stats, nil
+}
+
+// version returns the version of the pool with the given name, creating it if needed.
+// p.mu must be held for writing.
+func (p *WorkerPool) version(name string) *poolVersion {
+	if p.versions == nil {
+		p.versions = make(map[string]*poolVersion)
+	}
+	v, ok := p.versions[name]
+	if !ok {
+		v = &poolVersion{}
+		if name == DefaultVersion {
+			v.weight = defaultVersionWeight
+		}
+		p.versions[name] = v
+	}
+	return v
+}
+
+// binaryOf returns the binary the workers of version are started from, "" for an unknown version
+func (p *WorkerPool) binaryOf(version string) string {
+	if version == DefaultVersion {
+		return p.binary()
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if v, ok := p.versions[version]; ok {
+		return v.binaryPath
+	}
+	return ""
+}
+
+// pickVersion chooses the version an attempt is sent to. A request pinned to a version
+// that has workers in the pool goes to it, any other is split by the versions' weights
+// among those with healthy workers. "" means any worker, as when the pool runs one version.
+func (p *WorkerPool) pickVersion(pinned string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if len(p.versions) < 2 {
+		return ""
+	}
+
+	healthy := make(map[string]bool)
+	present := false
+	for _, worker := range p.workers {
+		present = present || worker.version == pinned
+		if worker.IsHealthy() {
+			healthy[worker.version] = true
+		}
+	}
+	if pinned != "" && present {
+		return pinned
+	}
+
+	total := 0
+	for name, v := range p.versions {
+		if healthy[name] {
+			total += v.weight
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	pick := rand.IntN(total)
+	for name, v := range p.versions {
+		if !healthy[name] {
+			continue
+		}
+		if pick < v.weight {
+			return name
+		}
+		pick -= v.weight
+	}
+	return ""
+}
+
+// recordVersion counts the outcome of an attempt sent to a worker of version
+func (p *WorkerPool) recordVersion(version string, latency time.Duration, failed bool) {
+	p.mu.RLock()
+	v, ok := p.versions[version]
+	p.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	v.requests.Add(1)
+	if failed {
+		v.failures.Add(1)
+	}
+
+	v.latencyMu.Lock()
+	defer v.latencyMu.Unlock()
+	if v.latency == 0 {
+		v.latency = latency
+		return
+	}
+	v.latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(v.latency))
+}
@@ -0,0 +1,133 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+)
+
+// versionedWorker returns a test worker of version
+func versionedWorker(id string, version string, healthy bool) *Worker {
+	worker := testWorker(id)
+	worker.version = version
+	worker.healthy.Store(healthy)
+	return worker
+}
+
+// versionedPool returns a pool of workers with the given versions and weights
+func versionedPool(workers []*Worker, weights map[string]int) *WorkerPool {
+	pool := NewWorkerPool(workers, time.Second, 0)
+	for name, weight := range weights {
+		pool.version(name).weight = weight
+	}
+	return pool
+}
+
+func TestPickVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers []*Worker
+		weights map[string]int
+		pinned  string
+		want    map[string]float64 // Share of the picks each version gets
+	}{
+		{
+			name:    "a single version picks any worker",
+			workers: []*Worker{versionedWorker("a", DefaultVersion, true)},
+			weights: map[string]int{DefaultVersion: 100},
+			want:    map[string]float64{"": 1},
+		},
+		{
+			name:    "traffic is split by weight",
+			workers: []*Worker{versionedWorker("a", DefaultVersion, true), versionedWorker("b", "canary", true)},
+			weights: map[string]int{DefaultVersion: 90, "canary": 10},
+			want:    map[string]float64{DefaultVersion: 0.9, "canary": 0.1},
+		},
+		{
+			name:    "a zero weight only serves pinned requests",
+			workers: []*Worker{versionedWorker("a", DefaultVersion, true), versionedWorker("b", "canary", true)},
+			weights: map[string]int{DefaultVersion: 100, "canary": 0},
+			want:    map[string]float64{DefaultVersion: 1},
+		},
+		{
+			name:    "a pinned version is picked whatever its weight",
+			workers: []*Worker{versionedWorker("a", DefaultVersion, true), versionedWorker("b", "canary", true)},
+			weights: map[string]int{DefaultVersion: 100, "canary": 0},
+			pinned:  "canary",
+			want:    map[string]float64{"canary": 1},
+		},
+		{
+			name:    "a pinned version without workers falls back to the split",
+			workers: []*Worker{versionedWorker("a", DefaultVersion, true), versionedWorker("b", "canary", true)},
+			weights: map[string]int{DefaultVersion: 100, "canary": 0, "next": 50},
+			pinned:  "next",
+			want:    map[string]float64{DefaultVersion: 1},
+		},
+		{
+			name:    "versions without healthy workers get no traffic",
+			workers: []*Worker{versionedWorker("a", DefaultVersion, true), versionedWorker("b", "canary", false)},
+			weights: map[string]int{DefaultVersion: 50, "canary": 50},
+			want:    map[string]float64{DefaultVersion: 1},
+		},
+		{
+			name:    "no healthy worker picks any worker",
+			workers: []*Worker{versionedWorker("a", DefaultVersion, false), versionedWorker("b", "canary", false)},
+			weights: map[string]int{DefaultVersion: 50, "canary": 50},
+			want:    map[string]float64{"": 1},
+		},
+	}
+
+	const picks = 10000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := versionedPool(tt.workers, tt.weights)
+
+			got := make(map[string]int)
+			for i := 0; i < picks; i++ {
+				got[pool.pickVersion(tt.pinned)]++
+			}
+			for version, count := range got {
+				if _, ok := tt.want[version]; !ok {
+					t.Errorf("picked %q %d times, want never", version, count)
+				}
+			}
+			for version, share := range tt.want {
+				if diff := float64(got[version])/picks - share; diff < -0.02 || diff > 0.02 {
+					t.Errorf("picked %q %d times out of %d, want a share of %.2f", version, got[version], picks, share)
+				}
+			}
+		})
+	}
+}
+
+func TestVersionStats(t *testing.T) {
+	o := NewOrchestrator()
+	pool := versionedPool([]*Worker{
+		versionedWorker("a", DefaultVersion, true),
+		versionedWorker("b", DefaultVersion, true),
+		versionedWorker("c", "canary", true),
+	}, map[string]int{DefaultVersion: defaultVersionWeight, "canary": 10})
+	o.pools["test"] = pool
+
+	pool.recordVersion(DefaultVersion, 100*time.Millisecond, false)
+	pool.recordVersion(DefaultVersion, 100*time.Millisecond, false)
+	pool.recordVersion("canary", 100*time.Millisecond, false)
+	pool.recordVersion("canary", 200*time.Millisecond, true)
+	pool.recordVersion("retired", time.Second, true) // Outcomes of unknown versions are dropped
+
+	stats, err := o.VersionStats("test")
+	if err != nil {
+		t.Fatalf("VersionStats() failed: %v", err)
+	}
+	want := []VersionStats{
+		{Version: "canary", Weight: 10, Workers: 1, Requests: 2, Failures: 1, ErrorRate: 0.5, Latency: 120 * time.Millisecond},
+		{Version: DefaultVersion, Weight: defaultVersionWeight, Workers: 2, Requests: 2, ErrorRate: 0, Latency: 100 * time.Millisecond},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("VersionStats() = %+v, want %+v", stats, want)
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("VersionStats()[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+}
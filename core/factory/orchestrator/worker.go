@@ -2,11 +2,14 @@ package orchestrator
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"os/exec"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bsmider/pipes/core/factory"
 	"github.com/bsmider/pipes/core/factory/utils"
@@ -16,29 +19,48 @@ type Worker struct {
 	id          string
 	processType string
 	binaryPath  string
+	version     string // the version of the pool's method the worker runs, see SpawnVersion
 	conn        net.Conn
-	cmd         *exec.Cmd // So we can Kill() it if it freezes
+	cmd         *exec.Cmd // So we can Kill() it if it freezes, nil for remote workers
+	peer        string    // where a remote worker connected from, see peerName
 	mailbox     chan *factory.Packet
 	writeMu     *sync.Mutex
+	done        chan struct{} // closed once the connection to the worker is gone
+	exited      chan struct{} // closed once the worker process has been reaped, or a remote worker has disconnected
+	started     time.Time
+
+	pendingPings atomic.Int32 // pings sent since the last pong
+	healthy      atomic.Bool
+	draining     atomic.Bool                          // set once the worker was asked to drain, it is not restarted
+	onTrial      atomic.Bool                          // set while a rollout decides whether to keep the worker, it is not restarted
+	inFlight     atomic.Int64                         // requests and streams currently sent to the worker
+	status       atomic.Pointer[factory.WorkerStatus] // last status reported in a pong, nil before the first
 }
 
 func NewWorker(id string, processType string, binaryPath string, conn net.Conn, cmd *exec.Cmd, mailbox chan *factory.Packet) *Worker {
-	return &Worker{
+	worker := &Worker{
 		id:          id,
 		processType: processType,
 		binaryPath:  binaryPath,
+		version:     DefaultVersion,
 		conn:        conn,
 		cmd:         cmd,
 		mailbox:     mailbox,
 		writeMu:     &sync.Mutex{},
+		done:        make(chan struct{}),
+		exited:      make(chan struct{}),
+		started:     time.Now(),
 	}
+	worker.healthy.Store(true)
+	return worker
 }
 
 func (w *Worker) listen() {
 	defer func() {
 		w.conn.Close()   // close the connection to the binary/process
+		close(w.done)    // stop anything tied to the worker's lifetime
 		close(w.mailbox) // close the mailbox channel to the orchestrator
-		log.Printf("[Orchestrator] Worker %s (PID %d) connection closed", w.id, w.cmd.Process.Pid)
+		log.Printf("[Orchestrator] Worker %s (%s) connection closed", w.id, w.origin())
 	}()
 
 	reader := bufio.NewReader(w.conn)
@@ -55,6 +77,62 @@ func (w *Worker) listen() {
 	}
 }
 
+// ID returns the unique id of the worker
+func (w *Worker) ID() string {
+	return w.id
+}
+
+// InFlight returns the number of requests and streams currently sent to the worker
+func (w *Worker) InFlight() int64 {
+	return w.inFlight.Load()
+}
+
+// Uptime returns how long ago the worker was started, or connected for a remote worker
+func (w *Worker) Uptime() time.Duration {
+	return time.Since(w.started)
+}
+
+// pid returns the process id of the worker, 0 for a remote worker
+func (w *Worker) pid() int {
+	if w.remote() {
+		return 0
+	}
+	return w.cmd.Process.Pid
+}
+
+// remote reports whether the worker was started elsewhere and connected itself, see ServeRemoteWorkers
+func (w *Worker) remote() bool {
+	return w.cmd == nil
+}
+
+// origin describes where the worker runs for logs
+func (w *Worker) origin() string {
+	if w.remote() {
+		return w.peer
+	}
+	return fmt.Sprintf("PID %d", w.cmd.Process.Pid)
+}
+
+// kill terminates the worker process. Its exit is picked up by the listen loop.
+// A remote worker cannot be killed, its connection is closed instead.
+func (w *Worker) kill() {
+	if w.remote() {
+		w.conn.Close()
+		return
+	}
+	if err := w.cmd.Process.Kill(); err != nil {
+		log.Printf("[Orchestrator] Failed to kill worker %s: %v", w.id, err)
+	}
+}
+
+// cancel tells the worker to stop running the request packet
+func (w *Worker) cancel(packet *factory.Packet) {
+	cancel := factory.NewPacket(packet.Id, factory.PacketType_PACKET_TYPE_CANCEL, packet.TargetIoType, nil, nil, nil)
+	if err := w.sendPacket(cancel); err != nil {
+		log.Printf("[Orchestrator] Failed to cancel %s on worker %s: %v", packet.Id, w.id, err)
+	}
+}
+
 func (w *Worker) sendPacket(packet *factory.Packet) error {
 	return utils.WriteMessage(w.conn, w.writeMu, packet)
 }
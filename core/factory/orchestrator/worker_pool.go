@@ -4,42 +4,173 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type WorkerPool struct {
-	workers []*Worker
-	mu      sync.RWMutex
-	next    uint64 // Tracks the next worker index
-	timeout time.Duration
-	retries int
+	workers    []*Worker
+	mu         sync.RWMutex
+	timeout    time.Duration
+	retry      RetryPolicy
+	binaryPath string                  // The binary new workers of the DefaultVersion are started from
+	versions   map[string]*poolVersion // Versions running side by side, see SpawnVersion
+	balancer   Balancer
+	// Requests in flight per worker, 0 is unlimited
+	maxConcurrency int
+	// Copies of slow requests sent to other workers, replaces retry when enabled
+	hedgingPolicy HedgingPolicy
+	// Fails requests fast while the pool's workers keep failing
+	breaker *CircuitBreaker
+	// Rate limits and concurrency caps checked before a worker is picked
+	admission *admission
+	// Copies requests to a shadow pool, nil when the pool is not mirrored
+	mirror *mirror
+
+	queueMu sync.Mutex
+	queue   waitQueue // Requests waiting for a free slot, see acquire
+	queued  uint64    // Requests queued so far, orders waiters of equal priority
+
+	// Set while Replace moves the pool to a new binary, autoscaling waits for it
+	rolling atomic.Bool
+
+	scaleMu     sync.Mutex
+	scaling     *ScalingPolicy // nil for pools of a fixed size
+	pinned      *int           // Local workers set by Scale, the autoscaler leaves a pinned pool alone
+	lastScaleUp time.Time
+	idleSince   time.Time // When the pool was last seen busy enough, zero while busy
+
+	requests atomic.Uint64 // Requests routed to the pool, see countRequest
+	failures atomic.Uint64 // Those that failed, requests the caller gave up on aside
+
+	latencyMu sync.Mutex
+	latency   time.Duration   // Moving average of successful request latency
+	samples   []time.Duration // Ring of the most recent latencies, see latencyPercentile
+	sampleAt  int             // Where the next sample is written once the ring is full
 }
 
 func NewWorkerPool(workers []*Worker, timeout time.Duration, retries int) *WorkerPool {
+	retry := DefaultRetryPolicy()
+	retry.MaxAttempts = retries + 1
+
 	return &WorkerPool{
-		workers: workers,
-		mu:      sync.RWMutex{},
-		next:    0,
-		timeout: timeout,
-		retries: retries,
+		workers:   workers,
+		mu:        sync.RWMutex{},
+		timeout:   timeout,
+		retry:     retry,
+		balancer:  NewRoundRobinBalancer(),
+		breaker:   NewCircuitBreaker("", DefaultBreakerPolicy()),
+		admission: newAdmission(AdmissionPolicy{}),
 	}
 }
 
-func (p *WorkerPool) GetNextWorker() *Worker {
+// SetBalancer replaces the strategy used to pick workers
+func (p *WorkerPool) SetBalancer(balancer Balancer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.balancer = balancer
+}
+
+// addWorker adds a worker to the pool's rotation
+func (p *WorkerPool) addWorker(worker *Worker) {
+	p.mu.Lock()
+	p.workers = append(p.workers, worker)
+	p.mu.Unlock()
+
+	// A new worker has free slots for requests waiting on the others
+	p.dispatch()
+}
+
+// removeWorker takes a worker out of the pool's rotation.
+// It reports whether the worker was part of the pool.
+func (p *WorkerPool) removeWorker(worker *Worker) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, w := range p.workers {
+		if w == worker {
+			// Copy instead of reslicing in place so readers holding the old slice are unaffected
+			workers := make([]*Worker, 0, len(p.workers)-1)
+			workers = append(workers, p.workers[:i]...)
+			p.workers = append(workers, p.workers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// binary returns the binary new workers of the pool's DefaultVersion are started from
+func (p *WorkerPool) binary() string {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
+	return p.binaryPath
+}
 
-	n := len(p.workers)
-	if n == 0 {
-		return nil
+// setBinary changes the binary new workers of the pool's DefaultVersion are started from
+func (p *WorkerPool) setBinary(binaryPath string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.binaryPath = binaryPath
+}
+
+// localWorkers returns the members of the pool running version the orchestrator started itself
+func (p *WorkerPool) localWorkers(version string) []*Worker {
+	var workers []*Worker
+	for _, worker := range p.snapshot() {
+		if !worker.remote() && worker.version == version {
+			workers = append(workers, worker)
+		}
+	}
+	return workers
+}
+
+// countRequest counts a request routed to the pool and whether it failed
+func (p *WorkerPool) countRequest(response *factory.Packet, err error) {
+	p.requests.Add(1)
+	if err == nil {
+		err = response.GetError().ToGoError()
+	}
+	if err != nil && status.Code(err) != codes.Canceled {
+		p.failures.Add(1)
 	}
+}
+
+// Requests returns the number of requests routed to the pool and how many of them failed
+func (p *WorkerPool) Requests() (requests uint64, failures uint64) {
+	return p.requests.Load(), p.failures.Load()
+}
 
-	// Atomic increment ensures thread-safety across goroutines.
-	// We use the modulo operator (%) to wrap around the slice length.
-	idx := atomic.AddUint64(&p.next, 1)
-	return p.workers[(idx-1)%uint64(n)]
+// snapshot returns the current members of the pool.
+// The slice is never modified in place, so it can be read without the lock.
+func (p *WorkerPool) snapshot() []*Worker {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.workers
 }
 
-// SelectWorker returns the next worker in the pool (round robin load balancing)
+// Size returns the number of workers in the pool
+func (p *WorkerPool) Size() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.workers)
+}
+
+// SelectWorker returns the healthy worker the pool's balancer picks, or nil if there is none
 func (p *WorkerPool) SelectWorker() *Worker {
-	return p.GetNextWorker()
+	p.mu.RLock()
+	workers, balancer := p.workers, p.balancer
+	p.mu.RUnlock()
+
+	healthy := make([]*Worker, 0, len(workers))
+	for _, worker := range workers {
+		if worker.IsHealthy() {
+			healthy = append(healthy, worker)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	return balancer.Select(healthy)
 }
@@ -26,22 +26,52 @@ const (
 type PacketType int32
 
 const (
-	PacketType_PACKET_TYPE_UNSPECIFIED PacketType = 0
-	PacketType_PACKET_TYPE_REQUEST     PacketType = 1
-	PacketType_PACKET_TYPE_RESPONSE    PacketType = 2
+	PacketType_PACKET_TYPE_UNSPECIFIED   PacketType = 0
+	PacketType_PACKET_TYPE_REQUEST       PacketType = 1
+	PacketType_PACKET_TYPE_RESPONSE      PacketType = 2
+	PacketType_PACKET_TYPE_PING          PacketType = 3  // health check sent by the orchestrator, answered by the IONode
+	PacketType_PACKET_TYPE_PONG          PacketType = 4  // reply to a PING, carries the same id and a WorkerStatus payload
+	PacketType_PACKET_TYPE_DRAIN         PacketType = 5  // asks a worker to finish its in-flight requests and exit
+	PacketType_PACKET_TYPE_CANCEL        PacketType = 6  // cancels the request with the same id
+	PacketType_PACKET_TYPE_STREAM_OPEN   PacketType = 7  // opens a stream to target_io_type, the id names the stream
+	PacketType_PACKET_TYPE_STREAM_DATA   PacketType = 8  // one message on an open stream
+	PacketType_PACKET_TYPE_STREAM_END    PacketType = 9  // no more messages from the sender, a server's end carries the final error
+	PacketType_PACKET_TYPE_STREAM_WINDOW PacketType = 10 // allows the receiver of this packet to send window more messages
+	PacketType_PACKET_TYPE_REGISTER      PacketType = 11 // sent by a worker dialing the orchestrator with its method in target_io_type, answered with the assigned worker id or an error
+	PacketType_PACKET_TYPE_PEER          PacketType = 12 // exchanged by peered orchestrators, the payload is a PeerAnnouncement
 )
 
 // Enum value maps for PacketType.
 var (
 	PacketType_name = map[int32]string{
-		0: "PACKET_TYPE_UNSPECIFIED",
-		1: "PACKET_TYPE_REQUEST",
-		2: "PACKET_TYPE_RESPONSE",
+		0:  "PACKET_TYPE_UNSPECIFIED",
+		1:  "PACKET_TYPE_REQUEST",
+		2:  "PACKET_TYPE_RESPONSE",
+		3:  "PACKET_TYPE_PING",
+		4:  "PACKET_TYPE_PONG",
+		5:  "PACKET_TYPE_DRAIN",
+		6:  "PACKET_TYPE_CANCEL",
+		7:  "PACKET_TYPE_STREAM_OPEN",
+		8:  "PACKET_TYPE_STREAM_DATA",
+		9:  "PACKET_TYPE_STREAM_END",
+		10: "PACKET_TYPE_STREAM_WINDOW",
+		11: "PACKET_TYPE_REGISTER",
+		12: "PACKET_TYPE_PEER",
 	}
 	PacketType_value = map[string]int32{
-		"PACKET_TYPE_UNSPECIFIED": 0,
-		"PACKET_TYPE_REQUEST":     1,
-		"PACKET_TYPE_RESPONSE":    2,
+		"PACKET_TYPE_UNSPECIFIED":   0,
+		"PACKET_TYPE_REQUEST":       1,
+		"PACKET_TYPE_RESPONSE":      2,
+		"PACKET_TYPE_PING":          3,
+		"PACKET_TYPE_PONG":          4,
+		"PACKET_TYPE_DRAIN":         5,
+		"PACKET_TYPE_CANCEL":        6,
+		"PACKET_TYPE_STREAM_OPEN":   7,
+		"PACKET_TYPE_STREAM_DATA":   8,
+		"PACKET_TYPE_STREAM_END":    9,
+		"PACKET_TYPE_STREAM_WINDOW": 10,
+		"PACKET_TYPE_REGISTER":      11,
+		"PACKET_TYPE_PEER":          12,
 	}
 )
 
@@ -72,6 +102,56 @@ func (PacketType) EnumDescriptor() ([]byte, []int) {
 	return file_core_factory_protos_packet_proto_rawDescGZIP(), []int{0}
 }
 
+// Priority decides which queued requests a saturated pool dispatches first
+type Priority int32
+
+const (
+	Priority_PRIORITY_NORMAL Priority = 0
+	Priority_PRIORITY_LOW    Priority = 1 // batch traffic, waits behind everything else
+	Priority_PRIORITY_HIGH   Priority = 2 // interactive traffic, dispatched first
+)
+
+// Enum value maps for Priority.
+var (
+	Priority_name = map[int32]string{
+		0: "PRIORITY_NORMAL",
+		1: "PRIORITY_LOW",
+		2: "PRIORITY_HIGH",
+	}
+	Priority_value = map[string]int32{
+		"PRIORITY_NORMAL": 0,
+		"PRIORITY_LOW":    1,
+		"PRIORITY_HIGH":   2,
+	}
+)
+
+func (x Priority) Enum() *Priority {
+	p := new(Priority)
+	*p = x
+	return p
+}
+
+func (x Priority) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Priority) Descriptor() protoreflect.EnumDescriptor {
+	return file_core_factory_protos_packet_proto_enumTypes[1].Descriptor()
+}
+
+func (Priority) Type() protoreflect.EnumType {
+	return &file_core_factory_protos_packet_proto_enumTypes[1]
+}
+
+func (x Priority) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Priority.Descriptor instead.
+func (Priority) EnumDescriptor() ([]byte, []int) {
+	return file_core_factory_protos_packet_proto_rawDescGZIP(), []int{1}
+}
+
 type Packet struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -80,6 +160,7 @@ type Packet struct {
 	Context       *Context               `protobuf:"bytes,4,opt,name=context,proto3" json:"context,omitempty"`
 	Payload       []byte                 `protobuf:"bytes,5,opt,name=payload,proto3" json:"payload,omitempty"`
 	Error         *Error                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	Window        uint32                 `protobuf:"varint,7,opt,name=window,proto3" json:"window,omitempty"` // credit granted by a STREAM_WINDOW packet
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -156,6 +237,13 @@ func (x *Packet) GetError() *Error {
 	return nil
 }
 
+func (x *Packet) GetWindow() uint32 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
 type Error struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Status        *status.Status         `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
@@ -205,6 +293,8 @@ type Context struct {
 	Deadline      *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=deadline,proto3" json:"deadline,omitempty"`
 	TraceId       string                 `protobuf:"bytes,2,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
 	Hops          []*Hop                 `protobuf:"bytes,3,rep,name=hops,proto3" json:"hops,omitempty"`
+	Priority      Priority               `protobuf:"varint,4,opt,name=priority,proto3,enum=factory.Priority" json:"priority,omitempty"` // inherited by every call made while handling the request
+	Version       string                 `protobuf:"bytes,5,opt,name=version,proto3" json:"version,omitempty"`                          // pins the request, and the calls made while handling it, to this version of any method that runs it
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -260,6 +350,20 @@ func (x *Context) GetHops() []*Hop {
 	return nil
 }
 
+func (x *Context) GetPriority() Priority {
+	if x != nil {
+		return x.Priority
+	}
+	return Priority_PRIORITY_NORMAL
+}
+
+func (x *Context) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
 type Hop struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	BinaryId      string                 `protobuf:"bytes,1,opt,name=binary_id,json=binaryId,proto3" json:"binary_id,omitempty"`
@@ -312,32 +416,189 @@ func (x *Hop) GetTimestamp() *timestamppb.Timestamp {
 	return nil
 }
 
+// WorkerStatus is the payload of a PONG, a worker's report of its load
+type WorkerStatus struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	MaxConcurrency   uint32                 `protobuf:"varint,1,opt,name=max_concurrency,json=maxConcurrency,proto3" json:"max_concurrency,omitempty"`       // requests the worker accepts at once, 0 is unlimited
+	InFlight         uint32                 `protobuf:"varint,2,opt,name=in_flight,json=inFlight,proto3" json:"in_flight,omitempty"`                         // requests accepted and not yet answered
+	RejectedRequests uint64                 `protobuf:"varint,3,opt,name=rejected_requests,json=rejectedRequests,proto3" json:"rejected_requests,omitempty"` // requests turned away because the worker was at its limit
+	DroppedResponses uint64                 `protobuf:"varint,4,opt,name=dropped_responses,json=droppedResponses,proto3" json:"dropped_responses,omitempty"` // responses nobody was waiting for any more
+	DroppedPackets   uint64                 `protobuf:"varint,5,opt,name=dropped_packets,json=droppedPackets,proto3" json:"dropped_packets,omitempty"`       // other packets that could not be delivered
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *WorkerStatus) Reset() {
+	*x = WorkerStatus{}
+	mi := &file_core_factory_protos_packet_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkerStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkerStatus) ProtoMessage() {}
+
+func (x *WorkerStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_packet_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkerStatus.ProtoReflect.Descriptor instead.
+func (*WorkerStatus) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_packet_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *WorkerStatus) GetMaxConcurrency() uint32 {
+	if x != nil {
+		return x.MaxConcurrency
+	}
+	return 0
+}
+
+func (x *WorkerStatus) GetInFlight() uint32 {
+	if x != nil {
+		return x.InFlight
+	}
+	return 0
+}
+
+func (x *WorkerStatus) GetRejectedRequests() uint64 {
+	if x != nil {
+		return x.RejectedRequests
+	}
+	return 0
+}
+
+func (x *WorkerStatus) GetDroppedResponses() uint64 {
+	if x != nil {
+		return x.DroppedResponses
+	}
+	return 0
+}
+
+func (x *WorkerStatus) GetDroppedPackets() uint64 {
+	if x != nil {
+		return x.DroppedPackets
+	}
+	return 0
+}
+
+type PeerAnnouncement struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`                            // the name of the announcing orchestrator, recorded in hops as "peer:name"
+	MethodIds     []string               `protobuf:"bytes,2,rep,name=method_ids,json=methodIds,proto3" json:"method_ids,omitempty"` // the methods the orchestrator has a pool for
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerAnnouncement) Reset() {
+	*x = PeerAnnouncement{}
+	mi := &file_core_factory_protos_packet_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerAnnouncement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerAnnouncement) ProtoMessage() {}
+
+func (x *PeerAnnouncement) ProtoReflect() protoreflect.Message {
+	mi := &file_core_factory_protos_packet_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerAnnouncement.ProtoReflect.Descriptor instead.
+func (*PeerAnnouncement) Descriptor() ([]byte, []int) {
+	return file_core_factory_protos_packet_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PeerAnnouncement) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PeerAnnouncement) GetMethodIds() []string {
+	if x != nil {
+		return x.MethodIds
+	}
+	return nil
+}
+
 var File_core_factory_protos_packet_proto protoreflect.FileDescriptor
 
 const file_core_factory_protos_packet_proto_rawDesc = "" +
 	"\n" +
-	" core/factory/protos/packet.proto\x12\afactory\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x17google/rpc/status.proto\"\xd3\x01\n" +
+	" core/factory/protos/packet.proto\x12\afactory\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x17google/rpc/status.proto\"\xeb\x01\n" +
 	"\x06Packet\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12'\n" +
 	"\x04type\x18\x02 \x01(\x0e2\x13.factory.PacketTypeR\x04type\x12$\n" +
 	"\x0etarget_io_type\x18\x03 \x01(\tR\ftargetIoType\x12*\n" +
 	"\acontext\x18\x04 \x01(\v2\x10.factory.ContextR\acontext\x12\x18\n" +
 	"\apayload\x18\x05 \x01(\fR\apayload\x12$\n" +
-	"\x05error\x18\x06 \x01(\v2\x0e.factory.ErrorR\x05error\"3\n" +
+	"\x05error\x18\x06 \x01(\v2\x0e.factory.ErrorR\x05error\x12\x16\n" +
+	"\x06window\x18\a \x01(\rR\x06window\"3\n" +
 	"\x05Error\x12*\n" +
-	"\x06status\x18\x01 \x01(\v2\x12.google.rpc.StatusR\x06status\"~\n" +
+	"\x06status\x18\x01 \x01(\v2\x12.google.rpc.StatusR\x06status\"\xc7\x01\n" +
 	"\aContext\x126\n" +
 	"\bdeadline\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\bdeadline\x12\x19\n" +
 	"\btrace_id\x18\x02 \x01(\tR\atraceId\x12 \n" +
-	"\x04hops\x18\x03 \x03(\v2\f.factory.HopR\x04hops\"\\\n" +
+	"\x04hops\x18\x03 \x03(\v2\f.factory.HopR\x04hops\x12-\n" +
+	"\bpriority\x18\x04 \x01(\x0e2\x11.factory.PriorityR\bpriority\x12\x18\n" +
+	"\aversion\x18\x05 \x01(\tR\aversion\"\\\n" +
 	"\x03Hop\x12\x1b\n" +
 	"\tbinary_id\x18\x01 \x01(\tR\bbinaryId\x128\n" +
-	"\ttimestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp*\\\n" +
+	"\ttimestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"\xd7\x01\n" +
+	"\fWorkerStatus\x12'\n" +
+	"\x0fmax_concurrency\x18\x01 \x01(\rR\x0emaxConcurrency\x12\x1b\n" +
+	"\tin_flight\x18\x02 \x01(\rR\binFlight\x12+\n" +
+	"\x11rejected_requests\x18\x03 \x01(\x04R\x10rejectedRequests\x12+\n" +
+	"\x11dropped_responses\x18\x04 \x01(\x04R\x10droppedResponses\x12'\n" +
+	"\x0fdropped_packets\x18\x05 \x01(\x04R\x0edroppedPackets\"E\n" +
+	"\x10PeerAnnouncement\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"method_ids\x18\x02 \x03(\tR\tmethodIds*\xdc\x02\n" +
 	"\n" +
 	"PacketType\x12\x1b\n" +
 	"\x17PACKET_TYPE_UNSPECIFIED\x10\x00\x12\x17\n" +
 	"\x13PACKET_TYPE_REQUEST\x10\x01\x12\x18\n" +
-	"\x14PACKET_TYPE_RESPONSE\x10\x02B/Z-github.com/bsmider/pipes/core/factory;factoryb\x06proto3"
+	"\x14PACKET_TYPE_RESPONSE\x10\x02\x12\x14\n" +
+	"\x10PACKET_TYPE_PING\x10\x03\x12\x14\n" +
+	"\x10PACKET_TYPE_PONG\x10\x04\x12\x15\n" +
+	"\x11PACKET_TYPE_DRAIN\x10\x05\x12\x16\n" +
+	"\x12PACKET_TYPE_CANCEL\x10\x06\x12\x1b\n" +
+	"\x17PACKET_TYPE_STREAM_OPEN\x10\a\x12\x1b\n" +
+	"\x17PACKET_TYPE_STREAM_DATA\x10\b\x12\x1a\n" +
+	"\x16PACKET_TYPE_STREAM_END\x10\t\x12\x1d\n" +
+	"\x19PACKET_TYPE_STREAM_WINDOW\x10\n" +
+	"\x12\x18\n" +
+	"\x14PACKET_TYPE_REGISTER\x10\v\x12\x14\n" +
+	"\x10PACKET_TYPE_PEER\x10\f*D\n" +
+	"\bPriority\x12\x13\n" +
+	"\x0fPRIORITY_NORMAL\x10\x00\x12\x10\n" +
+	"\fPRIORITY_LOW\x10\x01\x12\x11\n" +
+	"\rPRIORITY_HIGH\x10\x02B/Z-github.com/bsmider/pipes/core/factory;factoryb\x06proto3"
 
 var (
 	file_core_factory_protos_packet_proto_rawDescOnce sync.Once
@@ -351,30 +612,34 @@ func file_core_factory_protos_packet_proto_rawDescGZIP() []byte {
 	return file_core_factory_protos_packet_proto_rawDescData
 }
 
-var file_core_factory_protos_packet_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_core_factory_protos_packet_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_core_factory_protos_packet_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_core_factory_protos_packet_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
 var file_core_factory_protos_packet_proto_goTypes = []any{
 	(PacketType)(0),               // 0: factory.PacketType
-	(*Packet)(nil),                // 1: factory.Packet
-	(*Error)(nil),                 // 2: factory.Error
-	(*Context)(nil),               // 3: factory.Context
-	(*Hop)(nil),                   // 4: factory.Hop
-	(*status.Status)(nil),         // 5: google.rpc.Status
-	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+	(Priority)(0),                 // 1: factory.Priority
+	(*Packet)(nil),                // 2: factory.Packet
+	(*Error)(nil),                 // 3: factory.Error
+	(*Context)(nil),               // 4: factory.Context
+	(*Hop)(nil),                   // 5: factory.Hop
+	(*WorkerStatus)(nil),          // 6: factory.WorkerStatus
+	(*PeerAnnouncement)(nil),      // 7: factory.PeerAnnouncement
+	(*status.Status)(nil),         // 8: google.rpc.Status
+	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
 }
 var file_core_factory_protos_packet_proto_depIdxs = []int32{
 	0, // 0: factory.Packet.type:type_name -> factory.PacketType
-	3, // 1: factory.Packet.context:type_name -> factory.Context
-	2, // 2: factory.Packet.error:type_name -> factory.Error
-	5, // 3: factory.Error.status:type_name -> google.rpc.Status
-	6, // 4: factory.Context.deadline:type_name -> google.protobuf.Timestamp
-	4, // 5: factory.Context.hops:type_name -> factory.Hop
-	6, // 6: factory.Hop.timestamp:type_name -> google.protobuf.Timestamp
-	7, // [7:7] is the sub-list for method output_type
-	7, // [7:7] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	7, // [7:7] is the sub-list for extension extendee
-	0, // [0:7] is the sub-list for field type_name
+	4, // 1: factory.Packet.context:type_name -> factory.Context
+	3, // 2: factory.Packet.error:type_name -> factory.Error
+	8, // 3: factory.Error.status:type_name -> google.rpc.Status
+	9, // 4: factory.Context.deadline:type_name -> google.protobuf.Timestamp
+	5, // 5: factory.Context.hops:type_name -> factory.Hop
+	1, // 6: factory.Context.priority:type_name -> factory.Priority
+	9, // 7: factory.Hop.timestamp:type_name -> google.protobuf.Timestamp
+	8, // [8:8] is the sub-list for method output_type
+	8, // [8:8] is the sub-list for method input_type
+	8, // [8:8] is the sub-list for extension type_name
+	8, // [8:8] is the sub-list for extension extendee
+	0, // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_core_factory_protos_packet_proto_init() }
@@ -387,8 +652,8 @@ func file_core_factory_protos_packet_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_core_factory_protos_packet_proto_rawDesc), len(file_core_factory_protos_packet_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   4,
+			NumEnums:      2,
+			NumMessages:   6,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,57 @@
+package factory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Rank orders priorities from lowest to highest, the enum values are not in that order
+func (p Priority) Rank() int {
+	switch p {
+	case Priority_PRIORITY_LOW:
+		return 0
+	case Priority_PRIORITY_HIGH:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// ParsePriority parses a priority name such as "high" or "PRIORITY_HIGH"
+func ParsePriority(name string) (Priority, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "PRIORITY_") {
+		name = "PRIORITY_" + name
+	}
+	value, ok := Priority_value[name]
+	if !ok {
+		return Priority_PRIORITY_NORMAL, fmt.Errorf("unknown priority %q", name)
+	}
+	return Priority(value), nil
+}
+
+// PriorityFromContext returns the priority of the request being handled with ctx.
+// Calls made with ctx are sent with the same priority.
+func PriorityFromContext(ctx context.Context) Priority {
+	if protoContextWrapper, ok := ctx.Value(protoContexWrappertKey).(*ContextWrapper); ok {
+		return protoContextWrapper.ctx.GetPriority()
+	}
+	return Priority_PRIORITY_NORMAL
+}
+
+// SetPriority changes the priority calls made with ctx are sent with
+func SetPriority(ctx context.Context, priority Priority) error {
+	if ctx == nil {
+		return fmt.Errorf("context is nil")
+	}
+
+	if protoContextWrapper, ok := ctx.Value(protoContexWrappertKey).(*ContextWrapper); ok {
+		protoContextWrapper.mu.Lock()
+		defer protoContextWrapper.mu.Unlock()
+		protoContextWrapper.ctx.Priority = priority
+		return nil
+	}
+
+	return fmt.Errorf("context is not a proto context")
+}
@@ -0,0 +1,54 @@
+package processes
+
+import (
+	"log"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultMaxConcurrency is how many requests a node accepts at once unless told otherwise
+const DefaultMaxConcurrency = 100
+
+// SetMaxConcurrency limits the requests the node accepts at once, 0 is unlimited.
+// Requests above the limit are answered with RESOURCE_EXHAUSTED so the orchestrator
+// can send them elsewhere. The limit is advertised to the orchestrator with every PONG.
+func (node *IONode) SetMaxConcurrency(limit int) {
+	node.maxConcurrency.Store(int64(max(limit, 0)))
+}
+
+// Status reports the node's limit, load and the packets it has dropped so far
+func (node *IONode) Status() *factory.WorkerStatus {
+	return &factory.WorkerStatus{
+		MaxConcurrency:   uint32(node.maxConcurrency.Load()),
+		InFlight:         uint32(node.active.Load()),
+		RejectedRequests: node.rejectedRequests.Load(),
+		DroppedResponses: node.droppedResponses.Load(),
+		DroppedPackets:   node.droppedPackets.Load(),
+	}
+}
+
+// answerPing replies to a health check with the node's status
+func (node *IONode) answerPing(ping *factory.Packet) {
+	payload, err := proto.Marshal(node.Status())
+	if err != nil {
+		log.Printf("[ProcessRunner] Failed to encode status: %v\n", err)
+	}
+
+	pong := factory.NewPacket(ping.Id, factory.PacketType_PACKET_TYPE_PONG, ping.TargetIoType, nil, payload, nil)
+	if err := node.sendPacket(pong); err != nil {
+		log.Printf("[ProcessRunner] Failed to answer ping %s: %v\n", ping.Id, err)
+	}
+}
+
+// dropResponse counts a response that could not be delivered
+func (node *IONode) dropResponse(packet *factory.Packet, reason string) {
+	node.droppedResponses.Add(1)
+	log.Printf("[ProcessRunner] Warning: Drop packet %s - %s\n", packet.Id, reason)
+}
+
+// dropPacket counts any other packet that could not be delivered
+func (node *IONode) dropPacket(packet *factory.Packet, reason string) {
+	node.droppedPackets.Add(1)
+	log.Printf("[ProcessRunner] Warning: Drop packet %s - %s\n", packet.Id, reason)
+}
@@ -0,0 +1,83 @@
+package processes
+
+import (
+	"context"
+	"log"
+
+	"github.com/bsmider/pipes/core/factory"
+)
+
+// runningRequest is a request accepted by the node whose logic may still be running
+type runningRequest struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	slot   bool // the request holds one of the node's concurrency slots, see releaseSlot
+}
+
+// trackRequest registers a cancellable context for an accepted request.
+// It is registered before the request is queued so a CANCEL can never miss it.
+func (node *IONode) trackRequest(packetID string) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	node.mapMu.Lock()
+	defer node.mapMu.Unlock()
+	node.running[packetID] = &runningRequest{ctx: ctx, cancel: cancel, slot: true}
+}
+
+// requestContext returns the context tracked for packetID,
+// or a fresh background context if the request is unknown
+func (node *IONode) requestContext(packetID string) context.Context {
+	node.mapMu.Lock()
+	defer node.mapMu.Unlock()
+
+	if request, ok := node.running[packetID]; ok {
+		return request.ctx
+	}
+	return context.Background()
+}
+
+// untrackRequest forgets a request once it has been answered
+func (node *IONode) untrackRequest(packetID string) {
+	node.releaseSlot(packetID)
+
+	node.mapMu.Lock()
+	request, ok := node.running[packetID]
+	delete(node.running, packetID)
+	node.mapMu.Unlock()
+
+	if ok {
+		request.cancel()
+	}
+}
+
+// releaseSlot frees the concurrency slot of a request. It is called right before the
+// answer is sent, as the orchestrator may send the next request as soon as it has it.
+func (node *IONode) releaseSlot(packetID string) {
+	node.mapMu.Lock()
+	defer node.mapMu.Unlock()
+
+	if request, ok := node.running[packetID]; ok && request.slot {
+		request.slot = false
+		node.active.Add(-1)
+	}
+}
+
+// cancelRequest cancels the context of a running request. Any processes.Call
+// made with that context is cancelled in turn, spreading it down the call chain.
+func (node *IONode) cancelRequest(packetID string) {
+	node.mapMu.Lock()
+	request, ok := node.running[packetID]
+	node.mapMu.Unlock()
+
+	if ok {
+		request.cancel()
+	}
+}
+
+// sendCancel tells the orchestrator we no longer need the response to packet
+func (node *IONode) sendCancel(packet *factory.Packet) {
+	cancel := factory.NewPacket(packet.Id, factory.PacketType_PACKET_TYPE_CANCEL, packet.TargetIoType, nil, nil, nil)
+	if err := node.sendPacket(cancel); err != nil {
+		log.Printf("[ProcessRunner] Failed to cancel %s: %v\n", packet.Id, err)
+	}
+}
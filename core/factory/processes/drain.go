@@ -0,0 +1,88 @@
+package processes
+
+import (
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Drain stops the node from taking new requests. Requests already in progress
+// are allowed to finish, after which Done is closed.
+// It is safe to call more than once.
+func (node *IONode) Drain() {
+	node.drainMu.Lock()
+	if node.draining {
+		node.drainMu.Unlock()
+		return
+	}
+	node.draining = true
+	node.drainMu.Unlock()
+
+	log.Printf("[ProcessRunner] %s draining", node.id)
+
+	go func() {
+		node.inFlight.Wait()
+		close(node.done)
+	}()
+}
+
+// Done returns a channel that is closed once the node has drained.
+// Generated workers block on it and exit when it closes.
+func (node *IONode) Done() <-chan struct{} {
+	return node.done
+}
+
+// DrainOnSignal drains the node when any of the given signals is received
+func (node *IONode) DrainOnSignal(signals ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+
+	go func() {
+		sig := <-ch
+		log.Printf("[ProcessRunner] %s received %v", node.id, sig)
+		node.Drain()
+	}()
+}
+
+// acceptRequest registers a new in-flight request. It returns the status to reject
+// the request with once the node is draining or at its concurrency limit.
+func (node *IONode) acceptRequest() *status.Status {
+	node.drainMu.Lock()
+	defer node.drainMu.Unlock()
+
+	if node.draining {
+		return status.New(codes.Unavailable, "worker is draining")
+	}
+	if limit := node.maxConcurrency.Load(); limit > 0 && node.active.Load() >= limit {
+		node.rejectedRequests.Add(1)
+		return status.Newf(codes.ResourceExhausted, "worker is at its limit of %d requests", limit)
+	}
+	node.active.Add(1)
+	node.inFlight.Add(1)
+	return nil
+}
+
+// finishRequest marks an accepted request as answered.
+// Its concurrency slot is freed by untrackRequest.
+func (node *IONode) finishRequest() {
+	node.inFlight.Done()
+}
+
+// rejectRequest answers a request without running it, so the orchestrator can send it elsewhere.
+// A rejected stream is ended straight away.
+func (node *IONode) rejectRequest(packet *factory.Packet, st *status.Status) {
+	node.releaseSlot(packet.Id)
+	st = factory.MarkNotProcessed(st)
+	responseType := factory.PacketType_PACKET_TYPE_RESPONSE
+	if packet.Type == factory.PacketType_PACKET_TYPE_STREAM_OPEN {
+		responseType = factory.PacketType_PACKET_TYPE_STREAM_END
+	}
+	response := factory.NewPacket(packet.Id, responseType, "", packet.Context, nil, factory.NewError(st.Proto()))
+	if err := node.sendPacket(response); err != nil {
+		log.Printf("[ProcessRunner] Failed to reject packet %s: %v\n", packet.Id, err)
+	}
+}
@@ -4,16 +4,20 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"log"
 
 	"github.com/bsmider/pipes/core/factory"
 	"github.com/bsmider/pipes/core/factory/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -24,10 +28,21 @@ type IONode struct {
 	mapMu            sync.Mutex                      // used to synchronize access to the map
 	writeMu          sync.Mutex                      // used to synchronize writes to the writer
 	ResponseChannels map[string]chan *factory.Packet // maps an id to a channel that made an outbound call and is awaiting a response
+	running          map[string]*runningRequest      // maps the id of each accepted request to its cancellable context
+	streams          map[string]*nodeStream          // maps a stream id to the streams this node takes part in
 	RequestChannel   chan *factory.Packet            // a channel that processes new requests
 	reader           io.Reader                       // the reader to use for reading input from io
 	writer           io.Writer                       // the writer to use for writing output to io
 	conn             net.Conn                        // the connection to use for reading and writing
+	drainMu          sync.Mutex                      // guards draining and additions to inFlight
+	draining         bool                            // set once the node stops accepting new requests
+	inFlight         sync.WaitGroup                  // requests accepted but not yet answered
+	done             chan struct{}                   // closed once the node has drained
+	maxConcurrency   atomic.Int64                    // requests accepted at once, 0 is unlimited
+	active           atomic.Int64                    // requests accepted and not yet answered
+	rejectedRequests atomic.Uint64                   // requests turned away at the limit
+	droppedResponses atomic.Uint64                   // responses nobody was waiting for
+	droppedPackets   atomic.Uint64                   // other packets that could not be delivered
 }
 
 var (
@@ -44,11 +59,19 @@ func GetIONode(id ...string) *IONode {
 		var writer io.Writer = os.Stdout
 		var socketConn net.Conn
 
+		// --- REMOTE CONNECTION ---
+		// A worker that dialed the orchestrator with DialOrchestrator uses that connection.
+		if remote.conn != nil {
+			socketConn = remote.conn
+			reader = remote.conn
+			writer = remote.conn
+		}
+
 		// --- SOCKETPAIR DETECTION LOGIC ---
 		// By convention, ExtraFiles[0] passed by the parent becomes FD 3.
 		// We attempt to create a net.Conn from File Descriptor 3.
 		f := os.NewFile(3, "vibe-socket")
-		if f != nil {
+		if socketConn == nil && f != nil {
 			// Check if FD 3 is actually a valid socket
 			conn, err := net.FileConn(f)
 			if err == nil {
@@ -64,7 +87,9 @@ func GetIONode(id ...string) *IONode {
 		}
 
 		var finalID string
-		if len(id) > 0 {
+		if remote.id != "" {
+			finalID = remote.id
+		} else if len(id) > 0 {
 			finalID = id[0]
 		} else {
 			finalID = "unknown-node"
@@ -75,11 +100,15 @@ func GetIONode(id ...string) *IONode {
 			mapMu:            sync.Mutex{},
 			writeMu:          sync.Mutex{},
 			ResponseChannels: make(map[string]chan *factory.Packet), // maps id's to channels that sent a request to another binary and are waiting for a response
+			running:          make(map[string]*runningRequest),      // maps id's of requests being handled to their cancel functions
+			streams:          make(map[string]*nodeStream),          // maps stream id's to open streams
 			RequestChannel:   make(chan *factory.Packet, 100),       // processes new requests
 			reader:           reader,
 			writer:           writer,
 			conn:             socketConn,
+			done:             make(chan struct{}),
 		}
+		instance.SetMaxConcurrency(DefaultMaxConcurrency)
 	})
 	return instance
 }
@@ -95,12 +124,15 @@ func (node *IONode) readInput() {
 		// Read a length-prefixed PipeMessage
 		if err := utils.ReadMessage(reader, packet); err != nil {
 			// 1. The stream ended intentionally. Exit quietly.
+			// Without a connection there is nobody to serve, so wind down.
 			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				node.Drain()
 				return
 			}
 
 			// 2. Something actually went wrong. Log and bail.
 			log.Printf("[ProcessRunner] FATAL: Stream corrupted: %v\n", err)
+			node.Drain()
 			return
 		}
 
@@ -119,27 +151,69 @@ func (node *IONode) Listen() {
 }
 
 func (node *IONode) routePacket(packet *factory.Packet) {
-	// MULTIPLEXING LOGIC
-	node.mapMu.Lock()
-	responseChannel, isAwaitingResponse := node.ResponseChannels[packet.Id]
-	node.mapMu.Unlock()
+	// Health checks are answered right away, from the reader goroutine,
+	// so a worker busy with requests still reports itself as alive
+	if packet.Type == factory.PacketType_PACKET_TYPE_PING {
+		node.answerPing(packet)
+		return
+	}
+
+	switch packet.Type {
+	case factory.PacketType_PACKET_TYPE_DRAIN:
+		node.Drain()
+
+	case factory.PacketType_PACKET_TYPE_CANCEL:
+		node.cancelRequest(packet.Id)
+		node.cancelStream(packet.Id)
+
+	case factory.PacketType_PACKET_TYPE_STREAM_DATA,
+		factory.PacketType_PACKET_TYPE_STREAM_END,
+		factory.PacketType_PACKET_TYPE_STREAM_WINDOW:
+		node.deliverStreamPacket(packet)
+
+	case factory.PacketType_PACKET_TYPE_RESPONSE:
+		// MULTIPLEXING LOGIC
+		node.mapMu.Lock()
+		responseChannel, isAwaitingResponse := node.ResponseChannels[packet.Id]
+		node.mapMu.Unlock()
+
+		if !isAwaitingResponse {
+			node.dropResponse(packet, "nobody is awaiting this response")
+			return
+		}
 
-	if isAwaitingResponse {
 		// CASE A: RESPONSE to a request we sent
 		select {
 		case responseChannel <- packet:
 			// Success
 		default:
-			log.Printf("[ProcessRunner] Warning: Drop packet %s - channel full/no receiver\n", packet.Id)
+			node.dropResponse(packet, "channel full/no receiver")
+		}
+
+	case factory.PacketType_PACKET_TYPE_REQUEST, factory.PacketType_PACKET_TYPE_STREAM_OPEN:
+		// CASE B: NEW REQUEST (or stream) from another process
+		if st := node.acceptRequest(); st != nil {
+			node.rejectRequest(packet, st)
+			return
+		}
+		node.trackRequest(packet.Id)
+		if packet.Type == factory.PacketType_PACKET_TYPE_STREAM_OPEN {
+			node.acceptStream(packet)
 		}
-	} else {
-		// CASE B: NEW REQUEST from another process
+
 		select {
 		case node.RequestChannel <- packet:
 			// log.Printf("[ProcessRunner] Routing ID %s to NewRequestChannel\n", packet.Id)
 		default:
-			log.Printf("[ProcessRunner] Critical: RequestChannel full, dropping packet %s\n", packet.Id)
+			node.removeStream(packet.Id)
+			node.untrackRequest(packet.Id)
+			node.finishRequest()
+			node.rejectedRequests.Add(1)
+			node.rejectRequest(packet, status.New(codes.ResourceExhausted, "worker request queue is full"))
 		}
+
+	default:
+		node.dropPacket(packet, fmt.Sprintf("unexpected type %v", packet.Type))
 	}
 }
 
@@ -151,13 +225,23 @@ func Handle[RequestPayloadType proto.Message, ResponsePayloadType proto.Message]
 	go func() {
 		for requestPacket := range node.RequestChannel {
 			go func(requestPacket *factory.Packet) {
+				defer node.finishRequest()
+				defer node.untrackRequest(requestPacket.Id)
+
+				if requestPacket.Type == factory.PacketType_PACKET_TYPE_STREAM_OPEN {
+					node.removeStream(requestPacket.Id)
+					node.rejectRequest(requestPacket, status.New(codes.Unimplemented, "method does not serve streams"))
+					return
+				}
+
 				requestObject, err := utils.BytesToType[RequestPayloadType](requestPacket.Payload)
 				if err != nil {
 					log.Printf("decode error: %v", err)
 					return
 				}
 
-				context, cancel := requestPacket.Context.ToGoContext()
+				// Derived from the tracked context so a CANCEL packet reaches the logic
+				context, cancel := requestPacket.Context.ToGoContextFrom(node.requestContext(requestPacket.Id))
 				defer cancel()
 
 				responseObject, err := logic(context, requestObject)
@@ -178,6 +262,7 @@ func Handle[RequestPayloadType proto.Message, ResponsePayloadType proto.Message]
 					return
 				}
 
+				node.releaseSlot(requestPacket.Id)
 				err = node.sendPacket(responsePacket)
 				if err != nil {
 					log.Printf("write error: %v", err)
@@ -192,8 +277,9 @@ func (node *IONode) sendPacket(packet *factory.Packet) error {
 	return utils.WriteMessage(node.writer, &node.writeMu, packet)
 }
 
-// Sends a request to another process and blocks until a response is received
-func (node *IONode) executeRequest(packet *factory.Packet) (*factory.Packet, error) {
+// Sends a request to another process and blocks until a response is received.
+// If ctx is done first, the request is cancelled downstream.
+func (node *IONode) executeRequest(ctx context.Context, packet *factory.Packet) (*factory.Packet, error) {
 	responseChannel := make(chan *factory.Packet, 1)
 
 	// 1. Register our ID
@@ -215,15 +301,22 @@ func (node *IONode) executeRequest(packet *factory.Packet) (*factory.Packet, err
 	}
 
 	// 4. Block for response
-	return node.awaitResponse(responseChannel, 10*time.Second)
+	return node.awaitResponse(ctx, packet, responseChannel, 10*time.Second)
 }
 
-func (node *IONode) awaitResponse(responseChannel chan *factory.Packet, timeout time.Duration) (*factory.Packet, error) {
+func (node *IONode) awaitResponse(ctx context.Context, packet *factory.Packet, responseChannel chan *factory.Packet, timeout time.Duration) (*factory.Packet, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
 	select {
 	case response := <-responseChannel:
 		return response, nil
-	case <-time.After(timeout):
-		return nil, errors.New("request timed out")
+	case <-timer.C:
+		node.sendCancel(packet)
+		return nil, status.Error(codes.DeadlineExceeded, "request timed out")
+	case <-ctx.Done():
+		node.sendCancel(packet)
+		return nil, status.FromContextError(ctx.Err()).Err()
 	}
 }
 
@@ -236,7 +329,7 @@ func Call[RequestType proto.Message, ResponseType proto.Message](targetIoType st
 		return zero, err
 	}
 
-	responsePacket, err := GetIONode().executeRequest(requestPacket)
+	responsePacket, err := GetIONode().executeRequest(context, requestPacket)
 	if err != nil {
 		return zero, err
 	}
@@ -0,0 +1,80 @@
+package processes
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bsmider/pipes/core/factory"
+	"github.com/bsmider/pipes/core/factory/utils"
+)
+
+// registerTimeout is how long the orchestrator has to answer a REGISTER
+const registerTimeout = 10 * time.Second
+
+// remote is the connection set up by DialOrchestrator or AttachOrchestrator,
+// used by GetIONode instead of FD 3 or stdio
+var remote struct {
+	conn net.Conn
+	id   string // the worker id assigned by the orchestrator
+}
+
+// DialOrchestrator connects to an orchestrator serving remote workers on addr,
+// authenticating both ends with config, and registers this process as a worker
+// of methodID. It must be called before GetIONode.
+func DialOrchestrator(addr string, methodID string, config *tls.Config) error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: registerTimeout}, "tcp", addr, config)
+	if err != nil {
+		return fmt.Errorf("failed to dial orchestrator %s: %w", addr, err)
+	}
+	return register(conn, methodID)
+}
+
+// DialOrchestratorWithCerts is DialOrchestrator with the PEM certificate and key of this
+// worker and the CA that signed the orchestrator's certificate, see utils.ClientTLSConfig
+func DialOrchestratorWithCerts(addr string, methodID string, certFile string, keyFile string, caFile string) error {
+	config, err := utils.ClientTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		return err
+	}
+	return DialOrchestrator(addr, methodID, config)
+}
+
+// AttachOrchestrator joins the pool of methodID of an orchestrator running on this
+// machine through its attach socket at path, e.g. to run a worker under a debugger.
+// The orchestrator does not restart or stop the process. It must be called before GetIONode.
+func AttachOrchestrator(path string, methodID string) error {
+	conn, err := net.DialTimeout("unix", path, registerTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to attach to orchestrator at %s: %w", path, err)
+	}
+	return register(conn, methodID)
+}
+
+// register sends a REGISTER for methodID on conn and waits for the orchestrator's answer
+func register(conn net.Conn, methodID string) error {
+	conn.SetDeadline(time.Now().Add(registerTimeout))
+
+	hello := factory.NewPacket(factory.GeneratePacketId(), factory.PacketType_PACKET_TYPE_REGISTER, methodID, nil, nil, nil)
+	if err := utils.WriteMessage(conn, nil, hello); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to register with orchestrator: %w", err)
+	}
+
+	// Read unbuffered so nothing sent after the answer is lost to the IONode
+	ack := &factory.Packet{}
+	if err := utils.ReadMessageLimit(conn, ack, utils.MaxHandshakeSize); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to register with orchestrator: %w", err)
+	}
+	if err := ack.Error.ToGoError(); err != nil {
+		conn.Close()
+		return fmt.Errorf("orchestrator refused registration: %w", err)
+	}
+
+	conn.SetDeadline(time.Time{})
+	remote.conn = conn
+	remote.id = ack.Id
+	return nil
+}
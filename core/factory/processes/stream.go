@@ -0,0 +1,260 @@
+package processes
+
+import (
+	"context"
+	"log"
+
+	"github.com/bsmider/pipes/core/factory"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// nodeStream is a stream this node takes part in
+type nodeStream struct {
+	stream *factory.PacketStream
+	client bool // true if this node opened the stream
+}
+
+// HandleServerStream registers the logic of a server-streaming method,
+// e.g. func(*example.ListBooksRequest, example.BookService_ListBooksServer) error
+func HandleServerStream[Req any, Resp any](logic func(*Req, grpc.ServerStreamingServer[Resp]) error) {
+	handleStreams(func(ctx context.Context, openPacket *factory.Packet, stream *factory.PacketStream) error {
+		request := new(Req)
+		if err := proto.Unmarshal(openPacket.Payload, any(request).(proto.Message)); err != nil {
+			return status.Errorf(codes.InvalidArgument, "decode error: %v", err)
+		}
+		return logic(request, &grpc.GenericServerStream[Req, Resp]{ServerStream: newServerStream(ctx, stream)})
+	})
+}
+
+// HandleClientStream registers the logic of a client-streaming method,
+// e.g. func(example.BookService_UploadBooksServer) error
+func HandleClientStream[Req any, Resp any](logic func(grpc.ClientStreamingServer[Req, Resp]) error) {
+	handleStreams(func(ctx context.Context, _ *factory.Packet, stream *factory.PacketStream) error {
+		return logic(&grpc.GenericServerStream[Req, Resp]{ServerStream: newServerStream(ctx, stream)})
+	})
+}
+
+// HandleBidiStream registers the logic of a bidirectional streaming method,
+// e.g. func(example.BookService_ChatServer) error
+func HandleBidiStream[Req any, Resp any](logic func(grpc.BidiStreamingServer[Req, Resp]) error) {
+	handleStreams(func(ctx context.Context, _ *factory.Packet, stream *factory.PacketStream) error {
+		return logic(&grpc.GenericServerStream[Req, Resp]{ServerStream: newServerStream(ctx, stream)})
+	})
+}
+
+// handleStreams runs serve for every stream opened to this node, ending
+// each stream with the error serve returns
+func handleStreams(serve func(context.Context, *factory.Packet, *factory.PacketStream) error) {
+	node := GetIONode()
+
+	go func() {
+		for openPacket := range node.RequestChannel {
+			go func(openPacket *factory.Packet) {
+				defer node.finishRequest()
+				defer node.untrackRequest(openPacket.Id)
+				defer node.removeStream(openPacket.Id)
+
+				stream, ok := node.getStream(openPacket.Id)
+				if !ok {
+					log.Printf("[ProcessRunner] Packet %s is not a stream, this method only serves streams\n", openPacket.Id)
+					node.rejectRequest(openPacket, status.New(codes.Unimplemented, "method only serves streams"))
+					return
+				}
+
+				ctx, cancel := openPacket.Context.ToGoContextFrom(node.requestContext(openPacket.Id))
+				defer cancel()
+
+				err := serve(ctx, openPacket, stream)
+				node.releaseSlot(openPacket.Id)
+				if err := stream.CloseSend((&factory.Error{}).FromGoError(err)); err != nil {
+					log.Printf("write error: %v", err)
+				}
+			}(openPacket)
+		}
+	}()
+}
+
+// OpenStream opens a bidirectional stream to another method
+func OpenStream[Req any, Resp any](targetIoType string, ctx context.Context) (grpc.BidiStreamingClient[Req, Resp], error) {
+	clientStream, err := GetIONode().openStream(ctx, targetIoType, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc.GenericClientStream[Req, Resp]{ClientStream: clientStream}, nil
+}
+
+// OpenClientStream opens a client-streaming call to another method
+func OpenClientStream[Req any, Resp any](targetIoType string, ctx context.Context) (grpc.ClientStreamingClient[Req, Resp], error) {
+	clientStream, err := GetIONode().openStream(ctx, targetIoType, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc.GenericClientStream[Req, Resp]{ClientStream: clientStream}, nil
+}
+
+// CallServerStream calls a server-streaming method and returns the stream of responses
+func CallServerStream[Req any, Resp any](targetIoType string, ctx context.Context, request *Req) (grpc.ServerStreamingClient[Resp], error) {
+	clientStream, err := GetIONode().openStream(ctx, targetIoType, any(request).(proto.Message))
+	if err != nil {
+		return nil, err
+	}
+	return &grpc.GenericClientStream[Req, Resp]{ClientStream: clientStream}, nil
+}
+
+// openStream sends a STREAM_OPEN to targetIoType. For server-streaming calls
+// request is the single request message, otherwise it is nil.
+func (node *IONode) openStream(ctx context.Context, targetIoType string, request proto.Message) (*clientStream, error) {
+	ioCtx := (&factory.Context{}).FromGoContext(ctx)
+
+	var payload []byte
+	if request != nil {
+		var err error
+		if payload, err = proto.Marshal(request); err != nil {
+			return nil, status.Errorf(codes.Internal, "serialize error: %v", err)
+		}
+	}
+	openPacket := factory.NewPacket(factory.GeneratePacketId(), factory.PacketType_PACKET_TYPE_STREAM_OPEN, targetIoType, ioCtx, payload, nil)
+
+	stream := factory.NewPacketStream(openPacket.Id, targetIoType, ioCtx, node.sendPacket)
+	node.addStream(stream, true)
+
+	if err := node.sendPacket(openPacket); err != nil {
+		node.removeStream(openPacket.Id)
+		return nil, err
+	}
+
+	// Once the caller's context is done the stream is cancelled downstream
+	go func() {
+		select {
+		case <-ctx.Done():
+			node.sendCancel(openPacket)
+			node.removeStream(openPacket.Id)
+			stream.Abort(status.FromContextError(ctx.Err()).Err())
+		case <-stream.Finished():
+		}
+	}()
+
+	if request != nil {
+		// A server-streaming call sends nothing after its request
+		stream.CloseSend(nil)
+	}
+
+	return &clientStream{ctx: ctx, stream: stream}, nil
+}
+
+// acceptStream registers the server end of a stream opened to this node
+func (node *IONode) acceptStream(openPacket *factory.Packet) {
+	stream := factory.NewPacketStream(openPacket.Id, openPacket.TargetIoType, openPacket.Context, node.sendPacket)
+	node.addStream(stream, false)
+}
+
+// deliverStreamPacket hands a STREAM_DATA, STREAM_END or STREAM_WINDOW packet to its stream
+func (node *IONode) deliverStreamPacket(packet *factory.Packet) {
+	node.mapMu.Lock()
+	s, ok := node.streams[packet.Id]
+	node.mapMu.Unlock()
+
+	if !ok {
+		node.dropPacket(packet, "unknown stream")
+		return
+	}
+
+	s.stream.Deliver(packet)
+
+	// For a stream we opened, the other end's STREAM_END completes the call
+	if s.client && packet.Type == factory.PacketType_PACKET_TYPE_STREAM_END {
+		node.removeStream(packet.Id)
+	}
+}
+
+// cancelStream aborts a stream after a CANCEL packet
+func (node *IONode) cancelStream(packetID string) {
+	if s, ok := node.getStream(packetID); ok {
+		s.Abort(status.Error(codes.Canceled, "stream cancelled"))
+	}
+}
+
+func (node *IONode) addStream(stream *factory.PacketStream, client bool) {
+	node.mapMu.Lock()
+	defer node.mapMu.Unlock()
+	node.streams[stream.Id()] = &nodeStream{stream: stream, client: client}
+}
+
+func (node *IONode) getStream(packetID string) (*factory.PacketStream, bool) {
+	node.mapMu.Lock()
+	defer node.mapMu.Unlock()
+	s, ok := node.streams[packetID]
+	if !ok {
+		return nil, false
+	}
+	return s.stream, true
+}
+
+func (node *IONode) removeStream(packetID string) {
+	node.mapMu.Lock()
+	defer node.mapMu.Unlock()
+	delete(node.streams, packetID)
+}
+
+// serverStream adapts a PacketStream to grpc.ServerStream,
+// so worker code keeps its generated grpc streaming signatures.
+// Headers and trailers are not carried over packets and are dropped.
+type serverStream struct {
+	ctx    context.Context
+	stream *factory.PacketStream
+}
+
+func newServerStream(ctx context.Context, stream *factory.PacketStream) *serverStream {
+	return &serverStream{ctx: ctx, stream: stream}
+}
+
+func (s *serverStream) SetHeader(metadata.MD) error  { return nil }
+func (s *serverStream) SendHeader(metadata.MD) error { return nil }
+func (s *serverStream) SetTrailer(metadata.MD)       {}
+func (s *serverStream) Context() context.Context     { return s.ctx }
+
+func (s *serverStream) SendMsg(m any) error {
+	return s.stream.Send(s.ctx, m.(proto.Message))
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	return recvMessage(s.ctx, s.stream, m)
+}
+
+// clientStream adapts a PacketStream to grpc.ClientStream
+type clientStream struct {
+	ctx    context.Context
+	stream *factory.PacketStream
+}
+
+func (s *clientStream) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+func (s *clientStream) Trailer() metadata.MD         { return metadata.MD{} }
+func (s *clientStream) Context() context.Context     { return s.ctx }
+
+func (s *clientStream) CloseSend() error {
+	return s.stream.CloseSend(nil)
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	return s.stream.Send(s.ctx, m.(proto.Message))
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	return recvMessage(s.ctx, s.stream, m)
+}
+
+// recvMessage reads the next message of stream into m
+func recvMessage(ctx context.Context, stream *factory.PacketStream, m any) error {
+	payload, err := stream.Recv(ctx)
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(payload, m.(proto.Message)); err != nil {
+		return status.Errorf(codes.Internal, "decode error: %v", err)
+	}
+	return nil
+}
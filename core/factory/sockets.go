@@ -0,0 +1,26 @@
+package factory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SocketDir is the directory of the current user's orchestrator sockets,
+// $XDG_RUNTIME_DIR/pipes or else pipes-<uid> in the temporary directory
+func SocketDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "pipes")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("pipes-%d", os.Getuid()))
+}
+
+// DefaultAttachSocket is where generated orchestrators accept workers launched by hand
+func DefaultAttachSocket() string {
+	return filepath.Join(SocketDir(), "attach.sock")
+}
+
+// DefaultAdminSocket is where generated orchestrators serve the admin service
+func DefaultAdminSocket() string {
+	return filepath.Join(SocketDir(), "admin.sock")
+}
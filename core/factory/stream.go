@@ -0,0 +1,190 @@
+package factory
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/bsmider/pipes/core/factory/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultStreamWindow is how many STREAM_DATA packets each side of a stream may
+// send before the receiver has to grant more credit with a STREAM_WINDOW packet.
+const DefaultStreamWindow = 64
+
+// PacketStream is one end of a stream multiplexed over packets.
+// Packets received from the other end are handed to it with Deliver,
+// packets sent to the other end go through the send function.
+//
+// Send may be called concurrently with Recv, but neither may be
+// called concurrently with itself (the same rules as grpc streams).
+type PacketStream struct {
+	id      string
+	target  string
+	context *Context
+	send    func(*Packet) error
+
+	incoming chan *Packet  // STREAM_DATA and STREAM_END packets from the other end
+	credits  chan struct{} // one token per STREAM_DATA packet we may still send
+	consumed uint32        // data packets received since the last window update
+	recvErr  error         // sticky result once the other end's STREAM_END was read
+
+	sendMu     sync.Mutex
+	sendClosed bool
+
+	finished   chan struct{} // closed when the other end's STREAM_END arrives
+	finishOnce sync.Once
+	aborted    chan struct{} // closed by Abort
+	abortOnce  sync.Once
+	abortErr   error
+}
+
+// NewPacketStream creates one end of the stream with the given id. Both ends
+// start out with DefaultStreamWindow credits.
+func NewPacketStream(id string, target string, context *Context, send func(*Packet) error) *PacketStream {
+	stream := &PacketStream{
+		id:       id,
+		target:   target,
+		context:  context,
+		send:     send,
+		incoming: make(chan *Packet, DefaultStreamWindow+1),
+		credits:  make(chan struct{}, DefaultStreamWindow),
+		finished: make(chan struct{}),
+		aborted:  make(chan struct{}),
+	}
+	stream.grant(DefaultStreamWindow)
+	return stream
+}
+
+// Id returns the id shared by every packet of the stream
+func (s *PacketStream) Id() string {
+	return s.id
+}
+
+// Context returns the packet context the stream was opened with
+func (s *PacketStream) Context() *Context {
+	return s.context
+}
+
+// Finished is closed once the other end has ended the stream
+func (s *PacketStream) Finished() <-chan struct{} {
+	return s.finished
+}
+
+// Deliver hands a packet received from the other end to the stream.
+// It never blocks, so it is safe to call from a connection's reader loop.
+func (s *PacketStream) Deliver(packet *Packet) {
+	switch packet.Type {
+	case PacketType_PACKET_TYPE_STREAM_WINDOW:
+		s.grant(packet.Window)
+
+	case PacketType_PACKET_TYPE_STREAM_DATA, PacketType_PACKET_TYPE_STREAM_END:
+		select {
+		case s.incoming <- packet:
+		default:
+			// The other end sent more than its window allows
+			log.Printf("[PacketStream] Warning: Drop packet on stream %s - window exceeded\n", s.id)
+		}
+
+		if packet.Type == PacketType_PACKET_TYPE_STREAM_END {
+			s.finishOnce.Do(func() { close(s.finished) })
+		}
+	}
+}
+
+// Send encodes message as a STREAM_DATA packet, waiting for credit if the window is used up
+func (s *PacketStream) Send(ctx context.Context, message proto.Message) error {
+	select {
+	case <-s.credits:
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	case <-s.aborted:
+		return s.abortErr
+	}
+
+	bytes, err := utils.SerializeMessage(message)
+	if err != nil {
+		return status.Errorf(codes.Internal, "serialize error: %v", err)
+	}
+
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if s.sendClosed {
+		return status.Error(codes.FailedPrecondition, "send on closed stream")
+	}
+	return s.send(NewPacket(s.id, PacketType_PACKET_TYPE_STREAM_DATA, s.target, nil, bytes, nil))
+}
+
+// CloseSend tells the other end no more messages will be sent.
+// A server ends the stream with its final error, which may be nil.
+func (s *PacketStream) CloseSend(err *Error) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	if s.sendClosed {
+		return nil
+	}
+	s.sendClosed = true
+	return s.send(NewPacket(s.id, PacketType_PACKET_TYPE_STREAM_END, s.target, s.context, nil, err))
+}
+
+// Recv returns the payload of the next message from the other end.
+// It returns io.EOF once the other end ended the stream without an error.
+func (s *PacketStream) Recv(ctx context.Context) ([]byte, error) {
+	if s.recvErr != nil {
+		return nil, s.recvErr
+	}
+
+	select {
+	case packet := <-s.incoming:
+		if packet.Type == PacketType_PACKET_TYPE_STREAM_END {
+			s.recvErr = io.EOF
+			if err := packet.Error.ToGoError(); err != nil {
+				s.recvErr = err
+			}
+			return nil, s.recvErr
+		}
+
+		// Hand the credit back once half of the window has been consumed
+		s.consumed++
+		if s.consumed >= DefaultStreamWindow/2 {
+			window := NewPacket(s.id, PacketType_PACKET_TYPE_STREAM_WINDOW, s.target, nil, nil, nil)
+			window.Window = s.consumed
+			s.consumed = 0
+			if err := s.send(window); err != nil {
+				return nil, fmt.Errorf("failed to update window: %w", err)
+			}
+		}
+		return packet.Payload, nil
+
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+
+	case <-s.aborted:
+		return nil, s.abortErr
+	}
+}
+
+// Abort unblocks every pending and future Send and Recv with err
+func (s *PacketStream) Abort(err error) {
+	s.abortOnce.Do(func() {
+		s.abortErr = err
+		close(s.aborted)
+	})
+}
+
+// grant adds n send credits
+func (s *PacketStream) grant(n uint32) {
+	for i := uint32(0); i < n; i++ {
+		select {
+		case s.credits <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
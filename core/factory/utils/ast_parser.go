@@ -8,17 +8,36 @@ import (
 	"strings"
 )
 
+// MethodKind is the streaming shape of an RPC method
+type MethodKind int
+
+const (
+	MethodKindUnary MethodKind = iota
+	MethodKindServerStreaming
+	MethodKindClientStreaming
+	MethodKindBidiStreaming
+)
+
 // ServiceMethod represents a parsed RPC service method
 type ServiceMethod struct {
-	Name         string // Method name (e.g., "GetBook")
-	ReceiverType string // Receiver type (e.g., "BookService")
-	ReceiverName string // Receiver variable name (e.g., "s")
-	CtxName      string // Context parameter name
-	ReqName      string // Request parameter name
-	ReqType      string // Request type (e.g., "*example.GetBookRequest")
-	RespType     string // Response type (e.g., "*example.GetBookResponse")
-	BodyStart    int    // Starting position of function body (after '{')
-	BodyEnd      int    // Ending position of function body (before '}')
+	Name         string            // Method name (e.g., "GetBook")
+	Kind         MethodKind        // Unary or one of the streaming kinds
+	ReceiverType string            // Receiver type (e.g., "BookService")
+	ReceiverName string            // Receiver variable name (e.g., "s")
+	CtxName      string            // Context parameter name
+	ReqName      string            // Request parameter name
+	ReqType      string            // Request type (e.g., "*example.GetBookRequest")
+	RespType     string            // Response type (e.g., "*example.GetBookResponse")
+	StreamName   string            // Stream parameter name of a streaming method
+	StreamType   string            // Stream parameter type (e.g., "example.BookService_ListBooksServer")
+	Options      map[string]string // Settings from "//pipes:pool key=value" directives in the doc comment
+	BodyStart    int               // Starting position of function body (after '{')
+	BodyEnd      int               // Ending position of function body (before '}')
+}
+
+// IsStream reports whether the method streams in either direction
+func (m *ServiceMethod) IsStream() bool {
+	return m.Kind != MethodKindUnary
 }
 
 // RPCCall represents a call to another RPC method that needs to be transformed
@@ -46,6 +65,7 @@ type ParsedServiceFile struct {
 	ServiceName     string
 	Methods         []ServiceMethod
 	ProtoImportPath string // The proto import path (e.g., "github.com/bsmider/pipes/core/factory/build/example")
+	ProtoPackage    string // The name the proto package is referenced by in the file (e.g., "example")
 }
 
 // ParseServiceFile parses a Go service file and extracts RPC method information
@@ -77,7 +97,12 @@ func ParseServiceFile(filePath string) (*ParsedServiceFile, error) {
 	}
 
 	// Find all method declarations with receivers
+	var parseErr error
 	ast.Inspect(file, func(n ast.Node) bool {
+		if parseErr != nil {
+			return false
+		}
+
 		funcDecl, ok := n.(*ast.FuncDecl)
 		if !ok || funcDecl.Recv == nil {
 			return true
@@ -106,22 +131,38 @@ func ParseServiceFile(filePath string) (*ParsedServiceFile, error) {
 			ReceiverName: recvName,
 		}
 
-		// Extract parameters
-		if funcDecl.Type.Params != nil && len(funcDecl.Type.Params.List) >= 2 {
-			// First param should be context
-			if len(funcDecl.Type.Params.List[0].Names) > 0 {
-				method.CtxName = funcDecl.Type.Params.List[0].Names[0].Name
-			}
-			// Second param should be request
-			if len(funcDecl.Type.Params.List[1].Names) > 0 {
-				method.ReqName = funcDecl.Type.Params.List[1].Names[0].Name
-			}
-			method.ReqType = exprToString(funcDecl.Type.Params.List[1].Type, fset)
+		options, err := parsePoolDirectives(funcDecl.Doc)
+		if err != nil {
+			parseErr = fmt.Errorf("method %s: %w", funcDecl.Name.Name, err)
+			return false
 		}
+		method.Options = options
+
+		// Extract parameters. Unary methods take (ctx, req), server-streaming
+		// methods take (req, stream) and client/bidi streaming methods take (stream).
+		params := flattenFields(funcDecl.Type.Params)
+		switch {
+		case len(params) >= 2 && isContextType(params[0].expr):
+			method.CtxName = params[0].name
+			method.ReqName = params[1].name
+			method.ReqType = exprToString(params[1].expr, fset)
+
+			// Extract return types
+			if funcDecl.Type.Results != nil && len(funcDecl.Type.Results.List) >= 1 {
+				method.RespType = exprToString(funcDecl.Type.Results.List[0].Type, fset)
+			}
 
-		// Extract return types
-		if funcDecl.Type.Results != nil && len(funcDecl.Type.Results.List) >= 1 {
-			method.RespType = exprToString(funcDecl.Type.Results.List[0].Type, fset)
+		case len(params) == 2:
+			method.Kind = MethodKindServerStreaming
+			method.ReqName = params[0].name
+			method.ReqType = exprToString(params[0].expr, fset)
+			method.StreamName = params[1].name
+			method.StreamType = exprToString(params[1].expr, fset)
+
+		case len(params) == 1:
+			method.Kind = MethodKindClientStreaming
+			method.StreamName = params[0].name
+			method.StreamType = exprToString(params[0].expr, fset)
 		}
 
 		// Extract body positions
@@ -134,16 +175,24 @@ func ParseServiceFile(filePath string) (*ParsedServiceFile, error) {
 		return true
 	})
 
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
 	// Resolve ProtoImportPath by inspecting the Request Type of the first available method
 	// We assume all methods in the service use types from the same proto package.
 	for _, method := range result.Methods {
-		if method.ReqType == "" {
+		typeName := method.ReqType
+		if typeName == "" {
+			typeName = method.StreamType
+		}
+		if typeName == "" {
 			continue
 		}
 
 		// Request type is typically like "*example.GetBookRequest"
 		// We want to extract "example"
-		parts := strings.Split(strings.TrimPrefix(method.ReqType, "*"), ".")
+		parts := strings.Split(strings.TrimPrefix(typeName, "*"), ".")
 		if len(parts) < 2 {
 			continue
 		}
@@ -169,10 +218,15 @@ func ParseServiceFile(filePath string) (*ParsedServiceFile, error) {
 		}
 
 		if result.ProtoImportPath != "" {
+			result.ProtoPackage = pkgAlias
 			break
 		}
 	}
 
+	if err := resolveStreamTypes(filePath, result); err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
@@ -209,11 +263,81 @@ func exprToString(expr ast.Expr, fset *token.FileSet) string {
 		return "*" + exprToString(t.X, fset)
 	case *ast.SelectorExpr:
 		return exprToString(t.X, fset) + "." + t.Sel.Name
+	case *ast.IndexExpr:
+		return exprToString(t.X, fset) + "[" + exprToString(t.Index, fset) + "]"
+	case *ast.IndexListExpr:
+		args := make([]string, len(t.Indices))
+		for i, index := range t.Indices {
+			args[i] = exprToString(index, fset)
+		}
+		return exprToString(t.X, fset) + "[" + strings.Join(args, ", ") + "]"
 	default:
 		return ""
 	}
 }
 
+// PoolDirective prefixes doc comment lines that configure a method's pool
+const PoolDirective = "//pipes:pool"
+
+// parsePoolDirectives collects the key=value pairs of every pool directive in doc,
+// e.g. "//pipes:pool timeout=20s retries=0"
+func parsePoolDirectives(doc *ast.CommentGroup) (map[string]string, error) {
+	if doc == nil {
+		return nil, nil
+	}
+
+	var options map[string]string
+	for _, comment := range doc.List {
+		rest, ok := strings.CutPrefix(comment.Text, PoolDirective)
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+		if options == nil {
+			options = make(map[string]string)
+		}
+
+		for _, pair := range strings.Fields(rest) {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || key == "" || value == "" {
+				return nil, fmt.Errorf("malformed %s setting %q, expected key=value", PoolDirective, pair)
+			}
+			options[key] = value
+		}
+	}
+	return options, nil
+}
+
+// field is a single named parameter
+type field struct {
+	name string
+	expr ast.Expr
+}
+
+// flattenFields expands grouped parameters like (a, b string) into one entry each
+func flattenFields(list *ast.FieldList) []field {
+	if list == nil {
+		return nil
+	}
+
+	var fields []field
+	for _, f := range list.List {
+		if len(f.Names) == 0 {
+			fields = append(fields, field{expr: f.Type})
+			continue
+		}
+		for _, name := range f.Names {
+			fields = append(fields, field{name: name.Name, expr: f.Type})
+		}
+	}
+	return fields
+}
+
+// isContextType reports whether expr is context.Context
+func isContextType(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Context"
+}
+
 // FindRPCCalls finds all receiver method calls in a function body that match the pattern s.MethodName(ctx, req)
 // These are the calls that need to be transformed to processes.Call
 func FindRPCCalls(filePath string, method ServiceMethod, serviceMethodNames []string) ([]RPCCall, error) {
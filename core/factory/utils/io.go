@@ -11,6 +11,10 @@ import (
 
 const HeaderSize = 4
 
+// MaxHandshakeSize caps the frames read while registering a worker or peering,
+// before anything is known about the other end
+const MaxHandshakeSize = 1 << 20
+
 // WriteMessage wraps a Protobuf message in a length-prefixed frame and writes it to w.
 // The mu parameter ensures that the length and payload are written as one atomic block.
 func WriteMessage(w io.Writer, mu *sync.Mutex, msg proto.Message) error {
@@ -39,6 +43,12 @@ func WriteMessage(w io.Writer, mu *sync.Mutex, msg proto.Message) error {
 
 // ReadMessage reads a length-prefixed Protobuf message from r.
 func ReadMessage(r io.Reader, msg proto.Message) error {
+	return ReadMessageLimit(r, msg, 0)
+}
+
+// ReadMessageLimit reads a length-prefixed Protobuf message from r like ReadMessage,
+// refusing frames longer than limit bytes before allocating them. A limit of 0 allows any length.
+func ReadMessageLimit(r io.Reader, msg proto.Message, limit uint32) error {
 	// log.Printf("[IO UTILS] READING MESSAGE")
 	// 1. Read the header
 	lenBuf := make([]byte, HeaderSize)
@@ -48,6 +58,9 @@ func ReadMessage(r io.Reader, msg proto.Message) error {
 
 	// 2. Parse length
 	length := binary.BigEndian.Uint32(lenBuf)
+	if limit > 0 && length > limit {
+		return fmt.Errorf("message of %d bytes exceeds the limit of %d", length, limit)
+	}
 
 	// 3. Read exactly 'length' bytes
 	payload := make([]byte, length)
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// streamKinds maps the generic grpc server stream types onto method kinds
+var streamKinds = map[string]MethodKind{
+	"ServerStreamingServer": MethodKindServerStreaming,
+	"ClientStreamingServer": MethodKindClientStreaming,
+	"BidiStreamingServer":   MethodKindBidiStreaming,
+}
+
+// streamType is a grpc stream type with its message type arguments
+type streamType struct {
+	kind MethodKind
+	args []string // Message types as written in their package e.g. "Book"
+}
+
+// resolveStreamTypes fills in the kind and message types of every streaming method.
+// Stream parameters are either written as the generic grpc type
+// (grpc.ServerStreamingServer[example.Book]) or as the alias protoc-gen-go-grpc
+// declares for it (example.BookService_ListBooksServer), in which case the alias
+// is looked up in the proto package's *_grpc.pb.go files.
+func resolveStreamTypes(filePath string, result *ParsedServiceFile) error {
+	var aliases map[string]streamType
+
+	for i := range result.Methods {
+		method := &result.Methods[i]
+		if !method.IsStream() {
+			continue
+		}
+
+		stream, ok := parseStreamType(method.StreamType)
+		if !ok {
+			if aliases == nil {
+				var err error
+				if aliases, err = loadStreamAliases(filePath, result.ProtoImportPath); err != nil {
+					return fmt.Errorf("failed to resolve stream type of %s: %w", method.Name, err)
+				}
+			}
+
+			stream, ok = aliases[strings.TrimPrefix(method.StreamType, result.ProtoPackage+".")]
+			if !ok {
+				return fmt.Errorf("unknown stream type %s of method %s", method.StreamType, method.Name)
+			}
+
+			// Types in the alias are local to the proto package
+			for j, arg := range stream.args {
+				if !strings.Contains(arg, ".") {
+					stream.args[j] = result.ProtoPackage + "." + arg
+				}
+			}
+		}
+
+		method.Kind = stream.kind
+		switch stream.kind {
+		case MethodKindServerStreaming:
+			method.RespType = "*" + stream.args[0]
+		default:
+			method.ReqType = "*" + stream.args[0]
+			method.RespType = "*" + stream.args[1]
+		}
+	}
+
+	return nil
+}
+
+// parseStreamType parses a type string like "grpc.BidiStreamingServer[example.Req, example.Resp]"
+func parseStreamType(typeString string) (streamType, bool) {
+	open := strings.Index(typeString, "[")
+	if open < 0 || !strings.HasSuffix(typeString, "]") {
+		return streamType{}, false
+	}
+
+	name := typeString[:open]
+	name = name[strings.LastIndex(name, ".")+1:]
+	kind, ok := streamKinds[name]
+	if !ok {
+		return streamType{}, false
+	}
+
+	args := strings.Split(typeString[open+1:len(typeString)-1], ",")
+	for i := range args {
+		args[i] = strings.TrimPrefix(strings.TrimSpace(args[i]), "*")
+	}
+	return streamType{kind: kind, args: args}, true
+}
+
+// loadStreamAliases collects the stream type aliases declared in the generated
+// grpc code of the package at importPath
+func loadStreamAliases(filePath string, importPath string) (map[string]streamType, error) {
+	dir, err := findPackageDir(filePath, importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_grpc.pb.go"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *_grpc.pb.go files in %s", dir)
+	}
+
+	aliases := make(map[string]streamType)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		parsed, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+
+		ast.Inspect(parsed, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok || !spec.Assign.IsValid() {
+				return true
+			}
+			if stream, ok := parseStreamType(exprToString(spec.Type, fset)); ok {
+				aliases[spec.Name.Name] = stream
+			}
+			return true
+		})
+	}
+
+	return aliases, nil
+}
+
+// findPackageDir locates the directory of importPath by walking up from filePath
+// to the enclosing go.mod, which has to be the module the package belongs to.
+func findPackageDir(filePath string, importPath string) (string, error) {
+	dir, err := filepath.Abs(filepath.Dir(filePath))
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		modulePath, err := readModulePath(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			if importPath != modulePath && !strings.HasPrefix(importPath, modulePath+"/") {
+				return "", fmt.Errorf("%s is not part of module %s", importPath, modulePath)
+			}
+			return filepath.Join(dir, strings.TrimPrefix(importPath, modulePath)), nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no go.mod found above %s", filePath)
+		}
+		dir = parent
+	}
+}
+
+// readModulePath returns the module path declared in a go.mod file
+func readModulePath(goModPath string) (string, error) {
+	file, err := os.Open(goModPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module")), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module directive in %s", goModPath)
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// ServerTLSConfig loads the orchestrator's certificate and requires every worker
+// dialing in to present a certificate signed by the CA in caFile
+func ServerTLSConfig(certFile string, keyFile string, caFile string) (*tls.Config, error) {
+	cert, pool, err := loadKeyPair(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
+
+// ClientTLSConfig loads a worker's certificate and only trusts an orchestrator
+// whose certificate is signed by the CA in caFile
+func ClientTLSConfig(certFile string, keyFile string, caFile string) (*tls.Config, error) {
+	cert, pool, err := loadKeyPair(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
+
+// loadKeyPair reads a PEM certificate and key, and the CA certificates in caFile
+func loadKeyPair(certFile string, keyFile string, caFile string) (tls.Certificate, *x509.CertPool, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to read CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return tls.Certificate{}, nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return cert, pool, nil
+}